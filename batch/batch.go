@@ -0,0 +1,233 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ohzqq/avtools"
+)
+
+// Job is a command to run, like a media.Cmd or ff.Cmd. Jobs with a
+// RunContext method are stopped when the batch is.
+type Job interface {
+	Run() error
+}
+
+type contextJob interface {
+	RunContext(ctx context.Context) error
+}
+
+// Options are how a batch is run.
+type Options struct {
+	// Workers is how many jobs run at once, runtime.NumCPU() when 0.
+	Workers int
+	// Retries is how many more times a failed job is tried.
+	Retries int
+	// KeepGoing runs the remaining jobs after one fails, instead of
+	// stopping the batch.
+	KeepGoing bool
+}
+
+// Result is the outcome of a job.
+type Result struct {
+	Index    int
+	Name     string
+	Err      error
+	Attempts int
+	Elapsed  time.Duration
+	// Skipped is set for jobs not started because the batch stopped.
+	Skipped bool
+}
+
+func (r Result) String() string {
+	switch {
+	case r.Skipped:
+		return fmt.Sprintf("skipped %s", r.Name)
+	case r.Err != nil && r.Attempts > 1:
+		return fmt.Sprintf("failed  %s (%s, %d attempts): %v", r.Name, r.Elapsed.Round(time.Millisecond), r.Attempts, r.Err)
+	case r.Err != nil:
+		return fmt.Sprintf("failed  %s (%s): %v", r.Name, r.Elapsed.Round(time.Millisecond), r.Err)
+	}
+	return fmt.Sprintf("ok      %s (%s)", r.Name, r.Elapsed.Round(time.Millisecond))
+}
+
+// Summary is the outcome of a batch, with the results in job order.
+type Summary struct {
+	Results []Result
+	Elapsed time.Duration
+}
+
+// Failed returns the results of the jobs that failed.
+func (s Summary) Failed() []Result {
+	var failed []Result
+	for _, r := range s.Results {
+		if r.Err != nil && !r.Skipped {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// Succeeded is the number of jobs that ran without error.
+func (s Summary) Succeeded() int {
+	var n int
+	for _, r := range s.Results {
+		if r.Err == nil && !r.Skipped {
+			n++
+		}
+	}
+	return n
+}
+
+// Skipped is the number of jobs that weren't started.
+func (s Summary) Skipped() int {
+	var n int
+	for _, r := range s.Results {
+		if r.Skipped {
+			n++
+		}
+	}
+	return n
+}
+
+// Err returns the first failure, noting how many jobs failed, the
+// cancellation when jobs were only skipped, or nil.
+func (s Summary) Err() error {
+	failed := s.Failed()
+	switch len(failed) {
+	case 0:
+		for _, r := range s.Results {
+			if r.Skipped {
+				return r.Err
+			}
+		}
+		return nil
+	case 1:
+		return failed[0].Err
+	}
+	// jobs stopped by a failure aren't its cause
+	first := failed[0]
+	for _, r := range failed {
+		if !errors.Is(r.Err, avtools.ErrCanceled) {
+			first = r
+			break
+		}
+	}
+	return fmt.Errorf("%d jobs failed, first: %s: %w", len(failed), first.Name, first.Err)
+}
+
+func (s Summary) String() string {
+	lines := make([]string, 0, len(s.Results)+1)
+	for _, r := range s.Results {
+		lines = append(lines, r.String())
+	}
+	lines = append(lines, fmt.Sprintf(
+		"%d ok, %d failed, %d skipped in %s",
+		s.Succeeded(),
+		len(s.Failed()),
+		s.Skipped(),
+		s.Elapsed.Round(time.Millisecond),
+	))
+	return strings.Join(lines, "\n")
+}
+
+// Run runs jobs on opts.Workers workers. Unless opts.KeepGoing is set, the
+// first job to fail stops the running ones and the rest are skipped, as they
+// are when ctx is done.
+func Run(ctx context.Context, opts Options, jobs ...Job) Summary {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	results := make([]Result, len(jobs))
+	for i, job := range jobs {
+		results[i] = Result{
+			Index:   i,
+			Name:    name(i, job),
+			Skipped: true,
+		}
+	}
+
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				r := run(ctx, jobs[i], opts.Retries)
+				r.Index, r.Name = i, results[i].Name
+				results[i] = r
+				if r.Err != nil && !opts.KeepGoing {
+					cancel()
+				}
+			}
+		}()
+	}
+
+	for i := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case queue <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		for i := range results {
+			if results[i].Skipped {
+				results[i].Err = fmt.Errorf("%w: %v", avtools.ErrCanceled, err)
+			}
+		}
+	}
+
+	return Summary{
+		Results: results,
+		Elapsed: time.Since(start),
+	}
+}
+
+// run runs job, trying again up to retries times unless it was canceled.
+func run(ctx context.Context, job Job, retries int) Result {
+	var r Result
+	start := time.Now()
+	for r.Attempts = 1; ; r.Attempts++ {
+		if cj, ok := job.(contextJob); ok {
+			r.Err = cj.RunContext(ctx)
+		} else {
+			r.Err = job.Run()
+		}
+		if r.Err == nil || r.Attempts > retries ||
+			errors.Is(r.Err, avtools.ErrCanceled) || ctx.Err() != nil {
+			break
+		}
+	}
+	r.Elapsed = time.Since(start)
+	return r
+}
+
+func name(i int, job Job) string {
+	if s, ok := job.(fmt.Stringer); ok {
+		if n := s.String(); n != "" {
+			return n
+		}
+	}
+	return fmt.Sprintf("job %d", i+1)
+}
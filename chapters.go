@@ -0,0 +1,254 @@
+package avtools
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"text/template"
+	"time"
+)
+
+// DefaultChapterTitle is the template Renumber titles chapters with by
+// default.
+const DefaultChapterTitle = "Chapter {{.Num}}"
+
+// Chapters is a list of chapters in order. The editing methods keep the
+// list contiguous where they can: a chapter that is removed or cut short
+// leaves its time to a neighbour. Times keep the timebase of each chapter,
+// and a chapter whose end isn't after its start, like the last track of a
+// cue sheet, is taken to run to the end of the media.
+type Chapters []*Chapter
+
+// ChapterName is the data passed to the Renumber template.
+type ChapterName struct {
+	Num   int
+	Total int
+	Title string
+}
+
+// Len is the length of the chapter.
+func (ch Chapter) Len() time.Duration {
+	ss, to := ch.Stamps()
+	return to.Sub(ss).Duration()
+}
+
+// open reports whether the chapter has no known end.
+func (ch Chapter) open() bool {
+	ss, to := ch.Stamps()
+	return to.Cmp(ss) <= 0
+}
+
+func (c Chapters) index(i int) error {
+	if i < 0 || i >= len(c) {
+		return fmt.Errorf("%w: %d of %d", ErrChapterRange, i+1, len(c))
+	}
+	return nil
+}
+
+// Shift moves every chapter by off, dropping the ones that end up before
+// zero and starting the first one at zero when it would start before.
+func (c *Chapters) Shift(off time.Duration) {
+	var shifted Chapters
+	for _, ch := range *c {
+		open := ch.open()
+		ss, to := ch.Stamps()
+		ss = ss.Add(StampOf(off)).Rescale(ss.Base, RoundNearest)
+		if !open {
+			to = to.Add(StampOf(off)).Rescale(to.Base, RoundNearest)
+			if to.Ticks <= 0 {
+				continue
+			}
+		}
+		if ss.Ticks < 0 {
+			ss.Ticks = 0
+		}
+		ch.SetStamps(ss, to)
+		shifted = append(shifted, ch)
+	}
+	*c = shifted
+}
+
+// Scale retimes the chapters for media played at tempo times the speed, as
+// by ffmpeg's atempo filter, so a tempo of 2 halves every time. The tempo is
+// taken to the nearest millionth.
+func (c Chapters) Scale(tempo float64) error {
+	if tempo <= 0 || math.IsInf(tempo, 0) || math.IsNaN(tempo) {
+		return fmt.Errorf("invalid tempo %v", tempo)
+	}
+	den := int64(math.Round(tempo * 1e6))
+	if den == 0 {
+		return fmt.Errorf("invalid tempo %v", tempo)
+	}
+	for _, ch := range c {
+		ss, to := ch.Stamps()
+		ch.SetStamps(
+			ss.Scale(1e6, den, RoundNearest),
+			to.Scale(1e6, den, RoundNearest),
+		)
+	}
+	return nil
+}
+
+// Merge joins each chapter into the one before it when merge reports true
+// for the pair. The earlier chapter keeps its title and tags.
+func (c *Chapters) Merge(merge func(prev, next *Chapter) bool) {
+	var merged Chapters
+	for _, ch := range *c {
+		if n := len(merged); n > 0 && merge(merged[n-1], ch) {
+			prev := merged[n-1]
+			ss, _ := prev.Stamps()
+			_, to := ch.Stamps()
+			prev.SetStamps(ss, to)
+			continue
+		}
+		merged = append(merged, ch)
+	}
+	*c = merged
+}
+
+// MergeShort joins chapters shorter than min into the one before them, or
+// the one after for the first chapter, until none are left or a single
+// chapter remains.
+func (c *Chapters) MergeShort(min time.Duration) {
+	chaps := *c
+	for i := 0; i < len(chaps) && len(chaps) > 1; {
+		if chaps[i].open() || chaps[i].Len() >= min {
+			i++
+			continue
+		}
+		if i == 0 {
+			ss, _ := chaps[0].Stamps()
+			_, to := chaps[1].Stamps()
+			chaps[1].SetStamps(ss, to)
+			chaps = chaps[1:]
+			continue
+		}
+		ss, _ := chaps[i-1].Stamps()
+		_, to := chaps[i].Stamps()
+		chaps[i-1].SetStamps(ss, to)
+		chaps = append(chaps[:i], chaps[i+1:]...)
+		// the merged chapter may still be too short
+		i--
+	}
+	*c = chaps
+}
+
+// Split cuts chapter i in two at the time at, which must fall inside it.
+// The new second part is titled title and copies the tags of the first.
+func (c *Chapters) Split(i int, at time.Duration, title string) error {
+	chaps := *c
+	if err := chaps.index(i); err != nil {
+		return err
+	}
+	ch := chaps[i]
+	ss, to := ch.Stamps()
+	cut := StampOf(at).Rescale(ss.Base, RoundNearest)
+	if cut.Cmp(ss) <= 0 || (!ch.open() && cut.Cmp(to) >= 0) {
+		return fmt.Errorf("%w: %s is not inside chapter %d", ErrChapterRange, StampOf(at), i+1)
+	}
+
+	next := &Chapter{
+		ChapTitle: title,
+		Tags:      make(map[string]string),
+	}
+	for k, v := range ch.Tags {
+		next.Tags[k] = v
+	}
+	next.SetStamps(cut, to)
+	ch.SetStamps(ss, cut)
+
+	chaps = append(chaps[:i+1], append(Chapters{next}, chaps[i+1:]...)...)
+	*c = chaps
+	return nil
+}
+
+// Insert adds ch in order of its start. The chapter before it is ended at
+// its start, and ch without an end, or overlapping the chapter after it,
+// ends where that one starts, or where the chapter before ended. A chapter
+// can't be inserted where another starts, as one of them would be empty.
+func (c *Chapters) Insert(ch *Chapter) error {
+	chaps := *c
+	ss, to := ch.Stamps()
+
+	i := 0
+	for i < len(chaps) {
+		start, _ := chaps[i].Stamps()
+		if start.Cmp(ss) == 0 {
+			return fmt.Errorf("%w: chapter %d already starts at %s", ErrChapterRange, i+1, ss)
+		}
+		if start.Cmp(ss) > 0 {
+			break
+		}
+		i++
+	}
+
+	var limit Stamp
+	if i > 0 {
+		prevStart, prevEnd := chaps[i-1].Stamps()
+		if prevEnd.Cmp(ss) > 0 {
+			chaps[i-1].SetStamps(prevStart, ss)
+			limit = prevEnd
+		}
+	}
+	if i < len(chaps) {
+		limit, _ = chaps[i].Stamps()
+	}
+	if limit.Valid() && (to.Cmp(ss) <= 0 || to.Cmp(limit) > 0) {
+		to = limit
+	}
+	ch.SetStamps(ss, to)
+
+	chaps = append(chaps[:i], append(Chapters{ch}, chaps[i:]...)...)
+	*c = chaps
+	return nil
+}
+
+// Delete removes chapter i. Its time goes to the chapter before it, or the
+// one after when it is the first.
+func (c *Chapters) Delete(i int) error {
+	chaps := *c
+	if err := chaps.index(i); err != nil {
+		return err
+	}
+	ss, to := chaps[i].Stamps()
+	switch {
+	case i > 0:
+		prev, _ := chaps[i-1].Stamps()
+		chaps[i-1].SetStamps(prev, to)
+	case len(chaps) > 1:
+		_, next := chaps[1].Stamps()
+		chaps[1].SetStamps(ss, next)
+	}
+	*c = append(chaps[:i], chaps[i+1:]...)
+	return nil
+}
+
+// Renumber retitles the chapters from a text/template executed with a
+// ChapterName, like "{{.Num}}. {{.Title}}".
+func (c Chapters) Renumber(tmpl string) error {
+	if tmpl == "" {
+		tmpl = DefaultChapterTitle
+	}
+	t, err := template.New("chapter").Parse(tmpl)
+	if err != nil {
+		return err
+	}
+
+	titles := make([]string, len(c))
+	for i, ch := range c {
+		var buf bytes.Buffer
+		err := t.Execute(&buf, ChapterName{
+			Num:   i + 1,
+			Total: len(c),
+			Title: ch.Title(),
+		})
+		if err != nil {
+			return err
+		}
+		titles[i] = buf.String()
+	}
+	for i, ch := range c {
+		ch.ChapTitle = titles[i]
+	}
+	return nil
+}
@@ -0,0 +1,349 @@
+package avtools
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// span is a chapter from start to end in seconds, titled title.
+type span struct {
+	start, end float64
+	title      string
+}
+
+func (s span) String() string {
+	return fmt.Sprintf("%q %gs-%gs", s.title, s.start, s.end)
+}
+
+func secs(s float64) Stamp {
+	return NewStamp(int64(s*1000), Millisecond)
+}
+
+// makeChapters makes chapters of the spans; an end of 0 is an open one.
+func makeChapters(spans ...span) Chapters {
+	var c Chapters
+	for _, s := range spans {
+		ch := &Chapter{ChapTitle: s.title, Tags: map[string]string{"title": s.title}}
+		ch.SetStamps(secs(s.start), secs(s.end))
+		c = append(c, ch)
+	}
+	return c
+}
+
+func spans(c Chapters) []span {
+	var got []span
+	for _, ch := range c {
+		ss, to := ch.Stamps()
+		got = append(got, span{
+			start: ss.Duration().Seconds(),
+			end:   to.Duration().Seconds(),
+			title: ch.Title(),
+		})
+	}
+	return got
+}
+
+func checkSpans(t *testing.T, c Chapters, want []span) {
+	t.Helper()
+	got := spans(c)
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("chapter %d: got %v, want %v", i+1, got[i], want[i])
+		}
+	}
+}
+
+var three = []span{{0, 10, "a"}, {10, 20, "b"}, {20, 30, "c"}}
+
+func TestShift(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		chaps []span
+		off   time.Duration
+		want  []span
+	}{
+		{"later", three, 5 * time.Second, []span{{5, 15, "a"}, {15, 25, "b"}, {25, 35, "c"}}},
+		{"earlier", three, -5 * time.Second, []span{{0, 5, "a"}, {5, 15, "b"}, {15, 25, "c"}}},
+		{"first ends at zero", three, -10 * time.Second, []span{{0, 10, "b"}, {10, 20, "c"}}},
+		{"past zero", three, -15 * time.Second, []span{{0, 5, "b"}, {5, 15, "c"}}},
+		{"open last", []span{{0, 10, "a"}, {10, 0, "b"}}, -5 * time.Second, []span{{0, 5, "a"}, {5, 0, "b"}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := makeChapters(tt.chaps...)
+			c.Shift(tt.off)
+			checkSpans(t, c, tt.want)
+		})
+	}
+}
+
+func TestScale(t *testing.T) {
+	for _, tt := range []struct {
+		tempo float64
+		want  []span
+	}{
+		{2, []span{{0, 5, "a"}, {5, 10, "b"}, {10, 15, "c"}}},
+		{1.5, []span{{0, 10.0 / 1.5, "a"}, {10.0 / 1.5, 20.0 / 1.5, "b"}, {20.0 / 1.5, 20, "c"}}},
+		{0.5, []span{{0, 20, "a"}, {20, 40, "b"}, {40, 60, "c"}}},
+	} {
+		t.Run(fmt.Sprint(tt.tempo), func(t *testing.T) {
+			c := makeChapters(three...)
+			err := c.Scale(tt.tempo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the ms timebase of the chapters is kept, so times are rounded
+			for i, s := range spans(c) {
+				w := tt.want[i]
+				if d := s.start - w.start; d > 0.0005 || d < -0.0005 {
+					t.Errorf("chapter %d start %g, want %g", i+1, s.start, w.start)
+				}
+				if d := s.end - w.end; d > 0.0005 || d < -0.0005 {
+					t.Errorf("chapter %d end %g, want %g", i+1, s.end, w.end)
+				}
+			}
+		})
+	}
+
+	for _, tempo := range []float64{0, -1, 1e-9} {
+		c := makeChapters(three...)
+		if err := c.Scale(tempo); err == nil {
+			t.Errorf("scaled by %g", tempo)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	c := makeChapters(span{0, 10, "a"}, span{10, 20, "a"}, span{20, 30, "b"}, span{30, 40, "a"})
+	c.Merge(func(prev, next *Chapter) bool {
+		return prev.Title() == next.Title()
+	})
+	checkSpans(t, c, []span{{0, 20, "a"}, {20, 30, "b"}, {30, 40, "a"}})
+}
+
+func TestMergeShort(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		chaps []span
+		want  []span
+	}{
+		{
+			"first into next",
+			[]span{{0, 2, "a"}, {2, 10, "b"}, {10, 30, "c"}},
+			[]span{{0, 10, "b"}, {10, 30, "c"}},
+		},
+		{
+			"into previous",
+			[]span{{0, 10, "a"}, {10, 12, "b"}, {12, 30, "c"}},
+			[]span{{0, 12, "a"}, {12, 30, "c"}},
+		},
+		{
+			"merged still short",
+			[]span{{0, 2, "a"}, {2, 3, "b"}, {3, 4, "c"}, {4, 8, "d"}, {8, 30, "e"}},
+			[]span{{0, 8, "d"}, {8, 30, "e"}},
+		},
+		{
+			"short last",
+			[]span{{0, 10, "a"}, {10, 12, "b"}},
+			[]span{{0, 12, "a"}},
+		},
+		{
+			"open last kept",
+			[]span{{0, 10, "a"}, {10, 0, "b"}},
+			[]span{{0, 10, "a"}, {10, 0, "b"}},
+		},
+		{
+			"one left",
+			[]span{{0, 2, "a"}, {2, 4, "b"}},
+			[]span{{0, 4, "b"}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := makeChapters(tt.chaps...)
+			c.MergeShort(5 * time.Second)
+			checkSpans(t, c, tt.want)
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		chaps []span
+		i     int
+		at    time.Duration
+		want  []span
+		err   error
+	}{
+		{
+			name: "inside",
+			i:    1, at: 15 * time.Second,
+			want: []span{{0, 10, "a"}, {10, 15, "b"}, {15, 20, "new"}, {20, 30, "c"}},
+		},
+		{
+			name: "last",
+			i:    2, at: 29 * time.Second,
+			want: []span{{0, 10, "a"}, {10, 20, "b"}, {20, 29, "c"}, {29, 30, "new"}},
+		},
+		{
+			name:  "open",
+			chaps: []span{{0, 10, "a"}, {10, 0, "b"}},
+			i:     1, at: time.Hour,
+			want: []span{{0, 10, "a"}, {10, 3600, "b"}, {3600, 0, "new"}},
+		},
+		{name: "on its start", i: 1, at: 10 * time.Second, err: ErrChapterRange},
+		{name: "on its end", i: 1, at: 20 * time.Second, err: ErrChapterRange},
+		{name: "outside", i: 0, at: 25 * time.Second, err: ErrChapterRange},
+		{name: "no chapter", i: 3, at: 25 * time.Second, err: ErrChapterRange},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.chaps == nil {
+				tt.chaps = three
+			}
+			c := makeChapters(tt.chaps...)
+			err := c.Split(tt.i, tt.at, "new")
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("got %v, want %v", err, tt.err)
+				}
+				checkSpans(t, c, tt.chaps)
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSpans(t, c, tt.want)
+			if tags := c[tt.i+1].Tags; tags["title"] != tt.chaps[tt.i].title {
+				t.Errorf("second part tags %v", tags)
+			}
+		})
+	}
+}
+
+func TestInsert(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		chaps []span
+		ch    span
+		want  []span
+	}{
+		{
+			"inside a chapter",
+			three, span{15, 0, "new"},
+			[]span{{0, 10, "a"}, {10, 15, "b"}, {15, 20, "new"}, {20, 30, "c"}},
+		},
+		{
+			"overlapping the next",
+			three, span{15, 25, "new"},
+			[]span{{0, 10, "a"}, {10, 15, "b"}, {15, 20, "new"}, {20, 30, "c"}},
+		},
+		{
+			"shorter",
+			three, span{12, 14, "new"},
+			[]span{{0, 10, "a"}, {10, 12, "b"}, {12, 14, "new"}, {20, 30, "c"}},
+		},
+		{
+			"first",
+			[]span{{5, 10, "a"}}, span{0, 0, "new"},
+			[]span{{0, 5, "new"}, {5, 10, "a"}},
+		},
+		{
+			"inside the last",
+			three, span{25, 0, "new"},
+			[]span{{0, 10, "a"}, {10, 20, "b"}, {20, 25, "c"}, {25, 30, "new"}},
+		},
+		{
+			"after the end",
+			three, span{40, 50, "new"},
+			[]span{{0, 10, "a"}, {10, 20, "b"}, {20, 30, "c"}, {40, 50, "new"}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := makeChapters(tt.chaps...)
+			err := c.Insert(makeChapters(tt.ch)[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSpans(t, c, tt.want)
+		})
+	}
+
+	// on a start, one of the two would be empty
+	for _, at := range []float64{0, 10, 20} {
+		c := makeChapters(three...)
+		err := c.Insert(makeChapters(span{at, 0, "new"})[0])
+		if !errors.Is(err, ErrChapterRange) {
+			t.Errorf("insert at %gs: got %v", at, err)
+		}
+		checkSpans(t, c, three)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		chaps []span
+		i     int
+		want  []span
+	}{
+		{"first", three, 0, []span{{0, 20, "b"}, {20, 30, "c"}}},
+		{"middle", three, 1, []span{{0, 20, "a"}, {20, 30, "c"}}},
+		{"last", three, 2, []span{{0, 10, "a"}, {10, 30, "b"}}},
+		{"open last", []span{{0, 10, "a"}, {10, 0, "b"}}, 1, []span{{0, 0, "a"}}},
+		{"only", []span{{0, 10, "a"}}, 0, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := makeChapters(tt.chaps...)
+			err := c.Delete(tt.i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSpans(t, c, tt.want)
+		})
+	}
+
+	c := makeChapters(three...)
+	for _, i := range []int{-1, 3} {
+		if err := c.Delete(i); !errors.Is(err, ErrChapterRange) {
+			t.Errorf("delete %d: got %v", i, err)
+		}
+	}
+	checkSpans(t, c, three)
+}
+
+func TestRenumber(t *testing.T) {
+	for _, tt := range []struct {
+		tmpl string
+		want []string
+	}{
+		{"", []string{"Chapter 1", "Chapter 2", "Chapter 3"}},
+		{"{{.Num}}/{{.Total}} {{.Title}}", []string{"1/3 a", "2/3 b", "3/3 c"}},
+		{`{{printf "%02d" .Num}} - {{.Title}}`, []string{"01 - a", "02 - b", "03 - c"}},
+	} {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			c := makeChapters(three...)
+			err := c.Renumber(tt.tmpl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, ch := range c {
+				if ch.Title() != tt.want[i] {
+					t.Errorf("chapter %d: got %q, want %q", i+1, ch.Title(), tt.want[i])
+				}
+			}
+		})
+	}
+
+	// a failing template leaves the titles alone
+	c := makeChapters(three...)
+	for _, tmpl := range []string{"{{.Num", "{{.Missing}}"} {
+		if err := c.Renumber(tmpl); err == nil {
+			t.Errorf("renumbered with %q", tmpl)
+		}
+	}
+	checkSpans(t, c, three)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/ohzqq/avtools/media"
+	"github.com/ohzqq/avtools/silence"
+	"github.com/spf13/cobra"
+)
+
+var (
+	chapterize     media.Command
+	chapterizeOpts silence.Options
+)
+
+// chapterizeCmd represents the chapterize command
+var chapterizeCmd = &cobra.Command{
+	Use:   "chapterize",
+	Short: "make chapters from silences",
+	Long: `make chapters at the silences of a media file, writing them as a cue
+sheet or ffmetadata, or embedding them in an updated copy.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		input := args[0]
+		chapterize.Context = cmd.Context()
+
+		cmds, err := chapterize.Chapterize(input, chapterizeOpts)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		runCmds(cmd.Context(), cmds...)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(chapterizeCmd)
+	chapterizeCmd.Flags().BoolVarP(&chapterize.Flags.Bool.Cue, "cue", "c", false, "write a cue sheet")
+	chapterizeCmd.Flags().BoolVarP(&chapterize.Flags.Bool.Meta, "meta", "m", false, "write ffmetadata")
+	chapterizeCmd.Flags().StringVar(&chapterizeOpts.Noise, "noise", silence.DefaultNoise, "level below which audio is silent")
+	chapterizeCmd.Flags().DurationVarP(&chapterizeOpts.Silence, "silence", "s", silence.DefaultSilence, "shortest silence between chapters")
+	chapterizeCmd.Flags().DurationVar(&chapterizeOpts.MinLength, "min", 0, "shortest chapter")
+	chapterizeCmd.Flags().DurationVar(&chapterizeOpts.MaxLength, "max", 0, "longest chapter, broken at shorter pauses")
+	chapterizeCmd.Flags().IntVarP(&chapterizeOpts.Count, "count", "n", 0, "number of chapters, breaking at the longest silences")
+}
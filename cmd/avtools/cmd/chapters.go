@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ohzqq/avtools"
+	"github.com/ohzqq/avtools/media"
+	"github.com/spf13/cobra"
+)
+
+var chapters media.Command
+
+var chapterEdit struct {
+	shift      string
+	tempo      float64
+	mergeUnder string
+	mergeTitle string
+	split      []string
+	insert     []string
+	remove     []int
+	rename     string
+}
+
+// chaptersCmd represents the chapters command
+var chaptersCmd = &cobra.Command{
+	Use:   "chapters",
+	Short: "edit chapters",
+	Long: `edit the chapters of a media or metadata file.
+
+Chapter numbers given to --split and --delete refer to the chapters as they
+are; the edits are then applied in this order: split, delete, insert, merge,
+shift, tempo, rename. Media files are written as updated-<name>, metadata
+files as <name>-edited.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		input := args[0]
+		chapters.Context = cmd.Context()
+
+		c, err := chapters.EditChapters(input, editChapters)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		runCmds(cmd.Context(), c)
+	},
+}
+
+func editChapters(chaps *avtools.Chapters) error {
+	e := chapterEdit
+
+	type numbered struct {
+		num   int
+		split string
+	}
+	var ops []numbered
+	for _, s := range e.split {
+		n, at, ok := strings.Cut(s, "@")
+		num, err := strconv.Atoi(n)
+		if !ok || err != nil {
+			return fmt.Errorf("--split %q: want NUM@TIME[=TITLE]", s)
+		}
+		ops = append(ops, numbered{num: num, split: at})
+	}
+	for _, num := range e.remove {
+		ops = append(ops, numbered{num: num})
+	}
+	// highest numbers first, so the lower ones still point at the same
+	// chapters
+	sort.SliceStable(ops, func(i, j int) bool {
+		return ops[i].num > ops[j].num
+	})
+	for _, op := range ops {
+		if op.split == "" {
+			err := chaps.Delete(op.num - 1)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		at, title, _ := strings.Cut(op.split, "=")
+		d, err := avtools.ParseStamp(at)
+		if err != nil {
+			return err
+		}
+		err = chaps.Split(op.num-1, d, title)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, s := range e.insert {
+		at, title, _ := strings.Cut(s, "=")
+		ch := &avtools.Chapter{
+			ChapTitle: title,
+			Tags:      make(map[string]string),
+		}
+		err := ch.SS(at)
+		if err != nil {
+			return err
+		}
+		err = chaps.Insert(ch)
+		if err != nil {
+			return err
+		}
+	}
+
+	if e.mergeTitle != "" {
+		re, err := regexp.Compile(e.mergeTitle)
+		if err != nil {
+			return err
+		}
+		chaps.Merge(func(prev, next *avtools.Chapter) bool {
+			return re.MatchString(next.Title())
+		})
+	}
+
+	if e.mergeUnder != "" {
+		min, err := avtools.ParseStamp(e.mergeUnder)
+		if err != nil {
+			return err
+		}
+		chaps.MergeShort(min)
+	}
+
+	if e.shift != "" {
+		off, err := parseOffset(e.shift)
+		if err != nil {
+			return err
+		}
+		chaps.Shift(off)
+	}
+
+	if e.tempo != 0 && e.tempo != 1 {
+		err := chaps.Scale(e.tempo)
+		if err != nil {
+			return err
+		}
+	}
+
+	if e.rename != "" {
+		return chaps.Renumber(e.rename)
+	}
+
+	return nil
+}
+
+// parseOffset parses a timestamp with an optional sign.
+func parseOffset(s string) (time.Duration, error) {
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign = -1
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	d, err := avtools.ParseStamp(s)
+	return sign * d, err
+}
+
+func init() {
+	chapters.Warn = warn
+	rootCmd.AddCommand(chaptersCmd)
+	chaptersCmd.Flags().StringVarP(&chapters.Flags.File.Meta, "meta", "m", "", "take the chapters of a media file from a metadata file")
+	chaptersCmd.Flags().StringVarP(&chapterEdit.shift, "shift", "s", "", "move all chapters by a signed offset, like -0:30")
+	chaptersCmd.Flags().Float64VarP(&chapterEdit.tempo, "tempo", "t", 0, "retime chapters for media sped up by a tempo factor")
+	chaptersCmd.Flags().StringVar(&chapterEdit.mergeUnder, "merge-under", "", "merge chapters shorter than a length into their neighbour")
+	chaptersCmd.Flags().StringVar(&chapterEdit.mergeTitle, "merge-title", "", "merge chapters whose title matches a regexp into the one before")
+	chaptersCmd.Flags().StringArrayVar(&chapterEdit.split, "split", nil, "split a chapter, as NUM@TIME[=TITLE]")
+	chaptersCmd.Flags().StringArrayVar(&chapterEdit.insert, "insert", nil, "insert a chapter, as TIME[=TITLE]")
+	chaptersCmd.Flags().IntSliceVarP(&chapterEdit.remove, "delete", "d", nil, "delete a chapter by number")
+	chaptersCmd.Flags().StringVarP(&chapterEdit.rename, "rename", "r", "", "retitle chapters from a template, like '{{.Num}}. {{.Title}}'")
+}
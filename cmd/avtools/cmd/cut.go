@@ -20,25 +20,31 @@ var cutCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		input := args[0]
-
-		var cutCmd media.Cmd
-
-		if cmd.Flags().Changed("ss") || cmd.Flags().Changed("to") {
-			cutCmd = cut.CutStamp(input, start, end)
-		}
-
-		if cmd.Flags().Changed("num") {
-			cutCmd = cut.CutChapter(input, chap)
+		cut.Context = cmd.Context()
+
+		var (
+			cutCmd media.Cmd
+			err    error
+		)
+
+		switch {
+		case cmd.Flags().Changed("num"):
+			cutCmd, err = cut.CutChapter(input, chap)
+		case cmd.Flags().Changed("ss") || cmd.Flags().Changed("to"):
+			cutCmd, err = cut.CutStamp(input, start, end)
+		default:
+			log.Fatal("cut needs --ss, --to or --num")
 		}
-
-		err := cutCmd.Run()
 		if err != nil {
 			log.Fatal(err)
 		}
+
+		runCmds(cmd.Context(), cutCmd)
 	},
 }
 
 func init() {
+	cut.Warn = warn
 	rootCmd.AddCommand(cutCmd)
 	cutCmd.Flags().StringVarP(&start, "ss", "s", "", "start of clip")
 	cutCmd.Flags().StringVarP(&end, "to", "e", "", "end of clip")
@@ -17,22 +17,30 @@ var extractCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		input := args[0]
-		cmds := extract.Extract(input)
-
-		for _, c := range cmds {
-			err := c.Run()
-			if err != nil {
-				log.Fatal(err)
-			}
+		extract.Context = cmd.Context()
+		cmds, err := extract.Extract(input)
+		if err != nil {
+			log.Fatal(err)
 		}
+
+		runCmds(cmd.Context(), cmds...)
 	},
 }
 
 func init() {
+	extract.Warn = warn
 	rootCmd.AddCommand(extractCmd)
 
 	// flags
 	extractCmd.PersistentFlags().BoolVarP(&extract.Bool.Meta, "meta", "m", false, "extract ffmeta")
 	extractCmd.PersistentFlags().BoolVarP(&extract.Bool.Cue, "cue", "c", false, "extract cue sheet")
+	extractCmd.PersistentFlags().BoolVarP(&extract.Bool.Mkv, "mkv", "x", false, "extract matroska chapter xml")
+	extractCmd.PersistentFlags().BoolVar(&extract.Bool.Vtt, "vtt", false, "extract webvtt chapters")
+	extractCmd.PersistentFlags().BoolVarP(&extract.Bool.JSON, "json", "j", false, "extract podcast json chapters")
+	extractCmd.PersistentFlags().BoolVar(&extract.Bool.Psc, "psc", false, "extract podlove simple chapters")
+	extractCmd.PersistentFlags().BoolVar(&extract.Bool.Youtube, "youtube", false, "extract youtube timestamps")
+	extractCmd.PersistentFlags().BoolVarP(&extract.Bool.Labels, "labels", "l", false, "extract audacity labels")
+	extractCmd.PersistentFlags().BoolVar(&extract.Bool.Reaper, "reaper", false, "extract reaper markers")
+	extractCmd.PersistentFlags().BoolVar(&extract.Bool.EDL, "edl", false, "extract chapters as a cmx 3600 edl with timecodes")
 	extractCmd.PersistentFlags().BoolVarP(&extract.Bool.Cover, "album art", "a", false, "extract album art")
 }
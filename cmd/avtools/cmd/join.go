@@ -26,16 +26,18 @@ var joinCmd = &cobra.Command{
 			log.Fatalf("wrong number of args")
 		}
 
-		ff, formats := media.Join(ext, dir)
-		ff.Run()
+		ff, formats, err := media.JoinContext(cmd.Context(), ext, dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cmds := []media.Cmd{ff}
 		for format, c := range formats {
-			if format == "ini" && join.Flags.Bool.Meta {
-				c.Run()
-			}
-			if format == "cue" && join.Flags.Bool.Cue {
-				c.Run()
+			if (format == "ini" && join.Flags.Bool.Meta) ||
+				(format == "cue" && join.Flags.Bool.Cue) {
+				cmds = append(cmds, c)
 			}
 		}
+		runCmds(cmd.Context(), cmds...)
 	},
 }
 
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// planCmd represents the plan command
+var planCmd = &cobra.Command{
+	Use:   "plan SUBCOMMAND [args...]",
+	Short: "save the commands of a subcommand as a job plan",
+	Long: `Plan runs a subcommand without running its commands, writing them
+as a job plan for avtools run instead, as json to stdout or to the file
+given with --save-plan, yaml for .yml and .yaml names.`,
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+			cmd.Help()
+			return
+		}
+		if args[0] == cmd.Name() {
+			log.Fatalf("can't plan a plan")
+		}
+
+		savePlan = "-"
+		rootCmd.SetArgs(args)
+		err := rootCmd.Execute()
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+}
@@ -3,118 +3,89 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"sort"
 
 	"github.com/ohzqq/avtools"
-	"github.com/ohzqq/avtools/av"
-	"github.com/ohzqq/avtools/cue"
-	"github.com/ohzqq/avtools/ffmeta"
-	"github.com/ohzqq/avtools/media"
-	"github.com/ohzqq/avtools/probe"
+	"github.com/ohzqq/avtools/codec"
+	"github.com/ohzqq/dur"
 	"github.com/spf13/cobra"
 )
 
-var prob media.Command
+var (
+	probeTimecode bool
+	probeFPS      string
+	probeNDF      bool
+)
 
 // probeCmd represents the probe command
 var probeCmd = &cobra.Command{
 	Use:   "probe",
 	Short: "show media info",
-	Long:  "",
+	Long:  "show the format, tags and chapters of a media or metadata file",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		input := args[0]
-		//testIni(input)
-		testMedia(input)
-		//testCue(input)
-		//testProbe(input)
-		//metad := meta.FFProbe(input)
-		//for _, ch := range metad.ChapterEntry {
-		//fmt.Printf("probe meta %+V\n", ch.Start)
-		//}
-		//fmt.Printf("probe meta %+V\n", f.HHMMSS())
-		//}
-		//m := media.New(input)
-		//for _, ch := range m.Chapters() {
-		//  fmt.Printf("probe meta %+V\n", ch.Start.String())
-		//}
-		//fmt.Printf("meta %+V\n", m.Input.Abs)
-		//fmt.Printf("meta %+V\n", len(m.Chapters()))
-		//fmt.Printf("tags %+V\n", m.Chapters()[len(m.Chapters())-1].Tags)
-		//meta := meta.LoadIni(input)
-	},
-}
 
-func testMedia(in string) {
-	media := av.New(in)
-	fmt.Printf("%+V\n", media)
-	testChaps(media.Chaps)
+		f, err := codec.Detect(input)
+		if err != nil {
+			log.Fatal(err)
+		}
+		meta, err := f.Decode(input)
+		if err != nil {
+			log.Fatal(err)
+		}
 
-	media.Cue("tmp/curse.cue")
-	testChaps(media.Chaps)
-	c := cue.Dump(in, media.Chaps)
-	fmt.Printf("%+V\n", string(c))
-}
+		var rate avtools.Rate
+		if probeTimecode {
+			rate = timecodeRate(meta.Streams())
+			fmt.Printf("fps: %s\n", rate)
+		}
 
-func testChaps(chaps []*avtools.Chapter) {
-	for _, ch := range chaps {
-		//fmt.Printf("%s\n", ch.StartTime)
-		fmt.Printf("%s\n", ch.StartStamp.HHMMSS())
-		fmt.Printf("%s\n", ch.EndStamp.HHMMSS())
-		//fmt.Printf("%s\n", ch.End().Milliseconds())
-	}
-}
+		fmt.Printf("format: %s\n", f.Name)
 
-func testProbe(in string) {
-	ff, err := probe.Load(in)
-	if err != nil {
-		log.Fatal(err)
-	}
-	fmt.Printf("%+V\n", ff)
-	//for _, ch := range ff.Chapters() {
-	for _, ch := range ff.Chapters() {
-		//fmt.Printf("%s\n", ch.StartTime)
-		fmt.Printf("%s\n", ch.Start())
-		fmt.Printf("%s\n", ch.End())
-		//fmt.Printf("%s\n", ch.End().Milliseconds())
-	}
-}
+		tags := meta.Tags()
+		var keys []string
+		for k := range tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Printf("%s: %s\n", k, tags[k])
+		}
 
-func testCue(in string) {
-	ff, err := cue.Load(in)
-	if err != nil {
-		log.Fatal(err)
-	}
-	fmt.Printf("%+V\n", ff)
-	for _, ch := range ff.Chapters() {
-		fmt.Printf("%s\n", ch.Start())
-		fmt.Printf("%s\n", ch.End().Milliseconds())
-	}
+		for i, ch := range meta.Chapters() {
+			if probeTimecode {
+				ss := avtools.StampOf(ch.Start()).Timecode(rate, !probeNDF)
+				to := avtools.StampOf(ch.End()).Timecode(rate, !probeNDF)
+				fmt.Printf("%03d %s --> %s %s\n", i+1, ss, to, ch.Title())
+				continue
+			}
+			ss, _ := dur.New(ch.Start())
+			to, _ := dur.New(ch.End())
+			fmt.Printf("%03d %s --> %s %s\n", i+1, ss, to, ch.Title())
+		}
+	},
 }
 
-func testIni(in string) {
-	ff, err := ffmeta.Load(in)
-	if err != nil {
-		log.Fatal(err)
+// timecodeRate is the --fps rate, or the rate of the probed video.
+func timecodeRate(streams []map[string]string) avtools.Rate {
+	if probeFPS != "" {
+		rate, err := avtools.ParseRate(probeFPS)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return rate
 	}
-	fmt.Printf("%+V\n", ff)
-	for _, ch := range ff.Chapters() {
-		fmt.Printf("%s\n", ch.Start())
-		fmt.Printf("%s\n", ch.End().Milliseconds())
+	rate, ok := avtools.StreamRate(streams)
+	if !ok {
+		log.Fatal("no video frame rate for timecodes, set one with --fps")
 	}
+	return rate
 }
 
 func init() {
 	rootCmd.AddCommand(probeCmd)
-
-	probeCmd.PersistentFlags().StringVarP(&prob.Flags.File.Meta, "meta", "m", "", "extract ffmeta")
-	probeCmd.PersistentFlags().StringVarP(&prob.Flags.File.Cue, "cue", "c", "", "extract cue sheet")
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// probeCmd.PersistentFlags().String("foo", "", "A help for foo")
-
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// probeCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	probeCmd.Flags().BoolVarP(&probeTimecode, "timecode", "t", false, "show chapters as smpte timecodes")
+	probeCmd.Flags().StringVar(&probeFPS, "fps", "", "frame rate of timecodes, defaults to the video's")
+	probeCmd.Flags().BoolVar(&probeNDF, "ndf", false, "non-drop-frame timecodes at 29.97 and 59.94 fps")
 }
@@ -17,11 +17,12 @@ var rmCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		input := args[0]
-		mCmd := remove.Remove(input)
-		err := mCmd.Run()
+		remove.Context = cmd.Context()
+		mCmd, err := remove.Remove(input)
 		if err != nil {
 			log.Fatal(err)
 		}
+		runCmds(cmd.Context(), mCmd)
 	},
 }
 
@@ -1,13 +1,21 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"mime"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"syscall"
 
+	"github.com/ohzqq/avtools/batch"
 	"github.com/ohzqq/avtools/ff"
+	"github.com/ohzqq/avtools/media"
+	"github.com/ohzqq/avtools/plan"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -20,6 +28,10 @@ var (
 	verbose    bool
 	overwrite  bool
 	filterFlag []string
+	batchOpts  batch.Options
+	dryRun     bool
+	emitScript string
+	savePlan   string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -31,13 +43,121 @@ var rootCmd = &cobra.Command{
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+//
+// Ctrl-C cancels the context of the commands, which stops the ffmpeg and
+// ffprobe they run: those are in process groups of their own, so the
+// terminal doesn't signal them. A second Ctrl-C exits at once.
 func Execute() {
-	err := rootCmd.Execute()
+	err := rootCmd.ExecuteContext(signalContext())
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
+// signalContext is done on the first interrupt or SIGTERM, after which
+// they are no longer caught.
+func signalContext() context.Context {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx
+}
+
+// runCmds runs cmds as a batch, exiting when any fail. When ctx is done,
+// on Ctrl-C, the running ffmpeg is stopped and the rest skipped. With
+// --dry-run or --emit-script the commands are only printed, and with
+// --save-plan saved as a job plan.
+func runCmds(ctx context.Context, cmds ...media.Cmd) {
+	if savePlan != "" {
+		err := saveCmds(cmds...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if dryRun || emitScript != "" {
+		steps, err := media.Plan(cmds...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = planCmds(steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	jobs := make([]batch.Job, len(cmds))
+	for i, c := range cmds {
+		jobs[i] = c
+	}
+	// bars of jobs running side by side would overwrite each other
+	if batchOpts.Workers != 1 && len(jobs) > 1 {
+		ff.DefaultProgress = nil
+	}
+
+	sum := batch.Run(ctx, batchOpts, jobs...)
+	if len(jobs) > 1 {
+		fmt.Fprintln(os.Stderr, sum)
+	}
+	err := sum.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// warn prints the problems media commands work around.
+func warn(input, msg string) {
+	log.Printf("%s: %s\n", input, msg)
+}
+
+// planCmds prints steps for --dry-run, and writes them as a script for
+// --emit-script, to stdout when it is "-".
+func planCmds(steps []ff.Step) error {
+	if emitScript == "" {
+		return ff.DryRun(os.Stdout, steps)
+	}
+	if emitScript == "-" {
+		return ff.Script(os.Stdout, steps)
+	}
+	file, err := os.OpenFile(emitScript, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return ff.Script(file, steps)
+}
+
+// saveCmds writes cmds as a job plan to --save-plan, to stdout as json when
+// it is "-".
+func saveCmds(cmds ...media.Cmd) error {
+	jobs := make([][]ff.Step, len(cmds))
+	for i, c := range cmds {
+		steps, err := media.Plan(c)
+		if err != nil {
+			return err
+		}
+		jobs[i] = steps
+	}
+
+	p, err := plan.New(jobs...)
+	if err != nil {
+		return err
+	}
+	if savePlan == "-" {
+		data, err := p.Marshal(".json")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Println(string(data))
+		return err
+	}
+	return p.Save(savePlan)
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 
@@ -52,6 +172,12 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&overwrite, "overwrite", "y", true, "")
 	rootCmd.PersistentFlags().StringVarP(&inName, "input", "i", "", "input video")
 	rootCmd.PersistentFlags().StringSliceVarP(&filterFlag, "filter", "f", []string{}, "")
+	rootCmd.PersistentFlags().IntVar(&batchOpts.Workers, "jobs", runtime.NumCPU(), "commands to run at once")
+	rootCmd.PersistentFlags().IntVar(&batchOpts.Retries, "retries", 0, "times to retry a failed command")
+	rootCmd.PersistentFlags().BoolVar(&batchOpts.KeepGoing, "keep-going", false, "run the remaining commands after one fails")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "print the commands and file writes instead of running them")
+	rootCmd.PersistentFlags().StringVar(&emitScript, "emit-script", "", "write the commands as a shell script instead of running them, - for stdout")
+	rootCmd.PersistentFlags().StringVar(&savePlan, "save-plan", "", "save the commands as a json or yaml job plan instead of running them, - for stdout")
 
 }
 
@@ -69,11 +195,15 @@ func initConfig() {
 		viper.SetConfigType("yaml")
 		viper.SetConfigName(".cmd")
 		path := filepath.Join(home, ".config/avtools/profiles.yml")
-		ff.ReadConfig(path)
+		cobra.CheckErr(ff.ReadConfig(path))
 	}
 
 	viper.AutomaticEnv() // read in environment variables that match
 
+	if ff.IsTerminal(os.Stderr) {
+		ff.DefaultProgress = ff.Bar(os.Stderr)
+	}
+
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ohzqq/avtools/plan"
+	"github.com/spf13/cobra"
+)
+
+var skipHash bool
+
+// runCmd represents the run command
+var runCmd = &cobra.Command{
+	Use:   "run PLAN",
+	Short: "run a job plan",
+	Long: `Run runs the commands of a job plan made with avtools plan, after
+checking its input files haven't changed since. Files below the directory
+it was planned in are found relative to the plan file, so the plan can be
+moved along with them.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		p, err := plan.Load(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		err = p.Check(!skipHash)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if dryRun || emitScript != "" {
+			err := planCmds(p.Steps())
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+
+		sum := p.Run(cmd.Context(), batchOpts)
+		if len(p.Jobs) > 1 {
+			fmt.Fprintln(os.Stderr, sum)
+		}
+		err = sum.Err()
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+	runCmd.Flags().BoolVar(&skipHash, "no-hash", false, "only check the size and mtime of input files")
+}
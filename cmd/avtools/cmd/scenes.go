@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/ohzqq/avtools/media"
+	"github.com/ohzqq/avtools/scene"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scenes    media.Command
+	sceneOpts scene.Options
+)
+
+// scenesCmd represents the scenes command
+var scenesCmd = &cobra.Command{
+	Use:   "scenes",
+	Short: "make chapters from scene changes",
+	Long: `make chapters at the scene changes of a video, writing them as
+ffmetadata, matroska xml or yygif clips, or embedding them in an updated
+copy.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		input := args[0]
+		scenes.Context = cmd.Context()
+
+		cmds, err := scenes.Scenes(input, sceneOpts)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		runCmds(cmd.Context(), cmds...)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scenesCmd)
+	scenesCmd.Flags().BoolVarP(&scenes.Flags.Bool.Meta, "meta", "m", false, "write ffmetadata")
+	scenesCmd.Flags().BoolVarP(&scenes.Flags.Bool.Mkv, "mkv", "x", false, "write matroska chapter xml")
+	scenesCmd.Flags().BoolVarP(&scenes.Flags.Bool.Clips, "clips", "g", false, "write yygif clip yaml")
+	scenesCmd.Flags().BoolVarP(&scenes.Flags.Bool.Thumbs, "thumbs", "t", false, "grab a thumbnail of every scene")
+	scenesCmd.Flags().Float64VarP(&sceneOpts.Threshold, "threshold", "s", scene.DefaultThreshold, "scene score from 0 to 1 that starts a new scene")
+	scenesCmd.Flags().DurationVar(&sceneOpts.MinLength, "min", scene.DefaultMinLength, "shortest scene")
+}
@@ -22,19 +22,34 @@ var splitCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		input := args[0]
-		cmds := split.Split(input)
-		for _, c := range cmds {
-			err := c.Run()
-			if err != nil {
-				log.Fatal(err)
-			}
+		split.Context = cmd.Context()
+
+		var (
+			cmds []media.Cmd
+			err  error
+		)
+		if split.Flags.Bool.Tracks {
+			cmds, err = split.SplitTracks(input)
+		} else {
+			cmds, err = split.Split(input)
+		}
+		if err != nil {
+			log.Fatal(err)
 		}
+
+		runCmds(cmd.Context(), cmds...)
 	},
 }
 
 func init() {
+	split.Warn = warn
 	rootCmd.AddCommand(splitCmd)
 	splitCmd.PersistentFlags().StringVarP(&split.Flags.File.Cue, "cue", "c", "", "split by cue sheet")
 	splitCmd.PersistentFlags().StringVarP(&split.Flags.File.Meta, "meta", "m", "", "split by ffmetadata")
+	splitCmd.PersistentFlags().BoolVarP(&split.Flags.Bool.Tracks, "tracks", "t", false, "split an album image into tagged tracks by cue sheet")
+	splitCmd.PersistentFlags().StringVar(&split.Flags.Pregap, "pregap", media.PregapAppend, "what to do with INDEX 00 pregaps: append, making track 1's a track 00, or drop")
+	splitCmd.PersistentFlags().StringVar(&split.Flags.Name, "name", media.DefaultTrackName, "track filename template")
+	splitCmd.PersistentFlags().BoolVar(&split.Flags.Bool.Strict, "strict", false, "refuse invalid chapters instead of repairing them")
 	splitCmd.MarkFlagsMutuallyExclusive("cue", "meta")
+	splitCmd.MarkFlagsMutuallyExclusive("tracks", "meta")
 }
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"log"
+
 	"github.com/ohzqq/avtools/media"
 	"github.com/spf13/cobra"
 )
@@ -14,8 +16,12 @@ var thumbCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		input := args[0]
-		tn := thumb.Thumbnail(input, outName)
-		tn.Run()
+		thumb.Context = cmd.Context()
+		tn, err := thumb.Thumbnail(input, outName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		runCmds(cmd.Context(), tn)
 	},
 }
 
@@ -17,8 +17,12 @@ var updateCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		input := args[0]
+		update.Context = cmd.Context()
 		//m := media.Update(input, update.Meta, update.Cue)
-		m := update.Update(input)
+		m, err := update.Update(input)
+		if err != nil {
+			log.Fatal(err)
+		}
 		//switch {
 		//case update.Meta != "":
 		//  m.LoadMeta(update.Meta)
@@ -27,10 +31,7 @@ var updateCmd = &cobra.Command{
 		//  m.LoadMeta(update.Cue)
 		//}
 
-		err := m.Run()
-		if err != nil {
-			log.Fatal(err)
-		}
+		runCmds(cmd.Context(), m)
 		//out := ffmpeg.Input("ffmeta.ini").Output("jlk", ffmpeg.KwArgs{"map_metadata": "1"})
 		//fmt.Printf("args %+V\n", in.Compile().Args)
 	},
@@ -46,7 +47,11 @@ func MapMetadata(file string, idx ...string) []string {
 }
 
 func init() {
+	update.Warn = warn
 	rootCmd.AddCommand(updateCmd)
-	updateCmd.PersistentFlags().StringVarP(&update.Flags.File.Meta, "meta", "m", "", "extract ffmeta")
-	updateCmd.PersistentFlags().StringVarP(&update.Flags.File.Cue, "cue", "c", "", "extract cue sheet")
+	updateCmd.PersistentFlags().StringVarP(&update.Flags.File.Meta, "meta", "m", "", "update from a metadata file of any supported format")
+	updateCmd.PersistentFlags().StringVarP(&update.Flags.File.Cue, "cue", "c", "", "update chapters from cue sheet")
+	updateCmd.PersistentFlags().StringVarP(&update.Flags.File.Mkv, "mkv", "x", "", "update chapters from matroska chapter xml")
+	updateCmd.PersistentFlags().BoolVar(&update.Flags.Bool.Strict, "strict", false, "refuse invalid chapters instead of repairing them")
+	updateCmd.PersistentFlags().StringVarP(&update.Flags.File.Labels, "labels", "l", "", "update chapters from audacity labels or reaper markers (.csv)")
 }
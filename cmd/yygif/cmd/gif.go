@@ -26,19 +26,9 @@ var gifCmd = &cobra.Command{
 				clip := gifMeta.GetClip(arg[0], arg[1])
 				ff := ParseFlags(cmd, clip)
 				ff.Compile()
-				err := ff.Run()
-				if err != nil {
-					log.Fatal(err)
-				}
+				runGifs(cmd.Context(), ff)
 			} else {
-				c := gifMeta.MkGifs()
-				for _, clip := range c {
-					clip.Compile()
-					err := clip.Run()
-					if err != nil {
-						log.Fatal(err)
-					}
-				}
+				runGifs(cmd.Context(), gifMeta.MkGifs()...)
 			}
 		}
 	},
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"bytes"
 	"fmt"
 	"log"
 	"os"
@@ -11,9 +10,10 @@ import (
 
 	"github.com/ohzqq/avtools"
 	"github.com/ohzqq/avtools/ff"
+	"github.com/ohzqq/avtools/ffmeta"
+	"github.com/ohzqq/avtools/media"
 	"github.com/spf13/cobra"
 	ffmpeg "github.com/u2takey/ffmpeg-go"
-	"gopkg.in/ini.v1"
 	"gopkg.in/yaml.v3"
 )
 
@@ -25,7 +25,10 @@ var metaCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		if MetaExists("metadata-default.yml") {
 			gifMeta := ReadMeta("metadata-default.yml")
-			ini := gifMeta.DumpIni()
+			ini, err := gifMeta.DumpIni()
+			if err != nil {
+				log.Fatal(err)
+			}
 			file, err := os.Create("gif-meta.ini")
 			if err != nil {
 				log.Fatal(err)
@@ -50,6 +53,9 @@ type Clip struct {
 	StartTime string `yaml:"s"`
 	EndTime   string `yaml:"e"`
 	Crop      string `yaml:"crop"`
+	// FPS is the frame rate s and e timecodes are read at, the video's when
+	// empty.
+	FPS string `yaml:"fps"`
 }
 
 func (m Meta) Chapters() []*avtools.Chapter {
@@ -59,8 +65,7 @@ func (m Meta) Chapters() []*avtools.Chapter {
 	for _, scene := range m {
 		for _, clip := range scene {
 			clip.Name = fmt.Sprintf("Gif%03d", count)
-			ch := avtools.NewChapter(clip)
-			ch.Tags["crop"] = clip.Crop
+			ch := clip.Chap()
 			count++
 			chapters = append(chapters, ch)
 		}
@@ -80,58 +85,45 @@ func (m Meta) Tags() map[string]string {
 	}
 }
 
-func (meta Meta) DumpIni() []byte {
-	ini.PrettyFormat = false
+func (meta Meta) DumpIni() ([]byte, error) {
+	return ffmeta.Dump(meta)
+}
 
-	opts := ini.LoadOptions{
-		IgnoreInlineComment:    true,
-		AllowNonUniqueSections: true,
-	}
+func (c Clip) Chap() *avtools.Chapter {
+	ch := avtools.NewChapter(c)
+	ch.Tags["crop"] = c.Crop
+	return ch
+}
+
+func (c Clip) Start() time.Duration {
+	return c.stamp(c.StartTime)
+}
 
-	ffmeta := ini.Empty(opts)
+func (c Clip) End() time.Duration {
+	return c.stamp(c.EndTime)
+}
 
-	for k, v := range meta.Tags() {
-		_, err := ffmeta.Section("").NewKey(k, v)
+func (c Clip) stamp(t string) time.Duration {
+	var rate avtools.Rate
+	switch {
+	case c.FPS != "":
+		r, err := avtools.ParseRate(c.FPS)
 		if err != nil {
 			log.Fatal(err)
 		}
-	}
-
-	for _, chapter := range meta.Chapters() {
-		sec, err := ffmeta.NewSection("CHAPTER")
+		rate = r
+	case avtools.IsTimecode(t):
+		m, err := media.NewContext(rootCmd.Context(), c.Video)
 		if err != nil {
 			log.Fatal(err)
 		}
-		sec.NewKey("START", chapter.Start.String())
-		sec.NewKey("END", chapter.End.String())
-		sec.NewKey("title", chapter.Title)
-		for k, v := range chapter.Tags {
-			sec.NewKey(k, v)
-		}
+		rate = m.FrameRate()
 	}
-
-	var buf bytes.Buffer
-	_, err := buf.WriteString(";FFMETADATA1\n")
-	_, err = ffmeta.WriteTo(&buf)
+	d, err := avtools.ParseStamp(t, rate)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	return buf.Bytes()
-}
-
-func (c Clip) Chap() *avtools.Chapter {
-	ch := avtools.NewChapter(c)
-	ch.Tags["crop"] = c.Crop
-	return ch
-}
-
-func (c Clip) Start() time.Duration {
-	return avtools.ParseStamp(c.StartTime)
-}
-
-func (c Clip) End() time.Duration {
-	return avtools.ParseStamp(c.EndTime)
+	return d
 }
 
 func (c Clip) Title() string {
@@ -170,13 +162,16 @@ func (m Meta) GetClip(s, c string) *ff.Cmd {
 func (c Clip) Compile() ff.Cmd {
 	cmd := ff.New("gif")
 	cmd.In(c.Video, c.Input())
+	cmd.Duration = c.End() - c.Start()
 	cmd.Filters = c.Filters()
 	cmd.Output = c.Output()
 	return cmd
 }
 
 func (c Clip) Input() ffmpeg.KwArgs {
-	return ffmpeg.KwArgs{"ss": c.StartTime, "to": c.EndTime}
+	ss := avtools.StampOf(c.Start()).FFmpeg()
+	to := avtools.StampOf(c.End()).FFmpeg()
+	return ffmpeg.KwArgs{"ss": ss, "to": to}
 }
 
 func (c Clip) Filters() ff.Filters {
@@ -1,17 +1,22 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"mime"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"syscall"
 
 	"github.com/ohzqq/avtools"
+	"github.com/ohzqq/avtools/batch"
+	"github.com/ohzqq/avtools/codec"
 	"github.com/ohzqq/avtools/ff"
 	"github.com/ohzqq/avtools/media"
-	"github.com/ohzqq/avtools/meta"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -39,6 +44,9 @@ var (
 	bayerScale string
 	dither     string
 	yadif      bool
+	batchOpts  batch.Options
+	dryRun     bool
+	emitScript string
 )
 
 var rootCmd = &cobra.Command{
@@ -50,6 +58,7 @@ var rootCmd = &cobra.Command{
 		//c := media.CutChapter(meta, meta.Chapters()[0])
 		//c.Run()
 
+		var gifs []*ff.Cmd
 		for _, ch := range getChapters(cmd, meta) {
 			g := media.CutChapter(meta, ch)
 			if c, ok := ch.Tags["crop"]; ok {
@@ -64,22 +73,81 @@ var rootCmd = &cobra.Command{
 				fmt.Println(ff.String())
 			}
 
-			err := ff.Run()
+			gifs = append(gifs, ff)
+		}
+		runGifs(cmd.Context(), gifs...)
+
+	},
+}
+
+// runGifs makes gifs as a batch, exiting when any fail. When ctx is done,
+// on Ctrl-C, the gifs being made are stopped and the rest skipped. With
+// --dry-run or --emit-script the commands are only printed.
+func runGifs(ctx context.Context, gifs ...*ff.Cmd) {
+	if dryRun || emitScript != "" {
+		var steps []ff.Step
+		for _, g := range gifs {
+			s, err := g.Plan()
 			if err != nil {
 				log.Fatal(err)
 			}
+			steps = append(steps, s...)
 		}
+		err := planGifs(steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	},
+	jobs := make([]batch.Job, len(gifs))
+	for i, g := range gifs {
+		jobs[i] = g
+	}
+	if batchOpts.Workers != 1 && len(jobs) > 1 {
+		ff.DefaultProgress = nil
+	}
+
+	sum := batch.Run(ctx, batchOpts, jobs...)
+	if len(jobs) > 1 {
+		fmt.Fprintln(os.Stderr, sum)
+	}
+	err := sum.Err()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// planGifs prints steps for --dry-run, and writes them as a script for
+// --emit-script, to stdout when it is "-".
+func planGifs(steps []ff.Step) error {
+	if emitScript == "" {
+		return ff.DryRun(os.Stdout, steps)
+	}
+	if emitScript == "-" {
+		return ff.Script(os.Stdout, steps)
+	}
+	file, err := os.OpenFile(emitScript, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return ff.Script(file, steps)
 }
 
 func LoadGifMeta(input string) *media.Media {
-	meta := meta.LoadIni(input)
-	src := avtools.NewMedia().SetMeta(meta)
-	vid := meta.Tags()["title"]
+	meta, err := codec.Decode(input)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src := avtools.NewMedia().Merge(meta)
+	in, err := media.NewFile(meta.Tags()["title"])
+	if err != nil {
+		log.Fatal(err)
+	}
 	return &media.Media{
 		Media:   src,
-		Input:   media.NewFile(vid),
+		Input:   in,
 		Profile: "gif",
 	}
 }
@@ -87,12 +155,19 @@ func LoadGifMeta(input string) *media.Media {
 func getMedia(cmd *cobra.Command) *media.Media {
 	var meta *media.Media
 	if cmd.Flags().Changed("input") {
-		meta = media.New(inName)
+		var err error
+		meta, err = media.NewContext(cmd.Context(), inName)
+		if err != nil {
+			log.Fatal(err)
+		}
 		meta.Profile = "gif"
 	}
 	if cmd.Flags().Changed("meta") {
 		if cmd.Flags().Changed("input") {
-			meta.LoadIni(metadata)
+			err := meta.LoadMeta(metadata)
+			if err != nil {
+				log.Fatal(err)
+			}
 		} else {
 			meta = LoadGifMeta(metadata)
 		}
@@ -125,9 +200,14 @@ func getChapters(cmd *cobra.Command, meta *media.Media) []*avtools.Chapter {
 		}
 
 		ch := &avtools.Chapter{
-			Title: fmt.Sprintf("%s-%s-%s", meta.Input.Name, start, end),
+			ChapTitle: fmt.Sprintf("%s-%s-%s", meta.Input.Name, start, end),
+		}
+		if err := ch.SS(start, meta.FrameRate()); err != nil {
+			log.Fatal(err)
+		}
+		if err := ch.To(end, meta.FrameRate()); err != nil {
+			log.Fatal(err)
 		}
-		ch.SS(start).To(end)
 		chapters = append(chapters, ch)
 	default:
 		if meta.HasChapters() {
@@ -247,13 +327,28 @@ func FilterFlag() ff.Filters {
 	return filters
 }
 
+// Execute runs yygif. Ctrl-C cancels the context of the commands, which
+// stops the ffmpeg and ffprobe they run: those are in process groups of
+// their own, so the terminal doesn't signal them. A second Ctrl-C exits at
+// once.
 func Execute() {
-	err := rootCmd.Execute()
+	err := rootCmd.ExecuteContext(signalContext())
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
+// signalContext is done on the first interrupt or SIGTERM, after which
+// they are no longer caught.
+func signalContext() context.Context {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 	mime.AddExtensionType(".ini", "text/plain")
@@ -282,6 +377,11 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&fps, "fps", "r", "", "")
 	rootCmd.PersistentFlags().StringVar(&setpts, "setpts", "", "")
 	rootCmd.PersistentFlags().BoolVar(&yadif, "yadif", false, "")
+	rootCmd.PersistentFlags().IntVarP(&batchOpts.Workers, "jobs", "j", runtime.NumCPU(), "gifs to make at once")
+	rootCmd.PersistentFlags().IntVar(&batchOpts.Retries, "retries", 0, "times to retry a failed gif")
+	rootCmd.PersistentFlags().BoolVar(&batchOpts.KeepGoing, "keep-going", false, "make the remaining gifs after one fails")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "print the ffmpeg commands instead of running them")
+	rootCmd.PersistentFlags().StringVar(&emitScript, "emit-script", "", "write the ffmpeg commands as a shell script instead of running them, - for stdout")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -289,9 +389,13 @@ func initConfig() {
 	home, err := os.UserHomeDir()
 	cobra.CheckErr(err)
 	path := filepath.Join(home, ".config/avtools/profiles.yml")
-	ff.ReadConfig(path)
+	cobra.CheckErr(ff.ReadConfig(path))
 	viper.SetConfigName("profiles")
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath(filepath.Join(home, ".config/avtools"))
+
+	if ff.IsTerminal(os.Stderr) {
+		ff.DefaultProgress = ff.Bar(os.Stderr)
+	}
 	//fmt.Printf("%+V\n", yygif.Profiles)
 }
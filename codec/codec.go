@@ -0,0 +1,196 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ohzqq/avtools"
+)
+
+// sniffLen is how much of a file is read to detect its format.
+const sniffLen = 4096
+
+// Format is a metadata format. Decode reads a file into Metaz; Encode writes
+// Meta as a sidecar file, Save writes it into a copy of a media file. A
+// format sets whichever of the two it supports.
+type Format struct {
+	Name string
+	// Exts are the extensions of the format, the first being the one
+	// sidecar files are written with.
+	Exts []string
+	// Suffix is appended to the name of sidecar files, for formats sharing
+	// an extension.
+	Suffix string
+	// Sniff reports whether the start of a file is in this format.
+	Sniff  func(head []byte) bool
+	Decode func(file string) (avtools.Metaz, error)
+	Encode func(meta avtools.Meta) ([]byte, error)
+	Save   func(input, output string, meta avtools.Meta) error
+	// Chapters is set for formats that hold nothing but chapters.
+	Chapters bool
+}
+
+var (
+	formats  []Format
+	fallback Format
+)
+
+// Register adds f, replacing a format of the same name. Formats are tried in
+// the order they are registered.
+func Register(f Format) {
+	for i, reg := range formats {
+		if reg.Name == f.Name {
+			formats[i] = f
+			return
+		}
+	}
+	formats = append(formats, f)
+}
+
+// RegisterFallback sets the format used for binary files no other format
+// recognizes, i.e. media files read by ffprobe.
+func RegisterFallback(f Format) {
+	fallback = f
+}
+
+// Formats lists the registered formats.
+func Formats() []Format {
+	return append([]Format(nil), formats...)
+}
+
+func Lookup(name string) (Format, bool) {
+	for _, f := range formats {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	if fallback.Name == name && name != "" {
+		return fallback, true
+	}
+	return Format{}, false
+}
+
+// Ext returns the extension sidecar files are written with.
+func (f Format) Ext() string {
+	if len(f.Exts) > 0 {
+		return f.Exts[0]
+	}
+	return ""
+}
+
+func (f Format) hasExt(ext string) bool {
+	for _, e := range f.Exts {
+		if strings.EqualFold(e, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Detect finds the format of file: by content first, then by extension,
+// preferring formats that can't be recognized by content. Binary files
+// nothing recognizes are left to the fallback.
+func Detect(file string) (Format, error) {
+	head, err := readHead(file)
+	if err != nil {
+		return Format{}, err
+	}
+	return detect(filepath.Ext(file), head)
+}
+
+func detect(ext string, head []byte) (Format, error) {
+	for _, f := range formats {
+		if f.Sniff != nil && f.Sniff(head) {
+			return f, nil
+		}
+	}
+	for _, f := range formats {
+		if f.Sniff == nil && f.hasExt(ext) {
+			return f, nil
+		}
+	}
+	for _, f := range formats {
+		if f.hasExt(ext) {
+			return f, nil
+		}
+	}
+	if fallback.Decode != nil && !isText(head) {
+		return fallback, nil
+	}
+	return Format{}, fmt.Errorf("codec: %w%s", avtools.ErrUnknownFormat, forExt(ext))
+}
+
+func forExt(ext string) string {
+	if ext == "" {
+		return ""
+	}
+	return " " + ext
+}
+
+func readHead(file string) ([]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// isText reports whether head is UTF-8 without NUL bytes, allowing for a
+// rune cut at the end.
+func isText(head []byte) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return false
+	}
+	for i := 0; i < utf8.UTFMax && i <= len(head); i++ {
+		if utf8.Valid(head[:len(head)-i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Decode detects the format of file and reads it.
+func Decode(file string) (avtools.Metaz, error) {
+	f, err := Detect(file)
+	if err != nil {
+		return nil, err
+	}
+	if f.Decode == nil {
+		return nil, fmt.Errorf("codec: %w: %s can't be read", avtools.ErrUnsupported, f.Name)
+	}
+	return f.Decode(file)
+}
+
+// Encode writes meta in the named format.
+func Encode(name string, meta avtools.Meta) ([]byte, error) {
+	f, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("codec: %w %q", avtools.ErrUnknownFormat, name)
+	}
+	if f.Encode == nil {
+		return nil, fmt.Errorf("codec: %w: %s can't be written as a file", avtools.ErrUnsupported, f.Name)
+	}
+	return f.Encode(meta)
+}
+
+// Saver returns the format that can write metadata straight into the media
+// file input, if any.
+func Saver(input string) (Format, bool) {
+	f, err := Detect(input)
+	if err != nil || f.Save == nil {
+		return Format{}, false
+	}
+	return f, true
+}
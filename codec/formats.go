@@ -0,0 +1,134 @@
+package codec
+
+import (
+	"path/filepath"
+
+	"github.com/ohzqq/avtools"
+	"github.com/ohzqq/avtools/cue"
+	"github.com/ohzqq/avtools/edl"
+	"github.com/ohzqq/avtools/ffmeta"
+	"github.com/ohzqq/avtools/id3"
+	"github.com/ohzqq/avtools/labels"
+	"github.com/ohzqq/avtools/matroska"
+	"github.com/ohzqq/avtools/mp4"
+	"github.com/ohzqq/avtools/podcast"
+	"github.com/ohzqq/avtools/probe"
+	"github.com/ohzqq/avtools/psc"
+	"github.com/ohzqq/avtools/vtt"
+	"github.com/ohzqq/avtools/youtube"
+)
+
+func init() {
+	// media files first, so their headers win over text sniffing
+	Register(Format{
+		Name:   "mp4",
+		Exts:   []string{".m4b", ".m4a", ".mp4", ".m4v", ".mov"},
+		Sniff:  mp4.Sniff,
+		Decode: mp4.Load,
+		Save:   mp4.Save,
+	})
+	Register(Format{
+		Name:   "id3",
+		Exts:   []string{".mp3"},
+		Sniff:  id3.Sniff,
+		Decode: id3.Load,
+		Save:   id3.Save,
+	})
+	Register(Format{
+		Name:   "ffmeta",
+		Exts:   []string{".ini", ".txt"},
+		Sniff:  ffmeta.Sniff,
+		Decode: ffmeta.Load,
+		Encode: ffmeta.Dump,
+	})
+	Register(Format{
+		Name:     "cue",
+		Exts:     []string{".cue"},
+		Sniff:    cue.Sniff,
+		Decode:   cue.Load,
+		Encode:   dumpCue,
+		Chapters: true,
+	})
+	Register(Format{
+		Name:     "mkv",
+		Exts:     []string{".xml"},
+		Sniff:    matroska.Sniff,
+		Decode:   matroska.Load,
+		Encode:   matroska.Dump,
+		Chapters: true,
+	})
+	Register(Format{
+		Name:     "psc",
+		Exts:     []string{".xml", ".json", ".psc"},
+		Suffix:   "-psc",
+		Sniff:    psc.Sniff,
+		Decode:   psc.Load,
+		Encode:   psc.Dump,
+		Chapters: true,
+	})
+	Register(Format{
+		Name:     "vtt",
+		Exts:     []string{".vtt"},
+		Sniff:    vtt.Sniff,
+		Decode:   vtt.Load,
+		Encode:   infallible(vtt.Dump),
+		Chapters: true,
+	})
+	Register(Format{
+		Name:     "podcast",
+		Exts:     []string{".json"},
+		Sniff:    podcast.Sniff,
+		Decode:   podcast.Load,
+		Encode:   podcast.Dump,
+		Chapters: true,
+	})
+	Register(Format{
+		Name:     "audacity",
+		Exts:     []string{".txt"},
+		Suffix:   "-labels",
+		Sniff:    labels.Sniff,
+		Decode:   labels.Load,
+		Encode:   infallible(labels.Dump),
+		Chapters: true,
+	})
+	Register(Format{
+		Name:     "reaper",
+		Exts:     []string{".csv"},
+		Suffix:   "-markers",
+		Sniff:    labels.SniffReaper,
+		Decode:   labels.Load,
+		Encode:   infallible(labels.DumpReaper),
+		Chapters: true,
+	})
+	Register(Format{
+		Name:     "youtube",
+		Exts:     []string{".txt"},
+		Suffix:   "-youtube",
+		Decode:   youtube.Load,
+		Encode:   infallible(youtube.Dump),
+		Chapters: true,
+	})
+	Register(Format{
+		Name:     "edl",
+		Exts:     []string{".edl"},
+		Encode:   infallible(edl.Dump),
+		Chapters: true,
+	})
+
+	RegisterFallback(Format{
+		Name:   "probe",
+		Decode: probe.Load,
+	})
+}
+
+// dumpCue writes a cue sheet for the file named by the filename tag.
+func dumpCue(meta avtools.Meta) ([]byte, error) {
+	return cue.Dump(filepath.Base(meta.Tags()["filename"]), meta.Chapters()), nil
+}
+
+// infallible adapts an encoder that can't fail to Format.Encode.
+func infallible(dump func(avtools.Meta) []byte) func(avtools.Meta) ([]byte, error) {
+	return func(meta avtools.Meta) ([]byte, error) {
+		return dump(meta), nil
+	}
+}
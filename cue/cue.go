@@ -4,31 +4,61 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
-	"html/template"
-	"log"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/ohzqq/avtools"
-	"github.com/ohzqq/dur"
 	"github.com/ohzqq/fidi"
 )
 
 type Sheet struct {
 	fidi.File
-	file   string
-	Ext    string
-	Tracks []avtools.ChapterMeta
-	Chaps  []*avtools.Chapter
+	Title      string
+	Performer  string
+	Songwriter string
+	Catalog    string
+	CDTextFile string
+	Rem        []Rem
+	// Extra are the disc commands the parser doesn't know, kept as they
+	// were written.
+	Extra []string
+	Files []*File
+}
+
+type File struct {
+	Name   string
+	Type   string
+	Tracks []*Track
+}
+
+// HTOA returns the hidden track one audio, the INDEX 00 pregap of the first
+// track of the file, when that track has one before its INDEX 01.
+func (f File) HTOA() (start, end Frames, ok bool) {
+	if len(f.Tracks) == 0 {
+		return 0, 0, false
+	}
+	t := f.Tracks[0]
+	start, ok = t.Index(0)
+	end, _ = t.Index(1)
+	if !ok || end <= start {
+		return 0, 0, false
+	}
+	return start, end, true
 }
 
 func Load(file string) (avtools.Metaz, error) {
+	src, err := avtools.NewFile(file)
+	if err != nil {
+		return nil, err
+	}
+
 	sheet := new(Sheet)
-	sheet.File = fidi.NewFile(file)
+	sheet.File = src
 	if err := avtools.IsPlainText(sheet.Mime); err != nil {
-		return sheet, fmt.Errorf("ini load err: %w", err)
+		return sheet, fmt.Errorf("cue load err: %w", err)
 	}
 
 	contents, err := os.Open(file)
@@ -37,102 +67,473 @@ func Load(file string) (avtools.Metaz, error) {
 	}
 	defer contents.Close()
 
-	var times []time.Duration
-	var titles []string
-	scanner := bufio.NewScanner(contents)
+	err = sheet.Parse(contents)
+	if err != nil {
+		return sheet, err
+	}
+
+	return sheet, nil
+}
+
+// Parse reads a cue sheet, keeping track of whether commands apply to the
+// whole disc or to the current TRACK.
+func (s *Sheet) Parse(r io.Reader) error {
+	var (
+		file  *File
+		track *Track
+		num   int
+	)
+
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
+		num++
 		line := strings.TrimSpace(scanner.Text())
-		switch {
-		case strings.Contains(line, "TITLE"):
-			title := strings.TrimPrefix(line, "TITLE ")
-			title = strings.Trim(title, "'")
-			title = strings.Trim(title, `"`)
-			titles = append(titles, title)
-		case strings.Contains(line, "INDEX 01"):
-			stamp := strings.TrimPrefix(line, "INDEX 01 ")
-			start, err := dur.Parse(stamp[:len(stamp)-3])
+		if num == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line == "" {
+			continue
+		}
+
+		fields := splitFields(line)
+		cmd := strings.ToUpper(fields[0])
+		args := fields[1:]
+		// text values are the rest of the line, quoted or not
+		val := unquote(rest(line))
+
+		lineErr := func(msg string) error {
+			return fmt.Errorf("cue: line %d: %s: %q", num, msg, line)
+		}
+
+		switch cmd {
+		case "REM":
+			if len(args) == 0 {
+				continue
+			}
+			rem := Rem{
+				Key:   strings.ToUpper(args[0]),
+				Value: unquote(rest(rest(line))),
+			}
+			if track != nil {
+				track.Rem = append(track.Rem, rem)
+			} else {
+				s.Rem = append(s.Rem, rem)
+			}
+		case "CATALOG":
+			if len(args) != 1 {
+				return lineErr("expected one argument")
+			}
+			s.Catalog = args[0]
+		case "CDTEXTFILE":
+			if len(args) == 0 {
+				return lineErr("missing file name")
+			}
+			s.CDTextFile = val
+		case "TITLE", "PERFORMER", "SONGWRITER":
+			if len(args) == 0 {
+				return lineErr("missing value")
+			}
+			if track != nil {
+				track.setField(cmd, val)
+			} else {
+				s.setField(cmd, val)
+			}
+		case "FILE":
+			if len(args) < 1 {
+				return lineErr("missing file name")
+			}
+			name, typ := fileArgs(rest(line))
+			file = &File{Name: name, Type: strings.ToUpper(typ)}
+			s.Files = append(s.Files, file)
+			track = nil
+		case "TRACK":
+			if file == nil {
+				return lineErr("TRACK before FILE")
+			}
+			if len(args) != 2 {
+				return lineErr("expected track number and type")
+			}
+			n, err := strconv.Atoi(args[0])
 			if err != nil {
-				return sheet, err
+				return lineErr("invalid track number")
+			}
+			track = NewTrack(n)
+			track.Type = strings.ToUpper(args[1])
+			track.file = file
+			file.Tracks = append(file.Tracks, track)
+		case "INDEX":
+			if track == nil {
+				return lineErr("INDEX outside of TRACK")
+			}
+			if len(args) != 2 {
+				return lineErr("expected index number and timestamp")
+			}
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return lineErr("invalid index number")
+			}
+			f, err := ParseFrames(args[1])
+			if err != nil {
+				return lineErr(err.Error())
+			}
+			track.SetIndex(n, f)
+		case "PREGAP", "POSTGAP":
+			if track == nil {
+				return lineErr(cmd + " outside of TRACK")
+			}
+			if len(args) != 1 {
+				return lineErr("expected one argument")
+			}
+			f, err := ParseFrames(args[0])
+			if err != nil {
+				return lineErr(err.Error())
+			}
+			if cmd == "PREGAP" {
+				track.Pregap = f
+			} else {
+				track.Postgap = f
+			}
+		case "ISRC":
+			if track == nil {
+				return lineErr("ISRC outside of TRACK")
+			}
+			if len(args) != 1 {
+				return lineErr("expected one argument")
+			}
+			track.ISRC = args[0]
+		case "FLAGS":
+			if track == nil {
+				return lineErr("FLAGS outside of TRACK")
+			}
+			track.Flags = args
+		default:
+			if track != nil {
+				track.Extra = append(track.Extra, line)
+			} else {
+				s.Extra = append(s.Extra, line)
 			}
-			times = append(times, start.Dur)
 		}
 	}
 
-	e := 1
-	for i := 0; i < len(titles); i++ {
-		var track Track
-		track.title = titles[i]
-		track.start = times[i]
-		if e < len(titles) {
-			track.end = times[e]
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	for _, f := range s.Files {
+		for _, t := range f.Tracks {
+			if _, ok := t.Index(1); !ok {
+				return fmt.Errorf("cue: track %d has no INDEX 01", t.Number)
+			}
 		}
-		e++
-		sheet.Tracks = append(sheet.Tracks, track)
 	}
+	s.setEnds()
 
-	return sheet, nil
+	return nil
 }
 
-func NewCueSheet(f string) *Sheet {
-	cue := &Sheet{
-		file: f,
-		Ext:  filepath.Ext(f),
+// setEnds sets the end of every track to the start of the next track in the
+// same FILE, so that a pregap is counted as part of the previous track.
+func (s *Sheet) setEnds() {
+	for _, f := range s.Files {
+		for i, t := range f.Tracks {
+			t.file = f
+			t.end = 0
+			if i+1 < len(f.Tracks) {
+				t.end, _ = f.Tracks[i+1].Index(1)
+			}
+		}
 	}
-	cue.Ext = strings.ToUpper(cue.Ext)
-	cue.Ext = strings.TrimPrefix(cue.Ext, ".")
-	return cue
 }
 
-func Dump(file string, chaps []*avtools.Chapter) []byte {
-	var (
-		tmpl = template.Must(template.New("cue").Funcs(tmplFuncs).Parse(cueTmpl))
-		buf  bytes.Buffer
-	)
+// FromChapters creates a single FILE cue sheet for the given media file.
+// Performer and songwriter chapter tags become track commands. A first
+// chapter tagged htoa, as Sheet.Chapters makes the hidden track one audio,
+// becomes the INDEX 00 of the first track again.
+func FromChapters(file string, chaps []*avtools.Chapter) *Sheet {
+	f := &File{
+		Name: file,
+		Type: FileType(file),
+	}
 
-	cue := NewCueSheet(file)
-	cue.Chaps = chaps
+	var gap *avtools.Stamp
+	for idx, ch := range chaps {
+		ss, _ := ch.Stamps()
+		if idx == 0 && ch.Tags["htoa"] != "" && len(chaps) > 1 {
+			gap = &ss
+			continue
+		}
 
-	err := tmpl.Execute(&buf, cue)
-	if err != nil {
-		log.Fatal(err)
+		num := len(f.Tracks) + 1
+		t := NewTrack(num)
+		t.TrackTitle = ch.Title()
+		if t.TrackTitle == "" {
+			t.TrackTitle = fmt.Sprintf("Chapter %d", num)
+		}
+		t.Performer = ch.Tags["performer"]
+		t.Songwriter = ch.Tags["songwriter"]
+		t.ISRC = ch.Tags["isrc"]
+		if gap != nil {
+			t.SetIndex(0, StampFrames(*gap))
+			gap = nil
+		}
+		t.SetIndex(1, StampFrames(ss))
+		f.Tracks = append(f.Tracks, t)
 	}
 
+	sheet := &Sheet{Files: []*File{f}}
+	sheet.setEnds()
+
+	return sheet
+}
+
+func Dump(file string, chaps []*avtools.Chapter) []byte {
+	return FromChapters(file, chaps).Dump()
+}
+
+func (s Sheet) Dump() []byte {
+	var buf bytes.Buffer
+	s.Write(&buf)
 	return buf.Bytes()
 }
 
-func (cue Sheet) Chapters() []avtools.ChapterMeta {
-	return cue.Tracks
+func (s Sheet) Write(wr io.Writer) error {
+	w := bufio.NewWriter(wr)
+
+	for _, rem := range s.Rem {
+		writeRem(w, "", rem)
+	}
+	if s.Catalog != "" {
+		fmt.Fprintf(w, "CATALOG %s\n", s.Catalog)
+	}
+	if s.CDTextFile != "" {
+		fmt.Fprintf(w, "CDTEXTFILE %s\n", quote(s.CDTextFile))
+	}
+	writeString(w, "", "PERFORMER", s.Performer)
+	writeString(w, "", "SONGWRITER", s.Songwriter)
+	writeString(w, "", "TITLE", s.Title)
+	for _, line := range s.Extra {
+		fmt.Fprintln(w, line)
+	}
+
+	for _, f := range s.Files {
+		fmt.Fprintf(w, "FILE %s %s\n", quote(f.Name), f.Type)
+		for _, t := range f.Tracks {
+			fmt.Fprintf(w, "  TRACK %02d %s\n", t.Number, t.Type)
+			writeString(w, "    ", "TITLE", t.TrackTitle)
+			writeString(w, "    ", "PERFORMER", t.Performer)
+			writeString(w, "    ", "SONGWRITER", t.Songwriter)
+			for _, rem := range t.Rem {
+				writeRem(w, "    ", rem)
+			}
+			for _, line := range t.Extra {
+				fmt.Fprintf(w, "    %s\n", line)
+			}
+			if len(t.Flags) > 0 {
+				fmt.Fprintf(w, "    FLAGS %s\n", strings.Join(t.Flags, " "))
+			}
+			if t.ISRC != "" {
+				fmt.Fprintf(w, "    ISRC %s\n", t.ISRC)
+			}
+			if t.Pregap > 0 {
+				fmt.Fprintf(w, "    PREGAP %s\n", t.Pregap)
+			}
+			for _, idx := range t.Indices {
+				fmt.Fprintf(w, "    INDEX %02d %s\n", idx.Number, idx.Frames)
+			}
+			if t.Postgap > 0 {
+				fmt.Fprintf(w, "    POSTGAP %s\n", t.Postgap)
+			}
+		}
+	}
+
+	return w.Flush()
+}
+
+// Tracks returns the tracks of every FILE in order.
+func (s Sheet) Tracks() []*Track {
+	var tracks []*Track
+	for _, f := range s.Files {
+		tracks = append(tracks, f.Tracks...)
+	}
+	return tracks
 }
 
-func (cue Sheet) Tags() map[string]string {
-	return map[string]string{
-		"filename": cue.file,
+// Chapters returns the tracks, each FILE's preceded by its hidden track one
+// audio, see File.HTOA, titled HTOATitle and tagged htoa.
+func (s Sheet) Chapters() []avtools.ChapterMeta {
+	var chaps []avtools.ChapterMeta
+	for _, f := range s.Files {
+		if start, end, ok := f.HTOA(); ok {
+			chaps = append(chaps, htoa{start: start, end: end})
+		}
+		for _, t := range f.Tracks {
+			chaps = append(chaps, t)
+		}
 	}
+	return chaps
 }
 
-func (cue Sheet) Streams() []map[string]string {
+func (s Sheet) Tags() map[string]string {
+	tags := make(map[string]string)
+	if len(s.Files) > 0 {
+		tags["filename"] = s.Files[0].Name
+	}
+	if s.Title != "" {
+		tags["album"] = s.Title
+	}
+	if s.Performer != "" {
+		tags["album_artist"] = s.Performer
+		tags["artist"] = s.Performer
+	}
+	if s.Songwriter != "" {
+		tags["composer"] = s.Songwriter
+	}
+	for _, rem := range s.Rem {
+		switch rem.Key {
+		case "GENRE", "DATE", "COMMENT":
+			tags[strings.ToLower(rem.Key)] = rem.Value
+		}
+	}
+	return tags
+}
+
+func (s Sheet) Streams() []map[string]string {
 	return []map[string]string{}
 }
 
-func (cue Sheet) Source() fidi.File {
-	return cue.File
+func (s Sheet) Source() fidi.File {
+	return s.File
+}
+
+// FileType guesses the FILE type from a media file's extension.
+func FileType(name string) string {
+	ext := strings.ToUpper(strings.TrimPrefix(filepath.Ext(name), "."))
+	switch ext {
+	case "WAV", "FLAC", "APE", "WV":
+		return "WAVE"
+	case "AIF", "AIFF":
+		return "AIFF"
+	case "MP3":
+		return "MP3"
+	case "BIN":
+		return "BINARY"
+	}
+	return ext
+}
+
+func (s *Sheet) setField(cmd, val string) {
+	switch cmd {
+	case "TITLE":
+		s.Title = val
+	case "PERFORMER":
+		s.Performer = val
+	case "SONGWRITER":
+		s.Songwriter = val
+	}
+}
+
+func (t *Track) setField(cmd, val string) {
+	switch cmd {
+	case "TITLE":
+		t.TrackTitle = val
+	case "PERFORMER":
+		t.Performer = val
+	case "SONGWRITER":
+		t.Songwriter = val
+	}
+}
+
+func writeString(w io.Writer, indent, cmd, val string) {
+	if val != "" {
+		fmt.Fprintf(w, "%s%s %s\n", indent, cmd, quote(val))
+	}
+}
+
+func writeRem(w io.Writer, indent string, rem Rem) {
+	val := rem.Value
+	if strings.ContainsAny(val, " \t") || strings.HasPrefix(val, `"`) {
+		val = quote(val)
+	}
+	fmt.Fprintf(w, "%sREM %s %s\n", indent, rem.Key, val)
+}
+
+// quote wraps s in double quotes. Cue sheets can't escape quotes, but
+// values are read to the end of the line, so ones inside s are kept.
+func quote(s string) string {
+	return `"` + s + `"`
+}
+
+// unquote strips the double quotes around s.
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
 }
 
-var tmplFuncs = template.FuncMap{
-	"inc": Inc,
+// rest is line without its first field.
+func rest(line string) string {
+	i := strings.IndexAny(line, " \t")
+	if i < 0 {
+		return ""
+	}
+	return strings.TrimSpace(line[i:])
 }
 
-func Inc(n int) int {
-	return n + 1
+// fileArgs splits the arguments of FILE into the file name, quoted or not,
+// and the type after it.
+func fileArgs(args string) (name, typ string) {
+	i := strings.LastIndexAny(args, " \t")
+	if i < 0 || strings.Contains(args[i+1:], `"`) {
+		return unquote(args), ""
+	}
+	return unquote(strings.TrimSpace(args[:i])), args[i+1:]
 }
 
-const cueTmpl = `FILE "{{.File}}" {{.Ext -}}
-{{range $idx, $ch := .Chaps}}
-TRACK {{inc $idx}} AUDIO
-{{- if eq $ch.Title ""}}
-  TITLE "Chapter {{inc $idx}}"
-{{- else}}
-  TITLE "{{$ch.Title}}"
-{{- end}}
-  INDEX 01 {{$ch.Start.MMSS}}:00
-{{- end -}}`
+// splitFields splits a cue line on whitespace, keeping double quoted
+// strings together.
+func splitFields(line string) []string {
+	var (
+		fields []string
+		field  strings.Builder
+		quoted bool
+		isSet  bool
+	)
+
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			isSet = true
+		case !quoted && (r == ' ' || r == '\t'):
+			if isSet {
+				fields = append(fields, field.String())
+				field.Reset()
+				isSet = false
+			}
+		default:
+			field.WriteRune(r)
+			isSet = true
+		}
+	}
+	if isSet {
+		fields = append(fields, field.String())
+	}
+
+	return fields
+}
+
+// Sniff reports whether data starts with a disc scope cue command and holds
+// a TRACK.
+func Sniff(data []byte) bool {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	first := strings.Fields(strings.TrimSpace(text))
+	if len(first) == 0 {
+		return false
+	}
+	switch strings.ToUpper(first[0]) {
+	case "REM", "FILE", "TITLE", "PERFORMER", "SONGWRITER", "CATALOG", "CDTEXTFILE":
+		return strings.Contains(text, "TRACK ")
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package cue
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ohzqq/avtools"
+)
+
+const sheet = `REM GENRE "Spoken Word"
+REM DATE 2020
+CATALOG 0123456789012
+PERFORMER "The "Real" Author"
+TITLE "A Book: Part One"
+ARRANGER "Someone"
+FILE "book 1.flac" WAVE
+  TRACK 01 AUDIO
+    TITLE "Chapter "One""
+    PERFORMER "Reader"
+    REM COMPOSER "Some Body"
+    FOO bar baz
+    FLAGS DCP
+    ISRC ABCDE1234567
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    TITLE "Chapter Two"
+    PREGAP 00:02:00
+    INDEX 00 04:58:00
+    INDEX 01 05:00:37
+`
+
+func parse(t *testing.T, data string) *Sheet {
+	t.Helper()
+	s := new(Sheet)
+	err := s.Parse(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestRoundTrip(t *testing.T) {
+	s := parse(t, sheet)
+	if got := string(s.Dump()); got != sheet {
+		t.Errorf("dump\n got:\n%s\nwant:\n%s", got, sheet)
+	}
+
+	if s.Performer != `The "Real" Author` {
+		t.Errorf("performer %q", s.Performer)
+	}
+	tracks := s.Tracks()
+	if len(tracks) != 2 {
+		t.Fatalf("got %d tracks, want 2", len(tracks))
+	}
+	if tracks[0].Title() != `Chapter "One"` {
+		t.Errorf("title %q", tracks[0].Title())
+	}
+	want := 5*time.Minute + 37*time.Second/75
+	if tracks[0].End() != want || tracks[1].Start() != want {
+		t.Errorf("track 1 ends %s, track 2 starts %s, want %s", tracks[0].End(), tracks[1].Start(), want)
+	}
+}
+
+func TestUnquoted(t *testing.T) {
+	s := parse(t, `TITLE A Book With Spaces
+REM COMMENT just some words
+FILE book.mp3 MP3
+  TRACK 01 AUDIO
+    TITLE First Chapter
+    INDEX 01 00:00:00
+`)
+	if s.Title != "A Book With Spaces" {
+		t.Errorf("title %q", s.Title)
+	}
+	if len(s.Rem) != 1 || s.Rem[0].Value != "just some words" {
+		t.Errorf("rem %q", s.Rem)
+	}
+	if got := s.Tracks()[0].Title(); got != "First Chapter" {
+		t.Errorf("track title %q", got)
+	}
+	if f := s.Files[0]; f.Name != "book.mp3" || f.Type != "MP3" {
+		t.Errorf("file %q %q", f.Name, f.Type)
+	}
+}
+
+const hidden = `TITLE "Album"
+FILE "album.flac" WAVE
+  TRACK 01 AUDIO
+    TITLE "First"
+    INDEX 00 00:00:00
+    INDEX 01 00:32:00
+  TRACK 02 AUDIO
+    TITLE "Second"
+    INDEX 00 02:58:00
+    INDEX 01 03:00:00
+`
+
+func TestHTOA(t *testing.T) {
+	s := parse(t, hidden)
+	start, end, ok := s.Files[0].HTOA()
+	if !ok || start != 0 || end != 32*FramesPerSecond {
+		t.Fatalf("htoa %s-%s %v", start, end, ok)
+	}
+
+	chaps := s.Chapters()
+	want := []struct {
+		title      string
+		start, end time.Duration
+	}{
+		{HTOATitle, 0, 32 * time.Second},
+		{"First", 32 * time.Second, 3 * time.Minute},
+		{"Second", 3 * time.Minute, 0},
+	}
+	if len(chaps) != len(want) {
+		t.Fatalf("got %d chapters, want %d", len(chaps), len(want))
+	}
+	for i, w := range want {
+		c := chaps[i]
+		if c.Title() != w.title || c.Start() != w.start || c.End() != w.end {
+			t.Errorf("chapter %d: got %q %s-%s, want %q %s-%s", i+1,
+				c.Title(), c.Start(), c.End(), w.title, w.start, w.end)
+		}
+	}
+
+	// the hidden track is the first track's INDEX 00 again
+	got := string(Dump("album.flac", avtools.NewChapters(chaps)))
+	wantSheet := `FILE "album.flac" WAVE
+  TRACK 01 AUDIO
+    TITLE "First"
+    INDEX 00 00:00:00
+    INDEX 01 00:32:00
+  TRACK 02 AUDIO
+    TITLE "Second"
+    INDEX 01 03:00:00
+`
+	if got != wantSheet {
+		t.Errorf("dump\n got:\n%s\nwant:\n%s", got, wantSheet)
+	}
+
+	// without INDEX 00 before INDEX 01 there's no hidden track
+	s = parse(t, sheet)
+	if _, _, ok := s.Files[0].HTOA(); ok || len(s.Chapters()) != 2 {
+		t.Errorf("htoa in %v", s.Chapters())
+	}
+}
@@ -1,19 +1,198 @@
 package cue
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ohzqq/avtools"
+)
+
+// Frames is a cue sheet timestamp, counted in CD frames of 1/75 second.
+type Frames int
+
+const FramesPerSecond = 75
 
 type Track struct {
-	start time.Duration
-	end   time.Duration
-	title string
+	Number     int
+	Type       string
+	TrackTitle string
+	Performer  string
+	Songwriter string
+	ISRC       string
+	Flags      []string
+	Pregap     Frames
+	Postgap    Frames
+	Indices    []Index
+	Rem        []Rem
+	// Extra are the track commands the parser doesn't know, kept as they
+	// were written.
+	Extra []string
+	file  *File
+	end   Frames
+}
+
+// HTOATitle is the title of a hidden track one audio.
+const HTOATitle = "Hidden Track"
+
+// htoa is a hidden track one audio as a chapter.
+type htoa struct {
+	start, end Frames
+}
+
+func (h htoa) Start() time.Duration {
+	return h.start.Duration()
+}
+
+func (h htoa) End() time.Duration {
+	return h.end.Duration()
+}
+
+func (h htoa) Stamps() (start, end avtools.Stamp) {
+	return h.start.Stamp(), h.end.Stamp()
+}
+
+func (h htoa) Title() string {
+	return HTOATitle
+}
+
+func (h htoa) Tags() map[string]string {
+	return map[string]string{"htoa": "true"}
+}
+
+type Index struct {
+	Number int
+	Frames Frames
+}
+
+type Rem struct {
+	Key   string
+	Value string
+}
+
+func NewTrack(num int) *Track {
+	return &Track{
+		Number: num,
+		Type:   "AUDIO",
+	}
 }
 
 func (t Track) Start() time.Duration {
-	return t.start
+	if idx, ok := t.Index(1); ok {
+		return idx.Duration()
+	}
+	return 0
 }
+
 func (t Track) End() time.Duration {
-	return t.end
+	return t.end.Duration()
 }
+
+// Stamps returns INDEX 01 and the track end in CD frames.
+func (t Track) Stamps() (start, end avtools.Stamp) {
+	ss, _ := t.Index(1)
+	return ss.Stamp(), t.end.Stamp()
+}
+
 func (t Track) Title() string {
-	return t.title
+	return t.TrackTitle
+}
+
+func (t Track) Tags() map[string]string {
+	tags := make(map[string]string)
+	if t.Performer != "" {
+		tags["performer"] = t.Performer
+	}
+	if t.Songwriter != "" {
+		tags["songwriter"] = t.Songwriter
+	}
+	if t.ISRC != "" {
+		tags["isrc"] = t.ISRC
+	}
+	return tags
+}
+
+// Index returns the INDEX with the given number, 0 being the pregap and 1
+// the start of the track proper.
+func (t Track) Index(num int) (Frames, bool) {
+	for _, idx := range t.Indices {
+		if idx.Number == num {
+			return idx.Frames, true
+		}
+	}
+	return 0, false
+}
+
+func (t *Track) SetIndex(num int, f Frames) *Track {
+	for i, idx := range t.Indices {
+		if idx.Number == num {
+			t.Indices[i].Frames = f
+			return t
+		}
+	}
+	t.Indices = append(t.Indices, Index{Number: num, Frames: f})
+	return t
+}
+
+// EndFrames is the start of the next track in the same FILE, or 0 if this
+// is the last one.
+func (t Track) EndFrames() Frames {
+	return t.end
+}
+
+// File is the name of the FILE block the track belongs to.
+func (t Track) File() string {
+	if t.file != nil {
+		return t.file.Name
+	}
+	return ""
+}
+
+func NewFrames(d time.Duration) Frames {
+	return StampFrames(avtools.StampOf(d))
+}
+
+// StampFrames converts s to the nearest CD frame.
+func StampFrames(s avtools.Stamp) Frames {
+	return Frames(s.Rescale(avtools.CDFrame, avtools.RoundNearest).Ticks)
+}
+
+// ParseFrames parses a MM:SS:FF cue timestamp.
+func ParseFrames(stamp string) (Frames, error) {
+	split := strings.Split(stamp, ":")
+	if len(split) != 3 {
+		return 0, fmt.Errorf("cue: %w %q", avtools.ErrInvalidTimestamp, stamp)
+	}
+
+	var n [3]int
+	for i, s := range split {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("cue: %w %q", avtools.ErrInvalidTimestamp, stamp)
+		}
+		n[i] = v
+	}
+
+	if n[1] > 59 || n[2] >= FramesPerSecond {
+		return 0, fmt.Errorf("cue: %w %q", avtools.ErrInvalidTimestamp, stamp)
+	}
+
+	return Frames((n[0]*60+n[1])*FramesPerSecond + n[2]), nil
+}
+
+func (f Frames) Stamp() avtools.Stamp {
+	return avtools.NewStamp(int64(f), avtools.CDFrame)
+}
+
+func (f Frames) Duration() time.Duration {
+	return f.Stamp().Duration()
+}
+
+func (f Frames) String() string {
+	return f.Stamp().Cue()
+}
+
+func (i Index) Duration() time.Duration {
+	return i.Frames.Duration()
 }
@@ -0,0 +1,60 @@
+package edl
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ohzqq/avtools"
+)
+
+// DefaultRate is the frame rate of timecodes for media without video.
+var DefaultRate = avtools.Rate{Num: 30, Den: 1}
+
+// Dump writes the chapters of meta as a CMX 3600 edit decision list, one
+// cut per chapter, for importing as markers or a rough cut into an NLE.
+// Timecodes are at the frame rate of the video, drop-frame at 29.97 and
+// 59.94 fps.
+func Dump(meta avtools.Meta) []byte {
+	rate, video := avtools.StreamRate(meta.Streams())
+	if !video {
+		rate = DefaultRate
+	}
+	drop := rate.DropFrame()
+
+	tags := meta.Tags()
+	title := tags["title"]
+	if title == "" {
+		name := filepath.Base(tags["filename"])
+		title = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "TITLE: %s\n", title)
+	if drop {
+		buf.WriteString("FCM: DROP FRAME\n")
+	} else {
+		buf.WriteString("FCM: NON-DROP FRAME\n")
+	}
+
+	track := "A"
+	if video {
+		track = "V"
+	}
+
+	for i, ch := range meta.Chapters() {
+		ss, to := ch.Stamps()
+		in := ss.Timecode(rate, drop)
+		out := to.Timecode(rate, drop)
+		fmt.Fprintf(&buf, "\n%03d  AX       %-5s C        %s %s %s %s\n", i+1, track, in, out, in, out)
+
+		name := ch.Title()
+		if name == "" {
+			name = fmt.Sprintf("Chapter %d", i+1)
+		}
+		fmt.Fprintf(&buf, "* FROM CLIP NAME: %s\n", name)
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,56 @@
+package avtools
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ohzqq/fidi"
+)
+
+var (
+	ErrNotFFMeta        = errors.New("not an ffmetadata file")
+	ErrNoChapters       = errors.New("no chapters")
+	ErrChapterRange     = errors.New("chapter out of range")
+	ErrInvalidChapters  = errors.New("invalid chapters")
+	ErrInvalidTimestamp = errors.New("invalid timestamp")
+	ErrUnknownFormat    = errors.New("unknown metadata format")
+	ErrUnsupported      = errors.New("unsupported operation")
+	ErrProbeFailed      = errors.New("ffprobe failed")
+	ErrCanceled         = errors.New("canceled")
+	ErrNotPlainText     = errors.New("not a plain text file")
+)
+
+// ProbeError is returned when ffprobe fails or its output can't be read.
+// It matches ErrProbeFailed.
+type ProbeError struct {
+	Input  string
+	Stderr string
+	Err    error
+}
+
+func (e *ProbeError) Error() string {
+	msg := fmt.Sprintf("%s %s: %v", ErrProbeFailed, e.Input, e.Err)
+	if s := strings.TrimSpace(e.Stderr); s != "" {
+		msg += ": " + s
+	}
+	return msg
+}
+
+func (e *ProbeError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ProbeError) Is(target error) bool {
+	return target == ErrProbeFailed
+}
+
+// NewFile is fidi.NewFile returning the error of a missing or unreadable
+// file instead of exiting.
+func NewFile(name string) (fidi.File, error) {
+	if _, err := os.Stat(name); err != nil {
+		return fidi.File{}, err
+	}
+	return fidi.NewFile(name), nil
+}
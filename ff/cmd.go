@@ -2,18 +2,69 @@ package ff
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"os/exec"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ohzqq/avtools"
 
 	ffmpeg "github.com/u2takey/ffmpeg-go"
 )
 
+// Cmd is an ffmpeg command. The embedded Input and Output are the first
+// ones, numbered 0; Inputs and Outputs are those after them.
 type Cmd struct {
 	Filters Filters `yaml:"filters"`
 	Output
 	Input
-	args []string
-	cmd  *exec.Cmd
+	Inputs  []*Input  `yaml:"inputs,omitempty"`
+	Outputs []*Output `yaml:"outputs,omitempty"`
+	// Runner runs the command, DefaultRunner when nil.
+	Runner Runner `yaml:"-"`
+	// OnProgress is called with the progress of the command while it runs,
+	// DefaultProgress when nil. Duration is the expected length of the
+	// output, for the percent done.
+	OnProgress func(Progress) `yaml:"-"`
+	Duration   time.Duration  `yaml:"-"`
+	args       []string
+	layout     Layout
+}
+
+// Layout is a compiled command as its parts: each input and output with
+// its flags and file, and the filter graph.
+type Layout struct {
+	Inputs  []Part `json:"inputs" yaml:"inputs"`
+	Filters string `json:"filter_complex,omitempty" yaml:"filter_complex,omitempty"`
+	Outputs []Part `json:"outputs" yaml:"outputs"`
+}
+
+// Part is an input or output of a command.
+type Part struct {
+	File  string   `json:"file" yaml:"file"`
+	Role  Role     `json:"role,omitempty" yaml:"role,omitempty"`
+	Flags []string `json:"flags,omitempty" yaml:"flags,omitempty"`
+}
+
+// Args are the arguments to ffmpeg of the layout.
+func (l Layout) Args() []string {
+	var args []string
+	for _, in := range l.Inputs {
+		args = append(args, in.Flags...)
+		args = append(args, "-i", in.File)
+	}
+	if l.Filters != "" {
+		args = append(args, "-filter_complex", l.Filters)
+	}
+	for _, out := range l.Outputs {
+		args = append(args, out.Flags...)
+		args = append(args, out.File)
+	}
+	return args
 }
 
 func New(profile ...string) Cmd {
@@ -33,72 +84,259 @@ func (cmd *Cmd) In(file string, args ...ffmpeg.KwArgs) *Cmd {
 	return cmd
 }
 
+// AddInput adds an input for role, returning its index for stream
+// selectors like "1:s".
+func (cmd *Cmd) AddInput(file string, role Role, args ...ffmpeg.KwArgs) int {
+	cmd.Inputs = append(cmd.Inputs, &Input{
+		File: file,
+		Args: ffmpeg.MergeKwArgs(args),
+		Role: role,
+	})
+	return len(cmd.Inputs)
+}
+
+// AddOutput adds an output after the first.
+func (cmd *Cmd) AddOutput(args ...ffmpeg.KwArgs) *Output {
+	out := NewOutput(args...)
+	cmd.Outputs = append(cmd.Outputs, &out)
+	return &out
+}
+
+// Compile builds the arguments to ffmpeg: the inputs in order, the filter
+// graph of the first and the outputs. Outputs without maps of their own get
+// the covers, marked as attached pictures, then the filtered streams, or the
+// first input, and the streams of the media and subtitle inputs added after
+// it; unless they say otherwise their tags and chapters come from the last
+// metadata input.
 func (cmd *Cmd) Compile() *Cmd {
-	input := NewInput(cmd.Input.Args)
-	in := input.Compile(cmd.File)
-	inArgs := len(in.GetArgs())
+	var layout Layout
+
+	first := NewInput(cmd.Input.Args)
+	first.File = cmd.File
+	first.Role = Media
+	layout.Inputs = append(layout.Inputs, first.part())
 
-	for _, filter := range cmd.Filters.Compile() {
-		in = filter(in)
+	var (
+		maps   []string
+		covers []string
+		meta   string
+	)
+	for i, in := range cmd.Inputs {
+		idx := strconv.Itoa(i + 1)
+		layout.Inputs = append(layout.Inputs, in.part())
+		switch in.Role {
+		case Metadata:
+			meta = idx
+		case Cover:
+			covers = append(covers, idx)
+		default:
+			maps = append(maps, idx)
+		}
 	}
-	fArgs := len(in.GetArgs()[inArgs:])
 
-	output := cmd.Output.Compile(in)
+	graph, labels := cmd.filterGraph()
+	layout.Filters = graph
 
-	outArgs := inArgs
-	if fArgs > 0 {
-		fArgs = fArgs + 2
-		outArgs = outArgs + fArgs
+	for i, out := range cmd.outputs() {
+		o := Output{Args: out.Args.Copy()}
+		if len(o.Maps()) == 0 {
+			// covers go first, so cover n is the video stream v:n of the
+			// output
+			for n, idx := range covers {
+				o.Map(idx + ":v:0")
+				o.Disposition("v:"+strconv.Itoa(n), "attached_pic")
+			}
+			switch {
+			// a filter output can only go to one output
+			case i == 0 && len(labels) > 0:
+				o.Map(labels...)
+			case len(maps) > 0 || len(covers) > 0:
+				o.Map("0")
+			}
+			if len(maps) > 0 {
+				o.Map(maps...)
+			}
+		}
+		if meta != "" {
+			if o.Get("map_metadata") == nil {
+				o.MapMetadata(meta)
+			}
+			if o.Get("map_chapters") == nil {
+				o.MapChapters(meta)
+			}
+		}
+		layout.Outputs = append(layout.Outputs, o.part())
 	}
 
-	//output.Compile()
+	cmd.layout = layout
+	cmd.args = layout.Args()
+	return cmd
+}
 
-	ffArgs := output.GetArgs()
+// toStdout reports whether an output is written to stdout or a pipe.
+func (cmd Cmd) toStdout() bool {
+	for _, out := range cmd.outputs() {
+		name := out.String()
+		if name == "-" || strings.HasPrefix(name, "pipe:") {
+			return true
+		}
+	}
+	return false
+}
 
-	cmd.args = append(cmd.args, ffArgs[:inArgs]...)
+func (cmd *Cmd) outputs() []*Output {
+	return append([]*Output{&cmd.Output}, cmd.Outputs...)
+}
 
-	if meta, ok := cmd.Input.Args["meta"]; ok {
-		cmd.args = append(cmd.args, "-i", meta.(string))
+// filterGraph is the filter graph of the first input, with the labels of its
+// outputs.
+func (cmd *Cmd) filterGraph() (string, []string) {
+	filters := cmd.Filters.Compile()
+	if len(filters) == 0 {
+		return "", nil
 	}
 
-	cmd.args = append(cmd.args, ffArgs[inArgs:outArgs]...)
+	s := ffmpeg.Input(cmd.File)
+	for _, filter := range filters {
+		s = filter(s)
+	}
 
-	if label, ok := cmd.Input.Args["map_metadata"]; ok {
-		cmd.args = append(cmd.args, "-map_metadata", label.(string))
+	var (
+		graph  string
+		labels []string
+	)
+	args := s.Output("out").GetArgs()
+	for i := 0; i+1 < len(args); i++ {
+		switch args[i] {
+		case "-filter_complex":
+			graph = args[i+1]
+		case "-map":
+			labels = append(labels, args[i+1])
+		}
 	}
+	return graph, labels
+}
 
-	if label, ok := cmd.Input.Args["map_chapters"]; ok {
-		cmd.args = append(cmd.args, "-map_chapters", label.(string))
+// kwargs are args as ffmpeg flags, led by the keys in first in order, which
+// ffmpeg-go names differently from the flags.
+func kwargs(args ffmpeg.KwArgs, first ...string) []string {
+	rest := args.Copy()
+	var flags []string
+	for _, key := range first {
+		if val := rest.PopString(key); val != "" {
+			flags = append(flags, "-"+flagNames[key], val)
+		}
 	}
+	return append(flags, ffmpeg.ConvertKwargsToCmdLineArgs(rest)...)
+}
 
-	cmd.args = append(cmd.args, ffArgs[outArgs:]...)
+var flagNames = map[string]string{
+	"map_metadata":  "map_metadata",
+	"map_chapters":  "map_chapters",
+	"format":        "f",
+	"video_size":    "video_size",
+	"video_bitrate": "b:v",
+	"audio_bitrate": "b:a",
+}
 
-	cmd.cmd = exec.Command("ffmpeg", cmd.args...)
-	fmt.Printf("args %+V\n", cmd.args)
+// Args are the arguments to ffmpeg, once compiled.
+func (c Cmd) Args() []string {
+	return c.args
+}
 
-	return cmd
+// Layout is the compiled command as its parts.
+func (c Cmd) Layout() Layout {
+	return c.layout
 }
 
 func (c Cmd) String() string {
-	if c.cmd != nil {
-		return c.cmd.String()
+	if c.args == nil {
+		return ""
 	}
-	return ""
+	return strings.Join(append([]string{"ffmpeg"}, c.args...), " ")
 }
 
 func (c Cmd) Run() error {
+	return c.RunContext(context.Background())
+}
+
+// RunContext runs the command, stopping ffmpeg when ctx is done. The output
+// file of a stopped run is removed unless it was there before, and the
+// error matches avtools.ErrCanceled.
+func (c Cmd) RunContext(ctx context.Context) error {
 	var (
 		stderr bytes.Buffer
 		stdout bytes.Buffer
 	)
 
-	c.cmd.Stderr = &stderr
-	c.cmd.Stdout = &stdout
+	runner := c.Runner
+	if runner == nil {
+		runner = DefaultRunner
+	}
+
+	if c.IsVerbose() {
+		fmt.Fprintln(os.Stderr, c.String())
+	}
 
-	println(c.cmd.String())
-	err := c.cmd.Run()
+	call := Call{
+		Name:   "ffmpeg",
+		Args:   c.args,
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+
+	progress := c.OnProgress
+	if progress == nil {
+		progress = DefaultProgress
+	}
+	// progress is read from stdout, so it is left to media written there,
+	// unbuffered
+	if c.toStdout() {
+		progress = nil
+		call.Stdout = os.Stdout
+	}
+	var (
+		parsed chan error
+		pw     *io.PipeWriter
+	)
+	if progress != nil {
+		var pr *io.PipeReader
+		pr, pw = io.Pipe()
+		call.Args = append([]string{"-progress", "pipe:1", "-nostats"}, c.args...)
+		call.Stdout = pw
+		parsed = make(chan error, 1)
+		go func() {
+			err := ParseProgress(pr, c.Duration, progress)
+			// drain, so ffmpeg isn't blocked if parsing failed
+			io.Copy(io.Discard, pr)
+			parsed <- err
+		}()
+	}
+
+	outs := c.outputs()
+	before := make([]os.FileInfo, len(outs))
+	for i, out := range outs {
+		before[i], _ = os.Stat(out.String())
+	}
+
+	err := runner.Run(ctx, call)
+	if pw != nil {
+		pw.Close()
+		<-parsed
+	}
+	if errors.Is(err, avtools.ErrCanceled) {
+		// a file ffmpeg started overwriting is as partial as a new one
+		for i, out := range outs {
+			after, err := os.Stat(out.String())
+			if err == nil && after.Mode().IsRegular() &&
+				(before[i] == nil || !after.ModTime().Equal(before[i].ModTime())) {
+				os.Remove(out.String())
+			}
+		}
+		return fmt.Errorf("%s: %w", c.String(), err)
+	}
 	if err != nil {
-		return fmt.Errorf("%v\n%v\n", stderr.String(), c.cmd.String())
+		return fmt.Errorf("%s: %w\n%s", c.String(), err, strings.TrimSpace(stderr.String()))
 	}
 
 	if len(stdout.Bytes()) > 0 {
@@ -0,0 +1,42 @@
+package ff
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// failRunner fails every call with err, writing stderr.
+type failRunner struct {
+	err    error
+	stderr string
+}
+
+func (r failRunner) Run(ctx context.Context, call Call) error {
+	io.WriteString(call.Stderr, r.stderr)
+	return r.err
+}
+
+func TestRunFails(t *testing.T) {
+	exit := exec.Command("sh", "-c", "exit 3").Run()
+	if exit == nil {
+		t.Skip("sh did not fail")
+	}
+
+	cmd := New()
+	cmd.In("book.m4b")
+	cmd.Output.Name("out").Ext(".mp3")
+	cmd.Runner = failRunner{err: exit, stderr: "book.m4b: Invalid data found\n"}
+
+	err := cmd.Compile().Run()
+	var ee *exec.ExitError
+	if !errors.As(err, &ee) || ee.ExitCode() != 3 {
+		t.Fatalf("got %v, want exit status 3", err)
+	}
+	if !strings.Contains(err.Error(), "Invalid data found") {
+		t.Errorf("error %q has no stderr", err)
+	}
+}
@@ -0,0 +1,119 @@
+// Package fftest provides a fake ff.Runner that records the calls made to
+// ffmpeg and ffprobe and replays canned output, for testing how commands are
+// built without running them.
+package fftest
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/ohzqq/avtools/ff"
+)
+
+// Response is the canned result of a call.
+type Response struct {
+	Stdout string
+	Stderr string
+	Err    error
+}
+
+// Runner is a fake ff.Runner. Calls to a program get its responses in the
+// order they were added, the last one given repeating until another is
+// added; programs without any get an empty, successful response.
+type Runner struct {
+	mu        sync.Mutex
+	calls     []ff.Call
+	responses map[string][]Response
+	last      map[string]Response
+}
+
+// New returns a Runner without responses.
+func New() *Runner {
+	return &Runner{
+		responses: make(map[string][]Response),
+		last:      make(map[string]Response),
+	}
+}
+
+// Install makes r the ff.DefaultRunner, returning a func that restores the
+// previous one.
+func (r *Runner) Install() (restore func()) {
+	prev := ff.DefaultRunner
+	ff.DefaultRunner = r
+	return func() {
+		ff.DefaultRunner = prev
+	}
+}
+
+// On adds a response for the program name, "ffmpeg" or "ffprobe".
+func (r *Runner) On(name string, resp Response) *Runner {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responses[name] = append(r.responses[name], resp)
+	return r
+}
+
+// Probe adds ffprobe json output as a response.
+func (r *Runner) Probe(json string) *Runner {
+	return r.On("ffprobe", Response{Stdout: json})
+}
+
+// Run records call and writes out its response, or returns the cancellation
+// error without a response when ctx is done.
+func (r *Runner) Run(ctx context.Context, call ff.Call) error {
+	r.mu.Lock()
+	call.Args = append([]string(nil), call.Args...)
+	r.calls = append(r.calls, call)
+	if queue := r.responses[call.Name]; len(queue) > 0 {
+		r.last[call.Name] = queue[0]
+		r.responses[call.Name] = queue[1:]
+	}
+	resp := r.last[call.Name]
+	r.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return ff.Canceled(err)
+	}
+
+	if call.Stdout != nil {
+		io.WriteString(call.Stdout, resp.Stdout)
+	}
+	if call.Stderr != nil {
+		io.WriteString(call.Stderr, resp.Stderr)
+	}
+	return resp.Err
+}
+
+// Calls returns the calls made so far, in order.
+func (r *Runner) Calls() []ff.Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ff.Call(nil), r.calls...)
+}
+
+// Argv returns the program and arguments of the calls made so far.
+func (r *Runner) Argv() [][]string {
+	var argv [][]string
+	for _, c := range r.Calls() {
+		argv = append(argv, append([]string{c.Name}, c.Args...))
+	}
+	return argv
+}
+
+// Commands returns the calls made so far as command lines.
+func (r *Runner) Commands() []string {
+	var cmds []string
+	for _, argv := range r.Argv() {
+		cmds = append(cmds, strings.Join(argv, " "))
+	}
+	return cmds
+}
+
+// Reset forgets the calls made so far.
+func (r *Runner) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = nil
+}
@@ -162,7 +162,7 @@ func Palette(args ffmpeg.KwArgs) filter {
 			case "tc", "transparency_color":
 				genArgs["transparency_color"] = val
 			case "s", "stats_mode", "sm":
-				if val != "full" || val != "diff" || val != "single" {
+				if val != "full" && val != "diff" && val != "single" {
 					val = "full"
 				}
 				genArgs["stats_mode"] = val
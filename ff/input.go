@@ -4,9 +4,26 @@ import (
 	ffmpeg "github.com/u2takey/ffmpeg-go"
 )
 
+// Role is what an input is for, which decides the streams taken from it
+// when the outputs don't map any.
+type Role string
+
+const (
+	// Media inputs have their streams mapped.
+	Media Role = "media"
+	// Metadata inputs, like ffmetadata files, give the tags and chapters of
+	// the outputs.
+	Metadata Role = "metadata"
+	// Cover inputs are images, mapped like media.
+	Cover Role = "cover"
+	// Subtitle inputs have their streams mapped.
+	Subtitle Role = "subtitle"
+)
+
 type Input struct {
 	File string
 	Args ffmpeg.KwArgs
+	Role Role
 }
 
 func NewInput(args ...ffmpeg.KwArgs) Input {
@@ -28,40 +45,27 @@ func (i *Input) Merge(kwargs ffmpeg.KwArgs) *Input {
 }
 
 func (i *Input) Compile(file string) *ffmpeg.Stream {
-	args := make(ffmpeg.KwArgs)
-	for key, val := range i.Args {
-		switch key {
-		case "map_metadata", "map_chapters", "meta":
-		default:
-			args[key] = val
-		}
-	}
-	return ffmpeg.Input(file, args)
+	return ffmpeg.Input(file, i.Args)
 }
 
-func (i *Input) Verbose() *Input {
-	i.Set("loglevel", "info")
-	return i
-}
-
-func (i *Input) FFMeta(file string, idx ...string) *Input {
-	label := "1"
-	if len(idx) > 0 {
-		label = idx[0]
+// part is the input with its options as flags.
+func (i Input) part() Part {
+	return Part{
+		File:  i.File,
+		Role:  i.Role,
+		Flags: kwargs(i.Args, "format", "video_size"),
 	}
-	i.Set("meta", file)
-	i.Set("map_metadata", label)
-	return i
 }
 
-func (i *Input) MapChapters(idx string) *Input {
-	i.Set("map_chapters", idx)
+func (i *Input) Verbose() *Input {
+	i.Set("loglevel", "info")
 	return i
 }
 
-func (i *Input) MapMetadata(idx string) *Input {
-	i.Set("map_metadata", idx)
-	return i
+// IsVerbose reports whether Verbose was set, for commands to print what
+// they run.
+func (i Input) IsVerbose() bool {
+	return i.Args["loglevel"] == "info"
 }
 
 func (i *Input) Overwrite() *Input {
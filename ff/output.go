@@ -62,6 +62,18 @@ func (out Output) Compile(s *ffmpeg.Stream) *ffmpeg.Stream {
 	return s.Output(out.String(), out.KwArgs())
 }
 
+// part is the output with its stream maps and other options as flags.
+func (out Output) part() Part {
+	var args []string
+	for _, spec := range out.Maps() {
+		args = append(args, "-map", spec)
+	}
+	kw := out.KwArgs()
+	delete(kw, "map")
+	args = append(args, kwargs(kw, "map_metadata", "map_chapters", "format", "video_bitrate", "audio_bitrate", "video_size")...)
+	return Part{File: out.String(), Flags: args}
+}
+
 func (out *Output) Name(n string) *Output {
 	out.Set("name", n)
 	return out
@@ -107,6 +119,58 @@ func (out *Output) IsStreamCopy() bool {
 	return aCopy || vCopy
 }
 
+// Map selects streams for the output, like "0:a" or "2:s:0", or the output
+// of a filter graph, like "[v]". Outputs without any are mapped by the
+// roles of the inputs.
+func (out *Output) Map(specs ...string) *Output {
+	var maps []string
+	if m, ok := out.Args["map"].([]string); ok {
+		maps = m
+	}
+	out.Set("map", append(maps, specs...))
+	return out
+}
+
+// Maps are the stream selectors of the output.
+func (out Output) Maps() []string {
+	if m, ok := out.Args["map"].([]string); ok {
+		return m
+	}
+	return nil
+}
+
+// MapMetadata takes the tags of the output from the input idx, or none
+// when it is "-1".
+func (out *Output) MapMetadata(idx string) *Output {
+	out.Set("map_metadata", idx)
+	return out
+}
+
+// MapChapters takes the chapters of the output from the input idx, or none
+// when it is "-1".
+func (out *Output) MapChapters(idx string) *Output {
+	out.Set("map_chapters", idx)
+	return out
+}
+
+// Disposition sets the disposition of the output streams spec, like
+// "v:0" "attached_pic" for a cover.
+func (out *Output) Disposition(spec, val string) *Output {
+	out.Set("disposition:"+spec, val)
+	return out
+}
+
+// Metadata adds a -metadata key=val pair to the output.
+func (out *Output) Metadata(key, val string) *Output {
+	var meta []string
+	if m, ok := out.Args["metadata"].([]string); ok {
+		meta = m
+	}
+	meta = append(meta, key+"="+val)
+	out.Set("metadata", meta)
+	return out
+}
+
 func (out *Output) Get(key string) any {
 	if val, ok := out.Args[key]; ok {
 		return val
@@ -115,6 +179,9 @@ func (out *Output) Get(key string) any {
 }
 
 func (out *Output) Set(key string, val any) *Output {
+	if out.Args == nil {
+		out.Args = make(ffmpeg.KwArgs)
+	}
 	out.Args[key] = val
 	return out
 }
@@ -0,0 +1,126 @@
+package ff
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/alessio/shellescape"
+	"github.com/ohzqq/avtools"
+)
+
+// Step is one thing a command does, for dry runs, scripts and plans:
+// running a program with Args, writing Data to the file Write or saving
+// metadata natively.
+type Step struct {
+	Args []string
+	// Cmd is the layout of an ffmpeg run, whose Args it makes.
+	Cmd   *Layout
+	Write string
+	Data  []byte
+	Save  *Save
+	// Script is an ffmpeg run doing what Save does, which scripts run in
+	// its place.
+	Script *Layout
+	// Note describes a step that isn't done by a program, which a script
+	// can't repeat.
+	Note string
+}
+
+// Save writes the tags and chapters of the metadata file Meta natively,
+// in the codec Format, into Output, a copy of Input.
+type Save struct {
+	Format string `json:"format" yaml:"format"`
+	Input  string `json:"input" yaml:"input"`
+	Output string `json:"output" yaml:"output"`
+	Meta   string `json:"meta" yaml:"meta"`
+}
+
+func (s Save) String() string {
+	return fmt.Sprintf("save the tags and chapters of %s as %s into %s", s.Meta, s.Format, s.Output)
+}
+
+func (s Step) String() string {
+	switch {
+	case s.Write != "":
+		return fmt.Sprintf("write %s (%d bytes)", s.Write, len(s.Data))
+	case s.Save != nil:
+		return "# " + s.Save.String()
+	case s.Note != "":
+		return "# " + s.Note
+	}
+	return shellescape.QuoteCommand(s.Args)
+}
+
+// Shell is the step as a POSIX shell command. Notes, and saves without a
+// Script, can't be run by a shell.
+func (s Step) Shell() (string, error) {
+	switch {
+	case s.Write != "":
+		return fmt.Sprintf(
+			"printf '%%s' %s > %s",
+			shellescape.Quote(string(s.Data)),
+			shellescape.Quote(s.Write),
+		), nil
+	case s.Save != nil:
+		if s.Script == nil {
+			return "", fmt.Errorf("%w: can't script %s", avtools.ErrUnsupported, s.Save)
+		}
+		return "# " + s.Save.String() + "\n" + shellescape.QuoteCommand(append([]string{"ffmpeg"}, s.Script.Args()...)), nil
+	case s.Note != "":
+		return "", fmt.Errorf("%w: can't script %q", avtools.ErrUnsupported, s.Note)
+	}
+	return shellescape.QuoteCommand(s.Args), nil
+}
+
+// Planner is a command that can tell its steps without running.
+type Planner interface {
+	Plan() ([]Step, error)
+}
+
+// Plan is the ffmpeg run of the compiled command.
+func (c Cmd) Plan() ([]Step, error) {
+	if c.args == nil {
+		return nil, fmt.Errorf("ffmpeg command not compiled")
+	}
+	layout := c.layout
+	return []Step{{
+		Args: append([]string{"ffmpeg"}, c.args...),
+		Cmd:  &layout,
+	}}, nil
+}
+
+// DryRun writes the steps, one a line.
+func DryRun(w io.Writer, steps []Step) error {
+	for _, s := range steps {
+		_, err := fmt.Fprintln(w, s)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Script writes the steps as a POSIX shell script stopping at the first
+// failure. Nothing is written when a step can't be scripted.
+func Script(w io.Writer, steps []Step) error {
+	lines := make([]string, len(steps))
+	for i, s := range steps {
+		sh, err := s.Shell()
+		if err != nil {
+			return err
+		}
+		lines[i] = sh
+	}
+
+	_, err := io.WriteString(w, "#!/bin/sh\nset -e\n\n")
+	if err != nil {
+		return err
+	}
+	for _, l := range lines {
+		_, err := fmt.Fprintln(w, l)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
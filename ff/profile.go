@@ -1,7 +1,7 @@
 package ff
 
 import (
-	"log"
+	"fmt"
 	"os"
 
 	ffmpeg "github.com/u2takey/ffmpeg-go"
@@ -63,17 +63,18 @@ func MergeProfiles(pros ...string) profile {
 	}
 }
 
-func ReadConfig(name string) {
+func ReadConfig(name string) error {
 	file, err := os.Open(name)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer file.Close()
 
 	err = yaml.NewDecoder(file).Decode(&profiles)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("%s: %w", name, err)
 	}
+	return nil
 }
 
 func GetProfile(name string) Cmd {
@@ -0,0 +1,133 @@
+package ff
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultProgress is called with the progress of Cmds without an
+// OnProgress of their own. The CLIs set it to a Bar on a terminal.
+var DefaultProgress func(Progress)
+
+// Progress is a report from ffmpeg's -progress output.
+type Progress struct {
+	Frame     int64
+	FPS       float64
+	Bitrate   string
+	TotalSize int64
+	OutTime   time.Duration
+	// Speed is the encoding speed as a multiple of real time.
+	Speed float64
+	// Duration is the expected length of the output, 0 when unknown.
+	Duration time.Duration
+	// Done is set on the last report.
+	Done bool
+}
+
+// Percent is how much of Duration is done, from 0 to 100, or -1 when the
+// duration isn't known.
+func (p Progress) Percent() float64 {
+	if p.Duration <= 0 {
+		return -1
+	}
+	pc := float64(p.OutTime) / float64(p.Duration) * 100
+	switch {
+	case pc < 0:
+		return 0
+	case pc > 100 || p.Done:
+		return 100
+	}
+	return pc
+}
+
+// ETA is the time left at the current speed, or -1 when it can't be told.
+func (p Progress) ETA() time.Duration {
+	if p.Done {
+		return 0
+	}
+	if p.Duration <= 0 || p.Speed <= 0 {
+		return -1
+	}
+	left := p.Duration - p.OutTime
+	if left < 0 {
+		return 0
+	}
+	return time.Duration(float64(left) / p.Speed).Round(time.Second)
+}
+
+// ParseProgress reads the key=value blocks ffmpeg writes with -progress,
+// calling fn at the end of each. d is the expected length of the output.
+func ParseProgress(r io.Reader, d time.Duration, fn func(Progress)) error {
+	p := Progress{Duration: d}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, val, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		val = strings.TrimSpace(val)
+		switch key {
+		case "frame":
+			p.Frame, _ = strconv.ParseInt(val, 10, 64)
+		case "fps":
+			p.FPS, _ = strconv.ParseFloat(val, 64)
+		case "bitrate":
+			p.Bitrate = val
+		case "total_size":
+			p.TotalSize, _ = strconv.ParseInt(val, 10, 64)
+		case "out_time_us", "out_time_ms":
+			// out_time_ms is in microseconds too
+			if us, err := strconv.ParseInt(val, 10, 64); err == nil {
+				p.OutTime = time.Duration(us) * time.Microsecond
+			}
+		case "speed":
+			p.Speed, _ = strconv.ParseFloat(strings.TrimSuffix(val, "x"), 64)
+		case "progress":
+			p.Done = val == "end"
+			fn(p)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// Bar returns a func drawing progress as a one line bar on w.
+func Bar(w io.Writer) func(Progress) {
+	const width = 30
+	return func(p Progress) {
+		var line string
+		if pc := p.Percent(); pc >= 0 {
+			done := int(pc / 100 * width)
+			line = fmt.Sprintf(
+				"[%s%s] %5.1f%%",
+				strings.Repeat("#", done),
+				strings.Repeat(".", width-done),
+				pc,
+			)
+		} else {
+			line = p.OutTime.Round(time.Second).String()
+		}
+		if p.Speed > 0 {
+			line += fmt.Sprintf(" %.2fx", p.Speed)
+		}
+		if eta := p.ETA(); eta > 0 {
+			line += " eta " + eta.String()
+		}
+		fmt.Fprintf(w, "\r\033[K%s", line)
+		if p.Done {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// IsTerminal reports whether f is a terminal.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,100 @@
+package ff
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/ohzqq/avtools"
+)
+
+// Call is one run of ffmpeg or ffprobe.
+type Call struct {
+	// Name is the program, "ffmpeg" or "ffprobe".
+	Name   string
+	Args   []string
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Runner runs ffmpeg and ffprobe. Cmds without a Runner of their own, and
+// the probe, silence and scene packages unless given one, use
+// DefaultRunner. A run stopped by ctx returns an error matching
+// avtools.ErrCanceled.
+type Runner interface {
+	Run(ctx context.Context, call Call) error
+}
+
+// DefaultRunner runs the programs found in PATH.
+var DefaultRunner Runner = ExecRunner{}
+
+// ExecRunner runs the programs as processes.
+type ExecRunner struct {
+	// FFmpeg and FFprobe are the paths of the programs, looked up in PATH
+	// when empty.
+	FFmpeg  string
+	FFprobe string
+	// Env is added to the environment of the process.
+	Env []string
+	// Dir is the working directory, the current one when empty.
+	Dir string
+}
+
+// Path is the binary run for the program name.
+func (r ExecRunner) Path(name string) string {
+	switch {
+	case name == "ffmpeg" && r.FFmpeg != "":
+		return r.FFmpeg
+	case name == "ffprobe" && r.FFprobe != "":
+		return r.FFprobe
+	}
+	return name
+}
+
+// Command returns the process for call, without starting it. It runs in a
+// process group of its own, so it can be killed with its children.
+func (r ExecRunner) Command(call Call) *exec.Cmd {
+	cmd := exec.Command(r.Path(call.Name), call.Args...)
+	cmd.Stdout = call.Stdout
+	cmd.Stderr = call.Stderr
+	cmd.Dir = r.Dir
+	if len(r.Env) > 0 {
+		cmd.Env = append(os.Environ(), r.Env...)
+	}
+	setpgid(cmd)
+	return cmd
+}
+
+// Run runs call, killing its process group when ctx is done.
+func (r ExecRunner) Run(ctx context.Context, call Call) error {
+	if err := ctx.Err(); err != nil {
+		return Canceled(err)
+	}
+
+	cmd := r.Command(call)
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		killGroup(cmd)
+		<-done
+		return Canceled(ctx.Err())
+	}
+}
+
+// Canceled wraps the error of a done context as an avtools.ErrCanceled.
+func Canceled(err error) error {
+	return fmt.Errorf("%w: %v", avtools.ErrCanceled, err)
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package ff
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+func setpgid(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	if err != nil {
+		cmd.Process.Kill()
+	}
+}
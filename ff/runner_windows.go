@@ -0,0 +1,13 @@
+//go:build windows
+
+package ff
+
+import "os/exec"
+
+func setpgid(cmd *exec.Cmd) {}
+
+func killGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
@@ -1,41 +1,74 @@
 package ffmeta
 
 import (
-	"log"
+	"fmt"
 	"strconv"
-	"strings"
 	"time"
+
+	"github.com/ohzqq/avtools"
 )
 
 type FFMetaChapter struct {
-	Base      string  `ini:"TIMEBASE"`
-	StartTime float64 `ini:"START"`
-	EndTime   float64 `ini:"END"`
-	ChTitle   string  `ini:"title"`
+	Base      string
+	StartTime int64
+	EndTime   int64
+	ChTitle   string
+	tags      map[string]string
+	base      avtools.Timebase
+}
+
+// newChapter reads a CHAPTER section, keeping keys other than the timing
+// and title as tags. A missing TIMEBASE defaults to 1/1000.
+func newChapter(keys map[string]string) (FFMetaChapter, error) {
+	ch := FFMetaChapter{
+		Base: "1/1000",
+		tags: make(map[string]string),
+	}
+	for key, val := range keys {
+		var err error
+		switch key {
+		case "timebase":
+			ch.Base = val
+		case "start":
+			ch.StartTime, err = strconv.ParseInt(val, 10, 64)
+		case "end":
+			ch.EndTime, err = strconv.ParseInt(val, 10, 64)
+		case "title":
+			ch.ChTitle = val
+		default:
+			ch.tags[key] = val
+		}
+		if err != nil {
+			return ch, fmt.Errorf("ffmeta: chapter %s %w %q", key, avtools.ErrInvalidTimestamp, val)
+		}
+	}
+	base, err := avtools.ParseTimebase(ch.Base)
+	if err != nil {
+		return ch, fmt.Errorf("ffmeta: %w", err)
+	}
+	ch.base = base
+	return ch, nil
 }
 
 func (ch FFMetaChapter) Start() time.Duration {
-	return calculateSecs(ch.StartTime, ch.Base)
+	start, _ := ch.Stamps()
+	return start.Duration()
 }
 
 func (ch FFMetaChapter) End() time.Duration {
-	return calculateSecs(ch.EndTime, ch.Base)
+	_, end := ch.Stamps()
+	return end.Duration()
 }
 
-func (ch FFMetaChapter) Title() string {
-	return ch.ChTitle
+// Stamps returns START and END in the chapter's TIMEBASE.
+func (ch FFMetaChapter) Stamps() (start, end avtools.Stamp) {
+	return avtools.NewStamp(ch.StartTime, ch.base), avtools.NewStamp(ch.EndTime, ch.base)
 }
 
-func calculateSecs(num float64, base string) time.Duration {
-	b := timebase(base)
-	t := num / b * float64(time.Second)
-	return time.Duration(t)
+func (ch FFMetaChapter) Title() string {
+	return ch.ChTitle
 }
 
-func timebase(b string) float64 {
-	base, err := strconv.Atoi(strings.TrimPrefix(b, "1/"))
-	if err != nil {
-		log.Fatal(err)
-	}
-	return float64(base)
+func (ch FFMetaChapter) Tags() map[string]string {
+	return ch.tags
 }
@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
-	"log"
 	"os"
 	"strconv"
 
@@ -22,6 +21,11 @@ type FFMeta struct {
 }
 
 func Load(input string) (avtools.Metaz, error) {
+	src, err := avtools.NewFile(input)
+	if err != nil {
+		return nil, err
+	}
+
 	opts := ini.LoadOptions{}
 	opts.Insensitive = true
 	opts.InsensitiveSections = true
@@ -29,10 +33,10 @@ func Load(input string) (avtools.Metaz, error) {
 	opts.AllowNonUniqueSections = true
 
 	ffmeta := &FFMeta{}
-	ffmeta.File = fidi.NewFile(input)
+	ffmeta.File = src
 
 	if !IsFFMeta(ffmeta.File) {
-		return ffmeta, fmt.Errorf("not an ffmetadata file")
+		return ffmeta, fmt.Errorf("%w: %s", avtools.ErrNotFFMeta, input)
 	}
 
 	f, err := ini.LoadSources(opts, ffmeta.Path())
@@ -49,8 +53,7 @@ func Load(input string) (avtools.Metaz, error) {
 		}
 
 		for _, ch := range sections {
-			var c FFMetaChapter
-			err := ch.MapTo(&c)
+			c, err := newChapter(ch.KeysHash())
 			if err != nil {
 				return ffmeta, err
 			}
@@ -61,7 +64,7 @@ func Load(input string) (avtools.Metaz, error) {
 	return ffmeta, nil
 }
 
-func Dump(meta avtools.Meta) []byte {
+func Dump(meta avtools.Meta) ([]byte, error) {
 	ini.PrettyFormat = false
 
 	opts := ini.LoadOptions{
@@ -74,21 +77,23 @@ func Dump(meta avtools.Meta) []byte {
 	for k, v := range meta.Tags() {
 		_, err := ffmeta.Section("").NewKey(k, v)
 		if err != nil {
-			log.Fatal(err)
+			return nil, fmt.Errorf("ffmeta: tag %q: %w", k, err)
 		}
 	}
 
 	for _, chapter := range meta.Chapters() {
 		sec, err := ffmeta.NewSection("CHAPTER")
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		sec.NewKey("TIMEBASE", "1/1000")
-		ss := strconv.Itoa(int(chapter.StartStamp.Dur.Milliseconds()))
-		sec.NewKey("START", ss)
-
-		to := strconv.Itoa(int(chapter.EndStamp.Dur.Milliseconds()))
-		sec.NewKey("END", to)
+		base, err := avtools.ParseTimebase(chapter.Timebase())
+		if err != nil {
+			return nil, fmt.Errorf("ffmeta: %w", err)
+		}
+		ss, to := chapter.Stamps()
+		sec.NewKey("TIMEBASE", base.String())
+		sec.NewKey("START", strconv.FormatInt(ss.Rescale(base, avtools.RoundNearest).Ticks, 10))
+		sec.NewKey("END", strconv.FormatInt(to.Rescale(base, avtools.RoundNearest).Ticks, 10))
 		sec.NewKey("title", chapter.ChapTitle)
 		for k, v := range chapter.Tags {
 			sec.NewKey(k, v)
@@ -96,13 +101,13 @@ func Dump(meta avtools.Meta) []byte {
 	}
 
 	var buf bytes.Buffer
-	_, err := buf.WriteString(FFmetaComment)
-	_, err = ffmeta.WriteTo(&buf)
+	buf.WriteString(FFmetaComment)
+	_, err := ffmeta.WriteTo(&buf)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	return buf.Bytes()
+	return buf.Bytes(), nil
 }
 
 func (ff FFMeta) Chapters() []avtools.ChapterMeta {
@@ -121,23 +126,22 @@ func (ff FFMeta) Source() fidi.File {
 	return ff.File
 }
 
+// IsFFMeta reports whether the first line of f is the ;FFMETADATA1 header.
 func IsFFMeta(f fidi.File) bool {
-	if err := avtools.IsPlainText(f.Mime); err != nil {
-		return false
-	}
 	contents, err := os.Open(f.Path())
 	if err != nil {
-		log.Fatal(err)
+		return false
 	}
 	defer contents.Close()
 
 	scanner := bufio.NewScanner(contents)
-	line := 0
-	for scanner.Scan() {
-		if line == 0 && scanner.Text() == ";FFMETADATA1" {
-			return true
-			break
-		}
+	if !scanner.Scan() {
+		return false
 	}
-	return false
+	return Sniff(scanner.Bytes())
+}
+
+// Sniff reports whether data starts with the ;FFMETADATA1 header.
+func Sniff(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimPrefix(data, []byte("\ufeff")), []byte(";FFMETADATA1"))
 }
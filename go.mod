@@ -2,10 +2,22 @@ module github.com/ohzqq/avtools
 
 go 1.18
 
+require (
+	github.com/alessio/shellescape v1.4.1
+	github.com/ohzqq/dur v0.0.22
+	github.com/ohzqq/fidi v0.0.27
+	github.com/samber/lo v1.37.0
+	github.com/spf13/cobra v1.6.1
+	github.com/spf13/viper v1.14.0
+	github.com/u2takey/ffmpeg-go v0.4.1
+	golang.org/x/exp v0.0.0-20230321023759-10a507213a29
+	gopkg.in/ini.v1 v1.67.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
 require (
 	github.com/Ambrevar/demlo v3.7.1+incompatible // indirect
 	github.com/BurntSushi/toml v1.2.0 // indirect
-	github.com/alessio/shellescape v1.4.1 // indirect
 	github.com/aws/aws-sdk-go v1.38.20 // indirect
 	github.com/fsnotify/fsnotify v1.6.0 // indirect
 	github.com/go-ini/ini v1.66.4 // indirect
@@ -18,25 +30,16 @@ require (
 	github.com/leaanthony/clir v1.0.5 // indirect
 	github.com/magiconair/properties v1.8.6 // indirect
 	github.com/mitchellh/mapstructure v1.5.0 // indirect
-	github.com/ohzqq/dur v0.0.22 // indirect
-	github.com/ohzqq/fidi v0.0.27 // indirect
 	github.com/pelletier/go-toml v1.9.5 // indirect
 	github.com/pelletier/go-toml/v2 v2.0.5 // indirect
-	github.com/samber/lo v1.37.0 // indirect
 	github.com/spf13/afero v1.9.2 // indirect
 	github.com/spf13/cast v1.5.0 // indirect
-	github.com/spf13/cobra v1.6.1 // indirect
 	github.com/spf13/jwalterweatherman v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	github.com/spf13/viper v1.14.0 // indirect
 	github.com/subosito/gotenv v1.4.1 // indirect
-	github.com/u2takey/ffmpeg-go v0.4.1 // indirect
 	github.com/u2takey/go-utils v0.3.1 // indirect
 	github.com/vchimishuk/chub v0.0.0-20220107162648-9d7fe8a6a485 // indirect
-	golang.org/x/exp v0.0.0-20230321023759-10a507213a29 // indirect
 	golang.org/x/sys v0.6.0 // indirect
 	golang.org/x/text v0.4.0 // indirect
-	gopkg.in/ini.v1 v1.67.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
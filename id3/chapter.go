@@ -0,0 +1,140 @@
+package id3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// ctoc flags
+const (
+	tocOrdered  = 0x01
+	tocTopLevel = 0x02
+)
+
+// Chapter is a CHAP frame. Start and end are stored in milliseconds.
+type Chapter struct {
+	ID    string
+	start time.Duration
+	end   time.Duration
+	title string
+	image picture
+}
+
+type toc struct {
+	id       string
+	top      bool
+	children []string
+}
+
+func (ch Chapter) Start() time.Duration {
+	return ch.start
+}
+
+func (ch Chapter) End() time.Duration {
+	return ch.end
+}
+
+func (ch Chapter) Title() string {
+	return ch.title
+}
+
+// Image returns the picture embedded in the chapter, if any, and its mime
+// type.
+func (ch Chapter) Image() ([]byte, string) {
+	return ch.image.data, ch.image.mime
+}
+
+func readCHAP(data []byte, version byte) (*Chapter, error) {
+	i := bytes.IndexByte(data, 0)
+	if i < 0 || len(data) < i+17 {
+		return nil, fmt.Errorf("id3: truncated CHAP")
+	}
+
+	ch := &Chapter{ID: string(data[:i])}
+	data = data[i+1:]
+	ch.start = time.Duration(binary.BigEndian.Uint32(data[:4])) * time.Millisecond
+	ch.end = time.Duration(binary.BigEndian.Uint32(data[4:8])) * time.Millisecond
+
+	frames, err := parseFrames(data[16:], version)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range frames {
+		body, err := f.body(version)
+		if err != nil {
+			continue
+		}
+		switch f.id {
+		case "TIT2":
+			ch.title = readText(body)
+		case "APIC":
+			pic, err := readAPIC(body)
+			if err != nil {
+				return nil, err
+			}
+			ch.image = pic
+		}
+	}
+
+	return ch, nil
+}
+
+func readCTOC(data []byte) (toc, error) {
+	var t toc
+	i := bytes.IndexByte(data, 0)
+	if i < 0 || len(data) < i+3 {
+		return t, fmt.Errorf("id3: truncated CTOC")
+	}
+	t.id = string(data[:i])
+	flags := data[i+1]
+	count := int(data[i+2])
+	t.top = flags&tocTopLevel != 0
+	data = data[i+3:]
+
+	for n := 0; n < count; n++ {
+		j := bytes.IndexByte(data, 0)
+		if j < 0 {
+			return t, fmt.Errorf("id3: truncated CTOC")
+		}
+		t.children = append(t.children, string(data[:j]))
+		data = data[j+1:]
+	}
+
+	return t, nil
+}
+
+func chapFrame(version byte, ch *Chapter) frame {
+	data := append([]byte(ch.ID), 0)
+	var times [16]byte
+	binary.BigEndian.PutUint32(times[0:4], uint32(ch.start/time.Millisecond))
+	binary.BigEndian.PutUint32(times[4:8], uint32(ch.end/time.Millisecond))
+	binary.BigEndian.PutUint32(times[8:12], 0xffffffff)
+	binary.BigEndian.PutUint32(times[12:16], 0xffffffff)
+	data = append(data, times[:]...)
+
+	if ch.title != "" {
+		data = append(data, textFrame(version, "TIT2", ch.title).bytes(version)...)
+	}
+	if len(ch.image.data) > 0 {
+		data = append(data, apicFrame(version, ch.image).bytes(version)...)
+	}
+
+	return frame{id: "CHAP", data: data}
+}
+
+// ctocFrame builds an ordered top level table of contents listing every
+// chapter.
+func ctocFrame(chaps []*Chapter) (frame, error) {
+	if len(chaps) > 255 {
+		return frame{}, fmt.Errorf("id3: CTOC holds at most 255 chapters, got %d", len(chaps))
+	}
+	data := []byte("toc")
+	data = append(data, 0, tocTopLevel|tocOrdered, byte(len(chaps)))
+	for _, ch := range chaps {
+		data = append(data, ch.ID...)
+		data = append(data, 0)
+	}
+	return frame{id: "CTOC", data: data}, nil
+}
@@ -0,0 +1,264 @@
+package id3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+)
+
+// text encodings
+const (
+	encLatin1  = 0
+	encUTF16   = 1
+	encUTF16BE = 2
+	encUTF8    = 3
+)
+
+// frame flags the reader has to undo
+const (
+	flagUnsync     = 0x0002
+	flagDataLength = 0x0001
+	flagCompressed = 0x0008
+	flagEncrypted  = 0x0004
+)
+
+type frame struct {
+	id    string
+	flags uint16
+	data  []byte
+}
+
+// parseFrames reads the frames of a tag body or of the sub-frames of a
+// CHAP or CTOC frame.
+func parseFrames(data []byte, version byte) ([]frame, error) {
+	var frames []frame
+	for len(data) >= 10 {
+		if data[0] == 0 {
+			break
+		}
+		id := string(data[:4])
+		var size int
+		if version == 4 {
+			size = syncsafe(data[4:8])
+		} else {
+			size = int(binary.BigEndian.Uint32(data[4:8]))
+		}
+		flags := binary.BigEndian.Uint16(data[8:10])
+		data = data[10:]
+		if size > len(data) {
+			return frames, fmt.Errorf("id3: frame %s overflows the tag", id)
+		}
+		frames = append(frames, frame{
+			id:    id,
+			flags: flags,
+			data:  data[:size],
+		})
+		data = data[size:]
+	}
+	return frames, nil
+}
+
+// body returns the frame contents with v2.4 frame level unsynchronisation
+// and data length indicators undone.
+func (f frame) body(version byte) ([]byte, error) {
+	if version != 4 {
+		return f.data, nil
+	}
+	if f.flags&(flagCompressed|flagEncrypted) != 0 {
+		return nil, fmt.Errorf("id3: frame %s is compressed or encrypted", f.id)
+	}
+	data := f.data
+	if f.flags&flagDataLength != 0 {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("id3: frame %s is truncated", f.id)
+		}
+		data = data[4:]
+	}
+	if f.flags&flagUnsync != 0 {
+		data = resync(data)
+	}
+	return data, nil
+}
+
+func (f frame) bytes(version byte) []byte {
+	hdr := make([]byte, 10)
+	copy(hdr, f.id)
+	if version == 4 {
+		putSyncsafe(hdr[4:8], len(f.data))
+	} else {
+		binary.BigEndian.PutUint32(hdr[4:8], uint32(len(f.data)))
+	}
+	binary.BigEndian.PutUint16(hdr[8:10], f.flags)
+	return append(hdr, f.data...)
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+func putSyncsafe(b []byte, n int) {
+	b[0] = byte(n>>21) & 0x7f
+	b[1] = byte(n>>14) & 0x7f
+	b[2] = byte(n>>7) & 0x7f
+	b[3] = byte(n) & 0x7f
+}
+
+// resync removes the zero bytes inserted after 0xff by unsynchronisation.
+func resync(data []byte) []byte {
+	return bytes.ReplaceAll(data, []byte{0xff, 0x00}, []byte{0xff})
+}
+
+// decodeText decodes a string in the given encoding, dropping terminators.
+func decodeText(enc byte, b []byte) string {
+	switch enc {
+	case encUTF16, encUTF16BE:
+		order := binary.ByteOrder(binary.BigEndian)
+		if len(b) >= 2 {
+			switch {
+			case b[0] == 0xff && b[1] == 0xfe:
+				order = binary.LittleEndian
+				b = b[2:]
+			case b[0] == 0xfe && b[1] == 0xff:
+				b = b[2:]
+			}
+		}
+		u := make([]uint16, 0, len(b)/2)
+		for i := 0; i+1 < len(b); i += 2 {
+			c := order.Uint16(b[i:])
+			if c == 0 {
+				break
+			}
+			u = append(u, c)
+		}
+		return string(utf16.Decode(u))
+	case encUTF8:
+		return string(bytes.TrimRight(b, "\x00"))
+	}
+
+	b = bytes.TrimRight(b, "\x00")
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+// splitText splits off a terminated string at the start of b, returning it
+// and the rest.
+func splitText(enc byte, b []byte) (string, []byte) {
+	if enc == encUTF16 || enc == encUTF16BE {
+		for i := 0; i+1 < len(b); i += 2 {
+			if b[i] == 0 && b[i+1] == 0 {
+				return decodeText(enc, b[:i]), b[i+2:]
+			}
+		}
+		return decodeText(enc, b), nil
+	}
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		return decodeText(enc, b[:i]), b[i+1:]
+	}
+	return decodeText(enc, b), nil
+}
+
+// encodeText encodes a string in the encoding preferred for the version,
+// UTF-8 for v2.4 and UTF-16 for older tags.
+func encodeText(version byte, s string, terminate bool) (byte, []byte) {
+	if version == 4 {
+		b := []byte(s)
+		if terminate {
+			b = append(b, 0)
+		}
+		return encUTF8, b
+	}
+
+	b := []byte{0xff, 0xfe}
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(c), byte(c>>8))
+	}
+	if terminate {
+		b = append(b, 0, 0)
+	}
+	return encUTF16, b
+}
+
+func textFrame(version byte, id, val string) frame {
+	enc, b := encodeText(version, val, false)
+	return frame{id: id, data: append([]byte{enc}, b...)}
+}
+
+// readText reads a text information frame.
+func readText(b []byte) string {
+	if len(b) < 1 {
+		return ""
+	}
+	return decodeText(b[0], b[1:])
+}
+
+// readTXXX reads a user defined text frame into its description and value.
+func readTXXX(b []byte) (string, string) {
+	if len(b) < 1 {
+		return "", ""
+	}
+	desc, rest := splitText(b[0], b[1:])
+	return desc, decodeText(b[0], rest)
+}
+
+func txxxFrame(version byte, desc, val string) frame {
+	enc, d := encodeText(version, desc, true)
+	_, v := encodeText(version, val, false)
+	data := append([]byte{enc}, d...)
+	return frame{id: "TXXX", data: append(data, v...)}
+}
+
+// readCOMM reads a comment frame, skipping its language and description.
+func readCOMM(b []byte) string {
+	if len(b) < 4 {
+		return ""
+	}
+	_, rest := splitText(b[0], b[4:])
+	return decodeText(b[0], rest)
+}
+
+func commFrame(version byte, val string) frame {
+	enc, d := encodeText(version, "", true)
+	_, v := encodeText(version, val, false)
+	data := append([]byte{enc}, "eng"...)
+	data = append(data, d...)
+	return frame{id: "COMM", data: append(data, v...)}
+}
+
+type picture struct {
+	mime string
+	kind byte
+	desc string
+	data []byte
+}
+
+func readAPIC(b []byte) (picture, error) {
+	var pic picture
+	if len(b) < 2 {
+		return pic, fmt.Errorf("id3: truncated APIC")
+	}
+	enc := b[0]
+	i := bytes.IndexByte(b[1:], 0)
+	if i < 0 {
+		return pic, fmt.Errorf("id3: truncated APIC")
+	}
+	pic.mime = string(b[1 : i+1])
+	rest := b[i+2:]
+	if len(rest) < 1 {
+		return pic, fmt.Errorf("id3: truncated APIC")
+	}
+	pic.kind = rest[0]
+	pic.desc, pic.data = splitText(enc, rest[1:])
+	return pic, nil
+}
+
+func apicFrame(version byte, pic picture) frame {
+	enc, d := encodeText(version, pic.desc, true)
+	data := append([]byte{enc}, pic.mime...)
+	data = append(data, 0, pic.kind)
+	data = append(data, d...)
+	return frame{id: "APIC", data: append(data, pic.data...)}
+}
@@ -0,0 +1,263 @@
+package id3
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ohzqq/avtools"
+	"github.com/ohzqq/fidi"
+)
+
+// tag header flags
+const (
+	tagUnsync   = 0x80
+	tagExtended = 0x40
+	tagFooter   = 0x10
+)
+
+// Meta is the ID3v2 tag of an mp3 file, including CHAP and CTOC chapters.
+type Meta struct {
+	fidi.File
+	version  byte
+	size     int
+	frames   []frame
+	tags     map[string]string
+	chapters []*Chapter
+	cover    picture
+}
+
+// textTags maps text frames to ffmpeg's tag names.
+var textTags = map[string]string{
+	"TIT2": "title",
+	"TIT3": "subtitle",
+	"TPE1": "artist",
+	"TPE2": "album_artist",
+	"TALB": "album",
+	"TCON": "genre",
+	"TDRC": "date",
+	"TYER": "date",
+	"TRCK": "track",
+	"TPOS": "disc",
+	"TCOM": "composer",
+	"TCOP": "copyright",
+	"TENC": "encoded_by",
+	"TSSE": "encoder",
+	"TLAN": "language",
+	"TPUB": "publisher",
+}
+
+// skipTags are added by the probe and don't belong in a file.
+var skipTags = map[string]bool{
+	"filename": true,
+	"duration": true,
+	"size":     true,
+	"bit_rate": true,
+}
+
+func Load(input string) (avtools.Metaz, error) {
+	src, err := avtools.NewFile(input)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &Meta{
+		File: src,
+		tags: make(map[string]string),
+	}
+
+	f, err := os.Open(input)
+	if err != nil {
+		return meta, err
+	}
+	defer f.Close()
+
+	err = meta.read(f)
+	if err != nil {
+		return meta, err
+	}
+
+	return meta, nil
+}
+
+// read parses the tag at the start of r. A file without a tag is not an
+// error, it just has no metadata.
+func (m *Meta) read(r io.Reader) error {
+	hdr := make([]byte, 10)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		return err
+	}
+	if string(hdr[:3]) != "ID3" {
+		return nil
+	}
+
+	m.version = hdr[3]
+	if m.version < 3 || m.version > 4 {
+		return fmt.Errorf("id3: unsupported version 2.%d", m.version)
+	}
+	flags := hdr[5]
+	m.size = syncsafe(hdr[6:10]) + 10
+	if flags&tagFooter != 0 {
+		m.size += 10
+	}
+
+	body := make([]byte, syncsafe(hdr[6:10]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return err
+	}
+
+	if flags&tagUnsync != 0 && m.version == 3 {
+		body = resync(body)
+	}
+
+	if flags&tagExtended != 0 && len(body) >= 4 {
+		var ext int
+		if m.version == 4 {
+			ext = syncsafe(body[:4])
+		} else {
+			ext = int(binary.BigEndian.Uint32(body[:4])) + 4
+		}
+		if ext > len(body) {
+			return fmt.Errorf("id3: invalid extended header")
+		}
+		body = body[ext:]
+	}
+
+	frames, err := parseFrames(body, m.version)
+	if err != nil {
+		return err
+	}
+	m.frames = frames
+
+	return m.parse()
+}
+
+func (m *Meta) parse() error {
+	var tocs []toc
+	for _, f := range m.frames {
+		data, err := f.body(m.version)
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case f.id == "CHAP":
+			ch, err := readCHAP(data, m.version)
+			if err != nil {
+				return err
+			}
+			m.chapters = append(m.chapters, ch)
+		case f.id == "CTOC":
+			t, err := readCTOC(data)
+			if err != nil {
+				return err
+			}
+			tocs = append(tocs, t)
+		case f.id == "APIC":
+			pic, err := readAPIC(data)
+			if err != nil {
+				return err
+			}
+			if len(m.cover.data) == 0 || pic.kind == 3 {
+				m.cover = pic
+			}
+		case f.id == "TXXX":
+			desc, val := readTXXX(data)
+			if desc != "" {
+				m.tags[strings.ToLower(desc)] = val
+			}
+		case f.id == "COMM":
+			m.tags["comment"] = readCOMM(data)
+		case strings.HasPrefix(f.id, "T"):
+			if key, ok := textTags[f.id]; ok {
+				m.tags[key] = readText(data)
+			}
+		}
+	}
+
+	m.chapters = orderChapters(m.chapters, tocs)
+
+	return nil
+}
+
+// orderChapters sorts chapters by the top level table of contents, or by
+// start time when there is none.
+func orderChapters(chaps []*Chapter, tocs []toc) []*Chapter {
+	for _, t := range tocs {
+		if !t.top {
+			continue
+		}
+		byID := make(map[string]*Chapter)
+		for _, ch := range chaps {
+			byID[ch.ID] = ch
+		}
+		var ordered []*Chapter
+		for _, id := range t.children {
+			if ch, ok := byID[id]; ok {
+				ordered = append(ordered, ch)
+				delete(byID, id)
+			}
+		}
+		if len(byID) == 0 {
+			return ordered
+		}
+	}
+
+	sort.SliceStable(chaps, func(i, j int) bool {
+		return chaps[i].start < chaps[j].start
+	})
+	return chaps
+}
+
+func (m Meta) Chapters() []avtools.ChapterMeta {
+	var chaps []avtools.ChapterMeta
+	for _, ch := range m.chapters {
+		chaps = append(chaps, ch)
+	}
+	return chaps
+}
+
+func (m Meta) Tags() map[string]string {
+	return m.tags
+}
+
+func (m Meta) Streams() []map[string]string {
+	var streams []map[string]string
+	if len(m.cover.data) > 0 {
+		streams = append(streams, map[string]string{
+			"index":      "0",
+			"codec_type": "video",
+			"codec_name": codecName(m.cover.mime),
+			"cover":      "true",
+		})
+	}
+	return streams
+}
+
+func (m Meta) Source() fidi.File {
+	return m.File
+}
+
+// Cover returns the front cover, or the first picture if there is none,
+// along with its mime type.
+func (m Meta) Cover() ([]byte, string) {
+	return m.cover.data, m.cover.mime
+}
+
+func codecName(mime string) string {
+	if strings.Contains(mime, "png") {
+		return "png"
+	}
+	return "mjpeg"
+}
+
+// Sniff reports whether data starts with an ID3v2 header.
+func Sniff(data []byte) bool {
+	return len(data) >= 3 && string(data[:3]) == "ID3"
+}
@@ -0,0 +1,153 @@
+package id3
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ohzqq/avtools"
+)
+
+// meta is the avtools.Meta written by the tests.
+type meta struct {
+	tags  map[string]string
+	chaps []*avtools.Chapter
+}
+
+func (m meta) Chapters() []*avtools.Chapter { return m.chaps }
+func (m meta) Tags() map[string]string      { return m.tags }
+func (m meta) Streams() []map[string]string { return nil }
+
+// audio stands in for the mpeg frames after the tag.
+var audio = append([]byte{0xff, 0xfb, 0x90, 0x00}, make([]byte, 412)...)
+
+func chapter(t *testing.T, title, ss, to string) *avtools.Chapter {
+	t.Helper()
+	ch := &avtools.Chapter{ChapTitle: title}
+	if err := ch.SS(ss); err != nil {
+		t.Fatal(err)
+	}
+	if to != "" {
+		if err := ch.To(to); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ch
+}
+
+func load(t *testing.T, name string) *Meta {
+	t.Helper()
+	m, err := Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m.(*Meta)
+}
+
+func TestSaveRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "book.mp3")
+	err := os.WriteFile(input, audio, 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags := map[string]string{
+		"title":    "A Book",
+		"artist":   "Some Body",
+		"narrator": "Reader",
+		"comment":  "read aloud",
+		"duration": "60.000000",
+	}
+	chaps := []*avtools.Chapter{
+		chapter(t, "Intro", "0", "5"),
+		chapter(t, "Ünïcode", "5", ""),
+		chapter(t, "Last", "30", ""),
+	}
+	output := filepath.Join(dir, "updated-book.mp3")
+	err = Save(input, output, meta{tags: tags, chaps: chaps})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("mode %s, want 0640", info.Mode().Perm())
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(data, audio) {
+		t.Error("audio after the tag changed")
+	}
+
+	m := load(t, output)
+	if m.version != 4 {
+		t.Errorf("version %d, want 4", m.version)
+	}
+	for k, v := range tags {
+		if k == "duration" {
+			continue
+		}
+		if m.Tags()[k] != v {
+			t.Errorf("tag %s: got %q, want %q", k, m.Tags()[k], v)
+		}
+	}
+
+	want := []struct {
+		start, end time.Duration
+		title      string
+	}{
+		{0, 5 * time.Second, "Intro"},
+		{5 * time.Second, 30 * time.Second, "Ünïcode"},
+		{30 * time.Second, time.Minute, "Last"},
+	}
+	got := m.Chapters()
+	if len(got) != len(want) {
+		t.Fatalf("got %d chapters, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Start() != w.start || got[i].End() != w.end || got[i].Title() != w.title {
+			t.Errorf("chapter %d: got %s-%s %q, want %s-%s %q", i+1,
+				got[i].Start(), got[i].End(), got[i].Title(), w.start, w.end, w.title)
+		}
+	}
+
+	// saving again in place replaces the chapters and keeps the other frames
+	err = Save(output, output, meta{
+		tags:  map[string]string{"title": "A Book"},
+		chaps: []*avtools.Chapter{chapter(t, "Only", "0", "60")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = load(t, output)
+	if got := m.Chapters(); len(got) != 1 || got[0].Title() != "Only" {
+		t.Errorf("chapters after resave %v", got)
+	}
+	if m.Tags()["title"] != "A Book" || m.Tags()["artist"] != "" {
+		t.Errorf("tags after resave %v", m.Tags())
+	}
+}
+
+func TestCTOCOrder(t *testing.T) {
+	chaps := []*Chapter{
+		{ID: "b", start: 10 * time.Second},
+		{ID: "a", start: 0},
+	}
+	ordered := orderChapters(chaps, []toc{{id: "toc", top: true, children: []string{"b", "a"}}})
+	if ordered[0].ID != "b" || ordered[1].ID != "a" {
+		t.Errorf("table of contents order: got %s, %s", ordered[0].ID, ordered[1].ID)
+	}
+
+	ordered = orderChapters(chaps, nil)
+	if ordered[0].ID != "a" || ordered[1].ID != "b" {
+		t.Errorf("start order: got %s, %s", ordered[0].ID, ordered[1].ID)
+	}
+}
@@ -0,0 +1,190 @@
+package id3
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ohzqq/avtools"
+)
+
+const padding = 1024
+
+// Save writes the tags and chapters of meta to output as an ID3v2 tag in
+// front of the untouched audio of input. Frames the tags don't cover, like
+// pictures and lyrics, are kept, and so are the images of chapters starting
+// at the same time as before. Input and output may be the same file.
+func Save(input, output string, meta avtools.Meta) error {
+	in, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	old := &Meta{tags: make(map[string]string)}
+	err = old.read(in)
+	if err != nil {
+		return err
+	}
+
+	version := old.version
+	if version == 0 {
+		version = 4
+	}
+
+	frames, err := buildFrames(old, version, meta)
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	for _, f := range frames {
+		body = append(body, f.bytes(version)...)
+	}
+	body = append(body, make([]byte, padding)...)
+
+	hdr := []byte{'I', 'D', '3', version, 0, 0, 0, 0, 0, 0}
+	putSyncsafe(hdr[6:10], len(body))
+
+	tmp, err := os.CreateTemp(filepath.Dir(output), filepath.Base(output)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// CreateTemp makes the file 0600
+	stat, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tmp.Chmod(stat.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(hdr); err != nil {
+		return err
+	}
+	if _, err := tmp.Write(body); err != nil {
+		return err
+	}
+	if _, err := in.Seek(int64(old.size), io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, in); err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	in.Close()
+
+	return os.Rename(tmp.Name(), output)
+}
+
+func buildFrames(old *Meta, version byte, meta avtools.Meta) ([]frame, error) {
+	var frames []frame
+	for _, f := range old.frames {
+		switch {
+		case f.id == "CHAP", f.id == "CTOC", f.id == "TXXX", f.id == "COMM":
+		case textTags[f.id] != "":
+		default:
+			frames = append(frames, f)
+		}
+	}
+
+	ids := make(map[string]string)
+	for id, key := range textTags {
+		ids[key] = id
+	}
+	if version == 4 {
+		ids["date"] = "TDRC"
+	} else {
+		ids["date"] = "TYER"
+	}
+
+	tags := meta.Tags()
+	var keys []string
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		val := tags[key]
+		if skipTags[key] || val == "" {
+			continue
+		}
+		switch id, ok := ids[key]; {
+		case key == "comment":
+			frames = append(frames, commFrame(version, val))
+		case ok:
+			frames = append(frames, textFrame(version, id, val))
+		default:
+			frames = append(frames, txxxFrame(version, key, val))
+		}
+	}
+
+	var dur time.Duration
+	if d, ok := tags["duration"]; ok {
+		secs, err := strconv.ParseFloat(d, 64)
+		if err == nil {
+			dur = time.Duration(secs * float64(time.Second))
+		}
+	}
+
+	chaps := toChapters(meta.Chapters(), dur)
+	if len(chaps) == 0 {
+		return frames, nil
+	}
+
+	images := make(map[time.Duration]picture)
+	for _, ch := range old.chapters {
+		images[ch.start] = ch.image
+	}
+
+	toc, err := ctocFrame(chaps)
+	if err != nil {
+		return nil, err
+	}
+	frames = append(frames, toc)
+	for _, ch := range chaps {
+		ch.image = images[ch.start]
+		frames = append(frames, chapFrame(version, ch))
+	}
+
+	return frames, nil
+}
+
+// toChapters converts chapters for writing, filling in missing ends from the
+// next start or the duration.
+func toChapters(chapters []*avtools.Chapter, dur time.Duration) []*Chapter {
+	var chaps []*Chapter
+	for i, ch := range chapters {
+		chaps = append(chaps, &Chapter{
+			ID:    fmt.Sprintf("chp%d", i),
+			start: ch.Start().Dur,
+			end:   ch.End().Dur,
+			title: strings.TrimSpace(ch.Title()),
+		})
+	}
+
+	for i, ch := range chaps {
+		if ch.end > ch.start {
+			continue
+		}
+		if i+1 < len(chaps) {
+			ch.end = chaps[i+1].start
+		} else {
+			ch.end = dur
+		}
+	}
+
+	return chaps
+}
@@ -0,0 +1,329 @@
+package labels
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ohzqq/avtools"
+	"github.com/ohzqq/fidi"
+)
+
+// Labels are chapter markers from an Audacity label track or a Reaper
+// marker and region list.
+type Labels struct {
+	fidi.File
+	Labels []*Label
+}
+
+// Label is a point or region label. A point label ends at the start of the
+// next one.
+type Label struct {
+	Name  string
+	start time.Duration
+	end   time.Duration
+}
+
+// Load reads a Reaper csv, by its extension or header, or an Audacity label
+// track otherwise.
+func Load(file string) (avtools.Metaz, error) {
+	src, err := avtools.NewFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Labels{
+		File: src,
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return l, err
+	}
+
+	if strings.EqualFold(filepath.Ext(file), ".csv") || SniffReaper(data) {
+		err = l.ParseReaper(bytes.NewReader(data))
+	} else {
+		err = l.Parse(bytes.NewReader(data))
+	}
+	if err != nil {
+		return l, err
+	}
+
+	return l, nil
+}
+
+// Parse reads tab separated start, end and label lines, in seconds, as
+// exported by Audacity. Spectral selection lines are skipped.
+func (l *Labels) Parse(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "\\") {
+			continue
+		}
+
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) < 2 {
+			return fmt.Errorf("labels: line %d: expected start, end and label", n)
+		}
+
+		start, err := parseSeconds(fields[0])
+		if err != nil {
+			return fmt.Errorf("labels: line %d: %w", n, err)
+		}
+		end, err := parseSeconds(fields[1])
+		if err != nil {
+			return fmt.Errorf("labels: line %d: %w", n, err)
+		}
+
+		label := &Label{start: start, end: end}
+		if len(fields) == 3 {
+			label.Name = fields[2]
+		}
+		l.Labels = append(l.Labels, label)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	l.setEnds()
+	return nil
+}
+
+// ParseReaper reads a marker and region list exported from Reaper's region
+// manager, with the ruler in seconds or minutes:seconds.
+func (l *Labels) ParseReaper(r io.Reader) error {
+	rd := csv.NewReader(r)
+	rd.FieldsPerRecord = -1
+	rd.TrimLeadingSpace = true
+
+	records, err := rd.ReadAll()
+	if err != nil {
+		return fmt.Errorf("labels: %w", err)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("labels: empty marker list")
+	}
+
+	cols := make(map[string]int)
+	for i, h := range records[0] {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, c := range []string{"name", "start"} {
+		if _, ok := cols[c]; !ok {
+			return fmt.Errorf("labels: marker list has no %s column", c)
+		}
+	}
+	field := func(rec []string, col string) string {
+		if i, ok := cols[col]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+
+	for n, rec := range records[1:] {
+		start, err := ParseTime(field(rec, "start"))
+		if err != nil {
+			return fmt.Errorf("labels: row %d: %w", n+2, err)
+		}
+		label := &Label{
+			Name:  field(rec, "name"),
+			start: start,
+			end:   start,
+		}
+		if e := field(rec, "end"); e != "" {
+			label.end, err = ParseTime(e)
+			if err != nil {
+				return fmt.Errorf("labels: row %d: %w", n+2, err)
+			}
+		}
+		l.Labels = append(l.Labels, label)
+	}
+
+	sort.SliceStable(l.Labels, func(i, j int) bool {
+		return l.Labels[i].start < l.Labels[j].start
+	})
+	l.setEnds()
+
+	return nil
+}
+
+// setEnds ends point labels at the start of the next label.
+func (l *Labels) setEnds() {
+	for i, label := range l.Labels {
+		if label.end > label.start {
+			continue
+		}
+		label.end = 0
+		if i+1 < len(l.Labels) {
+			label.end = l.Labels[i+1].start
+		}
+	}
+}
+
+// Dump writes the chapters of meta as an Audacity label track of region
+// labels.
+func Dump(meta avtools.Meta) []byte {
+	var buf bytes.Buffer
+	for _, ch := range chapters(meta) {
+		fmt.Fprintf(&buf, "%s\t%s\t%s\n",
+			formatSeconds(ch.start),
+			formatSeconds(ch.end),
+			ch.Name,
+		)
+	}
+	return buf.Bytes()
+}
+
+// DumpReaper writes the chapters of meta as a Reaper region list.
+func DumpReaper(meta avtools.Meta) []byte {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"#", "Name", "Start", "End", "Length"})
+	for i, ch := range chapters(meta) {
+		w.Write([]string{
+			fmt.Sprintf("R%d", i+1),
+			ch.Name,
+			FormatTime(ch.start),
+			FormatTime(ch.end),
+			FormatTime(ch.end - ch.start),
+		})
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+// chapters converts the chapters of meta to regions, ending them at the
+// next start or the duration.
+func chapters(meta avtools.Meta) []*Label {
+	var dur time.Duration
+	if d, err := strconv.ParseFloat(meta.Tags()["duration"], 64); err == nil {
+		dur = time.Duration(d * float64(time.Second))
+	}
+
+	chaps := meta.Chapters()
+	var labels []*Label
+	for i, ch := range chaps {
+		label := &Label{
+			Name:  ch.Title(),
+			start: ch.Start().Dur,
+			end:   ch.End().Dur,
+		}
+		if label.end <= label.start {
+			label.end = dur
+			if i+1 < len(chaps) {
+				label.end = chaps[i+1].Start().Dur
+			}
+		}
+		if label.end < label.start {
+			label.end = label.start
+		}
+		labels = append(labels, label)
+	}
+	return labels
+}
+
+func (l Labels) Chapters() []avtools.ChapterMeta {
+	var chaps []avtools.ChapterMeta
+	for _, label := range l.Labels {
+		chaps = append(chaps, label)
+	}
+	return chaps
+}
+
+func (l Labels) Tags() map[string]string {
+	return map[string]string{}
+}
+
+func (l Labels) Streams() []map[string]string {
+	return []map[string]string{}
+}
+
+func (l Labels) Source() fidi.File {
+	return l.File
+}
+
+func (l Label) Start() time.Duration {
+	return l.start
+}
+
+func (l Label) End() time.Duration {
+	return l.end
+}
+
+func (l Label) Title() string {
+	return l.Name
+}
+
+func parseSeconds(s string) (time.Duration, error) {
+	secs, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || secs < 0 {
+		return 0, fmt.Errorf("%w %q", avtools.ErrInvalidTimestamp, s)
+	}
+	return time.Duration(secs * float64(time.Second)).Round(time.Microsecond), nil
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 6, 64)
+}
+
+// ParseTime parses seconds or a [h:]m:ss.fff time.
+func ParseTime(t string) (time.Duration, error) {
+	split := strings.Split(t, ":")
+	if len(split) > 3 {
+		return 0, fmt.Errorf("%w %q", avtools.ErrInvalidTimestamp, t)
+	}
+
+	secs, err := parseSeconds(split[len(split)-1])
+	if err != nil {
+		return 0, err
+	}
+
+	var d time.Duration
+	for _, s := range split[:len(split)-1] {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%w %q", avtools.ErrInvalidTimestamp, t)
+		}
+		d = d*60 + time.Duration(n)
+	}
+
+	return d*time.Minute + secs, nil
+}
+
+// FormatTime formats d as h:mm:ss.fff.
+func FormatTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%d:%02d:%02d.%03d",
+		ms/3600000,
+		ms/60000%60,
+		ms/1000%60,
+		ms%1000,
+	)
+}
+
+var labelLine = regexp.MustCompile(`^\d+(\.\d+)?\t\d+(\.\d+)?(\t|\r?$)`)
+
+// Sniff reports whether the first line of data is an Audacity label.
+func Sniff(data []byte) bool {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	return labelLine.Match(line)
+}
+
+// SniffReaper reports whether data starts with a Reaper marker list header.
+func SniffReaper(data []byte) bool {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	return bytes.HasPrefix(bytes.ToLower(data), []byte("#,name,"))
+}
@@ -0,0 +1,552 @@
+package matroska
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ohzqq/avtools"
+	"github.com/ohzqq/fidi"
+)
+
+const Header = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE Chapters SYSTEM "matroskachapters.dtd">
+`
+
+// Chapters is an mkvmerge style chapter file.
+type Chapters struct {
+	fidi.File `xml:"-"`
+	XMLName   xml.Name  `xml:"Chapters"`
+	Editions  []Edition `xml:"EditionEntry"`
+}
+
+type Edition struct {
+	UID     uint64 `xml:"EditionUID,omitempty"`
+	Hidden  int    `xml:"EditionFlagHidden"`
+	Default int    `xml:"EditionFlagDefault"`
+	Ordered int    `xml:"EditionFlagOrdered,omitempty"`
+	Atoms   []Atom `xml:"ChapterAtom"`
+}
+
+type Atom struct {
+	UID       uint64    `xml:"ChapterUID,omitempty"`
+	TimeStart string    `xml:"ChapterTimeStart"`
+	TimeEnd   string    `xml:"ChapterTimeEnd,omitempty"`
+	Hidden    int       `xml:"ChapterFlagHidden"`
+	Enabled   int       `xml:"ChapterFlagEnabled"`
+	Displays  []Display `xml:"ChapterDisplay"`
+	Atoms     []Atom    `xml:"ChapterAtom"`
+	start     time.Duration
+	end       time.Duration
+}
+
+type Display struct {
+	String   string   `xml:"ChapterString"`
+	Language []string `xml:"ChapterLanguage"`
+	IETF     []string `xml:"ChapLanguageIETF,omitempty"`
+	Country  []string `xml:"ChapterCountry,omitempty"`
+}
+
+func Load(file string) (avtools.Metaz, error) {
+	src, err := avtools.NewFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := &Chapters{
+		File: src,
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return ch, err
+	}
+
+	err = ch.Parse(data)
+	if err != nil {
+		return ch, err
+	}
+
+	return ch, nil
+}
+
+func (c *Chapters) Parse(data []byte) error {
+	err := xml.Unmarshal(data, c)
+	if err != nil {
+		return fmt.Errorf("matroska chapters: %w", err)
+	}
+
+	for i := range c.Editions {
+		err := parseTimes(c.Editions[i].Atoms)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseTimes parses the start and end of atoms, setting a missing end to
+// the start of the next sibling.
+func parseTimes(atoms []Atom) error {
+	for i := range atoms {
+		a := &atoms[i]
+
+		var err error
+		a.start, err = ParseTime(a.TimeStart)
+		if err != nil {
+			return err
+		}
+		if a.TimeEnd != "" {
+			a.end, err = ParseTime(a.TimeEnd)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = parseTimes(a.Atoms)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range atoms {
+		if atoms[i].TimeEnd == "" && i+1 < len(atoms) {
+			atoms[i].end = atoms[i+1].start
+		}
+	}
+
+	return nil
+}
+
+// Edition returns the default edition, or the first one if none is marked
+// as default.
+func (c Chapters) Edition() (Edition, bool) {
+	for _, e := range c.Editions {
+		if e.Default == 1 {
+			return e, true
+		}
+	}
+	if len(c.Editions) > 0 {
+		return c.Editions[0], true
+	}
+	return Edition{}, false
+}
+
+// Chapters returns the enabled, visible atoms of the default edition. An
+// atom with such atoms nested in it is replaced by them, and they keep it in
+// their tags, see chapter.Tags, so Dump can nest them again.
+func (c Chapters) Chapters() []avtools.ChapterMeta {
+	ed, ok := c.Edition()
+	if !ok {
+		return []avtools.ChapterMeta{}
+	}
+	return flatten(ed.Atoms, nil)
+}
+
+func flatten(atoms []Atom, parents []Atom) []avtools.ChapterMeta {
+	var chaps []avtools.ChapterMeta
+	for _, a := range atoms {
+		if a.Hidden == 1 || a.Enabled == 0 {
+			continue
+		}
+		nested := flatten(a.Atoms, append(parents[:len(parents):len(parents)], a))
+		if len(nested) > 0 {
+			chaps = append(chaps, nested...)
+			continue
+		}
+		chaps = append(chaps, chapter{Atom: a, parents: parents})
+	}
+	return chaps
+}
+
+// chapter is an atom nested in parents, outermost first.
+type chapter struct {
+	Atom
+	parents []Atom
+}
+
+// Tags adds the parents of the atom to its tags: parent holds their uids,
+// outermost first and separated by slashes, and the titles and languages of
+// each are kept as parent-<uid>-title, parent-<uid>-language and so on, see
+// Atom.Tags.
+func (c chapter) Tags() map[string]string {
+	tags := c.Atom.Tags()
+	if len(c.parents) == 0 {
+		return tags
+	}
+	var ids []string
+	for _, p := range c.parents {
+		id := strconv.FormatUint(p.key(), 10)
+		ids = append(ids, id)
+		for k, v := range displayTags(p.Displays) {
+			tags["parent-"+id+"-"+k] = v
+		}
+	}
+	tags["parent"] = strings.Join(ids, "/")
+	return tags
+}
+
+func (c Chapters) Tags() map[string]string {
+	return map[string]string{}
+}
+
+func (c Chapters) Streams() []map[string]string {
+	return []map[string]string{}
+}
+
+func (c Chapters) Source() fidi.File {
+	return c.File
+}
+
+// UnmarshalXML defaults ChapterFlagEnabled to 1, as the spec does.
+func (a *Atom) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type atom Atom
+	v := atom{Enabled: 1}
+	err := d.DecodeElement(&v, &start)
+	if err != nil {
+		return err
+	}
+	*a = Atom(v)
+	return nil
+}
+
+func (a Atom) Start() time.Duration {
+	return a.start
+}
+
+func (a Atom) End() time.Duration {
+	return a.end
+}
+
+// Title is the string of the first ChapterDisplay.
+func (a Atom) Title() string {
+	if len(a.Displays) > 0 {
+		return a.Displays[0].String
+	}
+	return ""
+}
+
+// Tags holds the displays of the atom, see displayTags, without the title,
+// and the chapter uid.
+func (a Atom) Tags() map[string]string {
+	tags := displayTags(a.Displays)
+	delete(tags, "title")
+	if a.UID != 0 {
+		tags["uid"] = strconv.FormatUint(a.UID, 10)
+	}
+	return tags
+}
+
+// key is the uid of the atom, or one derived from its start and title.
+func (a Atom) key() uint64 {
+	if a.UID != 0 {
+		return a.UID
+	}
+	return hash(fmt.Sprintf("%d-%s", a.start, a.Title()))
+}
+
+// displayTags holds the string of the first display as title, and its
+// languages, IETF languages and countries as language, ietf and country,
+// several of them separated by commas. Every other display is kept as
+// title-<lang>, by its first language, with ".<n>" added when the language
+// was taken already; its languages, when there are more than one, IETF
+// languages and countries are kept as title-<lang>-language and so on, and
+// their order as the <lang> of each in titles.
+func displayTags(displays []Display) map[string]string {
+	tags := make(map[string]string)
+	var order []string
+	for i, d := range displays {
+		key := "title"
+		if i > 0 {
+			lang := "eng"
+			if len(d.Language) > 0 {
+				lang = d.Language[0]
+			}
+			key = "title-" + lang
+			if _, ok := tags[key]; ok {
+				key += "." + strconv.Itoa(i)
+			}
+			order = append(order, strings.TrimPrefix(key, "title-"))
+		}
+		tags[key] = d.String
+
+		attr := key + "-"
+		if i == 0 {
+			attr = ""
+		}
+		if len(d.Language) > 0 && (i == 0 || len(d.Language) > 1) {
+			tags[attr+"language"] = strings.Join(d.Language, ",")
+		}
+		if len(d.IETF) > 0 {
+			tags[attr+"ietf"] = strings.Join(d.IETF, ",")
+		}
+		if len(d.Country) > 0 {
+			tags[attr+"country"] = strings.Join(d.Country, ",")
+		}
+	}
+	if len(order) > 0 {
+		tags["titles"] = strings.Join(order, ",")
+	}
+	return tags
+}
+
+// displays makes the displays kept in tags by displayTags, with their keys
+// prefixed by prefix, titling the first one title. The other displays are
+// in the order of titles, followed by any missing from it sorted by their
+// keys.
+func displays(title string, tags map[string]string, prefix string) []Display {
+	first := Display{
+		String:   title,
+		Language: split(tags[prefix+"language"]),
+		IETF:     split(tags[prefix+"ietf"]),
+		Country:  split(tags[prefix+"country"]),
+	}
+	if len(first.Language) == 0 {
+		first.Language = []string{"eng"}
+	}
+
+	order := make(map[string]bool)
+	var keys []string
+	for _, key := range split(tags[prefix+"titles"]) {
+		if _, ok := tags[prefix+"title-"+key]; ok && !order[key] {
+			order[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	var rest []string
+	for k := range tags {
+		key := strings.TrimPrefix(k, prefix+"title-")
+		if key == k || order[key] ||
+			strings.HasSuffix(key, "-language") ||
+			strings.HasSuffix(key, "-ietf") ||
+			strings.HasSuffix(key, "-country") {
+			continue
+		}
+		rest = append(rest, key)
+	}
+	sort.Strings(rest)
+	keys = append(keys, rest...)
+
+	ds := []Display{first}
+	for _, key := range keys {
+		k := prefix + "title-" + key
+		lang, _, _ := strings.Cut(key, ".")
+		d := Display{
+			String:   tags[k],
+			Language: split(tags[k+"-language"]),
+			IETF:     split(tags[k+"-ietf"]),
+			Country:  split(tags[k+"-country"]),
+		}
+		if len(d.Language) == 0 {
+			d.Language = []string{lang}
+		}
+		ds = append(ds, d)
+	}
+	return ds
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// node is an atom being nested by Dump.
+type node struct {
+	atom Atom
+	id   string
+	kids []*node
+}
+
+// Atom returns the atom with its nested atoms. An atom with nested ones
+// spans them.
+func (n *node) Atom() Atom {
+	a := n.atom
+	for _, k := range n.kids {
+		a.Atoms = append(a.Atoms, k.Atom())
+	}
+	if len(a.Atoms) > 0 {
+		a.TimeStart = a.Atoms[0].TimeStart
+		a.TimeEnd = a.Atoms[len(a.Atoms)-1].TimeEnd
+	}
+	return a
+}
+
+// Dump writes the chapters of meta as a single default edition, nesting
+// the ones tagged with parents, see chapter.Tags, in atoms made from those
+// tags.
+func Dump(meta avtools.Meta) ([]byte, error) {
+	var (
+		roots []*node
+		open  []*node
+	)
+	add := func(n *node) {
+		if len(open) == 0 {
+			roots = append(roots, n)
+			return
+		}
+		top := open[len(open)-1]
+		top.kids = append(top.kids, n)
+	}
+
+	for i, ch := range meta.Chapters() {
+		var path []string
+		if p := ch.Tags["parent"]; p != "" {
+			path = strings.Split(p, "/")
+		}
+		n := 0
+		for n < len(open) && n < len(path) && open[n].id == path[n] {
+			n++
+		}
+		open = open[:n]
+		for _, id := range path[n:] {
+			prefix := "parent-" + id + "-"
+			p := &node{
+				id: id,
+				atom: Atom{
+					UID:      parentUID(id),
+					Enabled:  1,
+					Displays: displays(ch.Tags[prefix+"title"], ch.Tags, prefix),
+				},
+			}
+			add(p)
+			open = append(open, p)
+		}
+
+		atom := Atom{
+			UID:       uid(ch.Tags["uid"], i, ch),
+			TimeStart: FormatTime(ch.Start().Dur),
+			Enabled:   1,
+			Displays:  displays(ch.Title(), ch.Tags, ""),
+		}
+		if end := ch.End().Dur; end > ch.Start().Dur {
+			atom.TimeEnd = FormatTime(end)
+		}
+		add(&node{atom: atom})
+	}
+
+	ed := Edition{
+		Default: 1,
+	}
+	for _, n := range roots {
+		ed.Atoms = append(ed.Atoms, n.Atom())
+	}
+	ed.UID = editionUID(ed.Atoms)
+
+	chaps := Chapters{Editions: []Edition{ed}}
+
+	var buf bytes.Buffer
+	buf.WriteString(Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	err := enc.Encode(chaps)
+	if err != nil {
+		return nil, fmt.Errorf("matroska chapters: %w", err)
+	}
+	buf.WriteString("\n")
+
+	return buf.Bytes(), nil
+}
+
+// ParseTime parses a HH:MM:SS.nnnnnnnnn timestamp.
+func ParseTime(t string) (time.Duration, error) {
+	t = strings.TrimSpace(t)
+	split := strings.Split(t, ":")
+	if len(split) != 3 {
+		return 0, fmt.Errorf("matroska chapters: %w %q", avtools.ErrInvalidTimestamp, t)
+	}
+
+	hh, err := strconv.Atoi(split[0])
+	if err != nil {
+		return 0, fmt.Errorf("matroska chapters: %w %q", avtools.ErrInvalidTimestamp, t)
+	}
+	mm, err := strconv.Atoi(split[1])
+	if err != nil {
+		return 0, fmt.Errorf("matroska chapters: %w %q", avtools.ErrInvalidTimestamp, t)
+	}
+
+	secs, frac, _ := strings.Cut(split[2], ".")
+	ss, err := strconv.Atoi(secs)
+	if err != nil {
+		return 0, fmt.Errorf("matroska chapters: %w %q", avtools.ErrInvalidTimestamp, t)
+	}
+
+	var ns int
+	if frac != "" {
+		if len(frac) > 9 {
+			frac = frac[:9]
+		}
+		frac += strings.Repeat("0", 9-len(frac))
+		ns, err = strconv.Atoi(frac)
+		if err != nil {
+			return 0, fmt.Errorf("matroska chapters: %w %q", avtools.ErrInvalidTimestamp, t)
+		}
+	}
+
+	d := time.Duration(hh)*time.Hour +
+		time.Duration(mm)*time.Minute +
+		time.Duration(ss)*time.Second +
+		time.Duration(ns)
+	return d, nil
+}
+
+func FormatTime(d time.Duration) string {
+	hh := d / time.Hour
+	mm := d % time.Hour / time.Minute
+	ss := d % time.Minute / time.Second
+	ns := d % time.Second
+	return fmt.Sprintf("%02d:%02d:%02d.%09d", hh, mm, ss, ns)
+}
+
+// uid keeps an existing chapter uid, or derives a stable one from the
+// chapter's position, start and title.
+func uid(tag string, idx int, ch *avtools.Chapter) uint64 {
+	if u, err := strconv.ParseUint(tag, 10, 64); err == nil && u != 0 {
+		return u
+	}
+	return hash(fmt.Sprintf("%d-%d-%s", idx, ch.Start().Dur, ch.Title()))
+}
+
+// parentUID is the uid of the parent tagged id, or one derived from it.
+func parentUID(id string) uint64 {
+	if u, err := strconv.ParseUint(id, 10, 64); err == nil && u != 0 {
+		return u
+	}
+	return hash(id)
+}
+
+// hash is a uid derived from s, never 0.
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	if u := h.Sum64(); u != 0 {
+		return u
+	}
+	return 1
+}
+
+func editionUID(atoms []Atom) uint64 {
+	h := fnv.New64a()
+	for _, a := range atoms {
+		fmt.Fprintf(h, "%d", a.UID)
+	}
+	if u := h.Sum64(); u != 0 {
+		return u
+	}
+	return 1
+}
+
+// Sniff reports whether data holds a Chapters element with editions.
+func Sniff(data []byte) bool {
+	return bytes.Contains(data, []byte("<Chapters")) &&
+		bytes.Contains(data, []byte("<EditionEntry"))
+}
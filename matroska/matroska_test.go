@@ -0,0 +1,166 @@
+package matroska
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ohzqq/avtools"
+)
+
+const nested = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE Chapters SYSTEM "matroskachapters.dtd">
+<Chapters>
+  <EditionEntry>
+    <EditionUID>7</EditionUID>
+    <EditionFlagHidden>0</EditionFlagHidden>
+    <EditionFlagDefault>1</EditionFlagDefault>
+    <ChapterAtom>
+      <ChapterUID>1</ChapterUID>
+      <ChapterTimeStart>00:00:00.000000000</ChapterTimeStart>
+      <ChapterTimeEnd>00:01:00.000000000</ChapterTimeEnd>
+      <ChapterFlagHidden>0</ChapterFlagHidden>
+      <ChapterFlagEnabled>1</ChapterFlagEnabled>
+      <ChapterDisplay>
+        <ChapterString>Part One</ChapterString>
+        <ChapterLanguage>eng</ChapterLanguage>
+        <ChapLanguageIETF>en-GB</ChapLanguageIETF>
+      </ChapterDisplay>
+      <ChapterDisplay>
+        <ChapterString>Première partie</ChapterString>
+        <ChapterLanguage>fre</ChapterLanguage>
+        <ChapterCountry>fr</ChapterCountry>
+        <ChapterCountry>ca</ChapterCountry>
+      </ChapterDisplay>
+      <ChapterAtom>
+        <ChapterUID>2</ChapterUID>
+        <ChapterTimeStart>00:00:00.000000000</ChapterTimeStart>
+        <ChapterTimeEnd>00:00:30.000000000</ChapterTimeEnd>
+        <ChapterFlagHidden>0</ChapterFlagHidden>
+        <ChapterFlagEnabled>1</ChapterFlagEnabled>
+        <ChapterDisplay>
+          <ChapterString>One</ChapterString>
+          <ChapterLanguage>eng</ChapterLanguage>
+          <ChapterLanguage>enm</ChapterLanguage>
+        </ChapterDisplay>
+        <ChapterDisplay>
+          <ChapterString>Eins</ChapterString>
+          <ChapterLanguage>ger</ChapterLanguage>
+        </ChapterDisplay>
+        <ChapterDisplay>
+          <ChapterString>Un</ChapterString>
+          <ChapterLanguage>fre</ChapterLanguage>
+        </ChapterDisplay>
+      </ChapterAtom>
+      <ChapterAtom>
+        <ChapterUID>3</ChapterUID>
+        <ChapterTimeStart>00:00:30.000000000</ChapterTimeStart>
+        <ChapterTimeEnd>00:01:00.000000000</ChapterTimeEnd>
+        <ChapterFlagHidden>0</ChapterFlagHidden>
+        <ChapterFlagEnabled>1</ChapterFlagEnabled>
+        <ChapterDisplay>
+          <ChapterString>Two</ChapterString>
+          <ChapterLanguage>eng</ChapterLanguage>
+        </ChapterDisplay>
+        <ChapterDisplay>
+          <ChapterString>Deux</ChapterString>
+          <ChapterLanguage>fre</ChapterLanguage>
+        </ChapterDisplay>
+        <ChapterDisplay>
+          <ChapterString>Zwei</ChapterString>
+          <ChapterLanguage>ger</ChapterLanguage>
+          <ChapterLanguage>gsw</ChapterLanguage>
+        </ChapterDisplay>
+        <ChapterDisplay>
+          <ChapterString>Deux (Québec)</ChapterString>
+          <ChapterLanguage>fre</ChapterLanguage>
+          <ChapLanguageIETF>fr-CA</ChapLanguageIETF>
+        </ChapterDisplay>
+      </ChapterAtom>
+    </ChapterAtom>
+    <ChapterAtom>
+      <ChapterUID>4</ChapterUID>
+      <ChapterTimeStart>00:01:00.000000000</ChapterTimeStart>
+      <ChapterTimeEnd>00:02:00.000000000</ChapterTimeEnd>
+      <ChapterFlagHidden>0</ChapterFlagHidden>
+      <ChapterFlagEnabled>1</ChapterFlagEnabled>
+      <ChapterDisplay>
+        <ChapterString>Part Two</ChapterString>
+        <ChapterLanguage>eng</ChapterLanguage>
+      </ChapterDisplay>
+    </ChapterAtom>
+  </EditionEntry>
+</Chapters>
+`
+
+func load(t *testing.T, data string) *Chapters {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "chapters.xml")
+	err := os.WriteFile(name, []byte(data), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return meta.(*Chapters)
+}
+
+func TestNested(t *testing.T) {
+	chaps := load(t, nested).Chapters()
+	want := []struct {
+		title  string
+		parent string
+	}{
+		{"One", "1"},
+		{"Two", "1"},
+		{"Part Two", ""},
+	}
+	if len(chaps) != len(want) {
+		t.Fatalf("got %d chapters, want %d", len(chaps), len(want))
+	}
+	for i, w := range want {
+		tags := chaps[i].(avtools.ChapterTags).Tags()
+		if chaps[i].Title() != w.title || tags["parent"] != w.parent {
+			t.Errorf("chapter %d: got %q in %q, want %q in %q", i+1,
+				chaps[i].Title(), tags["parent"], w.title, w.parent)
+		}
+	}
+
+	tags := chaps[0].(avtools.ChapterTags).Tags()
+	for k, v := range map[string]string{
+		"language":                   "eng,enm",
+		"title-ger":                  "Eins",
+		"title-fre":                  "Un",
+		"parent-1-title":             "Part One",
+		"parent-1-ietf":              "en-GB",
+		"parent-1-title-fre":         "Première partie",
+		"parent-1-title-fre-country": "fr,ca",
+	} {
+		if tags[k] != v {
+			t.Errorf("tag %s: got %q, want %q", k, tags[k], v)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	src := load(t, nested)
+	data, err := Dump(avtools.NewMedia().Merge(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Chapters
+	err = got.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Editions) != 1 {
+		t.Fatalf("got %d editions", len(got.Editions))
+	}
+	if want := src.Editions[0].Atoms; !reflect.DeepEqual(got.Editions[0].Atoms, want) {
+		t.Errorf("dump\n%s\nwant\n%s", data, nested)
+	}
+}
@@ -2,7 +2,6 @@ package avtools
 
 import (
 	"fmt"
-	"log"
 	"strings"
 	"time"
 
@@ -14,11 +13,8 @@ type Media struct {
 	fidi.File
 	Filename string
 	tags     map[string]string
-	Tagz     map[string]string
 	chapters []*Chapter
-	Chaps    []*Chapter
 	streams  []map[string]string
-	Streamz  []Stream
 	Input    fidi.File
 	Output   fidi.File
 	Ini      fidi.File
@@ -27,20 +23,13 @@ type Media struct {
 	HasCover bool
 }
 
-type Stream struct {
-	CodecType string
-	CodecName string
-	Index     string
-	IsCover   bool
-}
-
+// Chapter is a titled span of media, its start and end kept as exact
+// Stamps; see Stamps, SetStamps, SS and To.
 type Chapter struct {
-	StartTime  Time
-	EndTime    Time
-	StartStamp dur.Timestamp
-	EndStamp   dur.Timestamp
-	ChapTitle  string
-	Tags       map[string]string
+	ChapTitle string
+	Tags      map[string]string
+	start     Stamp
+	end       Stamp
 }
 
 type ChapterMeta interface {
@@ -49,6 +38,17 @@ type ChapterMeta interface {
 	Title() string
 }
 
+// ChapterTags is implemented by chapters that carry metadata besides a title.
+type ChapterTags interface {
+	Tags() map[string]string
+}
+
+// ChapterStamps is implemented by chapters counted in ticks of a timebase,
+// whose times are kept exactly.
+type ChapterStamps interface {
+	Stamps() (start, end Stamp)
+}
+
 type Metaz interface {
 	Chapters() []ChapterMeta
 	Tags() map[string]string
@@ -70,20 +70,31 @@ func NewMedia() *Media {
 }
 
 func NewChapter(chap ChapterMeta) *Chapter {
-	ss, err := dur.New(chap.Start())
-	if err != nil {
-		log.Fatal(err)
+	ch := &Chapter{
+		ChapTitle: chap.Title(),
+		Tags:      make(map[string]string),
 	}
-	to, err := dur.New(chap.End())
-	if err != nil {
-		log.Fatal(err)
+	if tagged, ok := chap.(ChapterTags); ok {
+		for k, v := range tagged.Tags() {
+			ch.Tags[k] = v
+		}
 	}
-	return &Chapter{
-		ChapTitle:  chap.Title(),
-		StartStamp: ss,
-		EndStamp:   to,
-		Tags:       make(map[string]string),
+	if stamped, ok := chap.(ChapterStamps); ok {
+		ch.SetStamps(stamped.Stamps())
+	} else {
+		ch.SetStamps(stampOf(chap.Start()), stampOf(chap.End()))
+	}
+	return ch
+}
+
+// stampOf is d in milliseconds when that is exact, in nanoseconds
+// otherwise.
+func stampOf(d time.Duration) Stamp {
+	s := StampOf(d)
+	if s.Exact(Millisecond) {
+		return s.Rescale(Millisecond, RoundNearest)
 	}
+	return s
 }
 
 func NewChapters(chaps []ChapterMeta) []*Chapter {
@@ -95,6 +106,9 @@ func NewChapters(chaps []ChapterMeta) []*Chapter {
 }
 
 func (m *Media) SetMeta(meta Meta) *Media {
+	if m.tags == nil {
+		m.tags = make(map[string]string)
+	}
 	for key, val := range meta.Tags() {
 		m.tags[key] = val
 	}
@@ -103,6 +117,24 @@ func (m *Media) SetMeta(meta Meta) *Media {
 	return m
 }
 
+// Merge copies the tags of meta, and its chapters and streams when it has
+// any, so sidecar files with only chapters or tags keep the rest.
+func (m *Media) Merge(meta Metaz) *Media {
+	if chaps := meta.Chapters(); len(chaps) > 0 {
+		m.chapters = NewChapters(chaps)
+	}
+	if m.tags == nil {
+		m.tags = make(map[string]string)
+	}
+	for key, val := range meta.Tags() {
+		m.tags[key] = val
+	}
+	if streams := meta.Streams(); len(streams) > 0 {
+		m.streams = streams
+	}
+	return m
+}
+
 func (m Media) Chapters() []*Chapter {
 	return m.chapters
 }
@@ -121,35 +153,98 @@ func (m Media) Streams() []map[string]string {
 }
 
 func (m Media) GetTag(key string) string {
-	if val, ok := m.Tagz[key]; ok {
+	if val, ok := m.tags[key]; ok {
 		return val
 	}
 
 	return ""
 }
 
+// Timebase is the timebase of the chapter's stamps, or 1/1000 for chapters
+// without any.
 func (ch Chapter) Timebase() string {
-	return "1/1000"
+	return ch.base().String()
 }
 
-func (ch *Chapter) SS(ss string) *Chapter {
-	dur := ParseStamp(ss)
-	ch.StartTime = Timestamp(dur)
-	return ch
+func (ch Chapter) base() Timebase {
+	switch {
+	case ch.start.Valid():
+		return ch.start.Base
+	case ch.end.Valid():
+		return ch.end.Base
+	}
+	return Millisecond
 }
 
-func (ch *Chapter) To(to string) *Chapter {
-	dur := ParseStamp(to)
-	ch.EndTime = Timestamp(dur)
-	return ch
+// SS sets the start of the chapter, parsing timecodes at the optional
+// frame rate.
+func (ch *Chapter) SS(ss string, rate ...Rate) error {
+	s, err := parseStamp(ss, rate...)
+	if err != nil {
+		return err
+	}
+	ch.start = s
+	return nil
+}
+
+// To sets the end of the chapter, parsing timecodes at the optional frame
+// rate.
+func (ch *Chapter) To(to string, rate ...Rate) error {
+	s, err := parseStamp(to, rate...)
+	if err != nil {
+		return err
+	}
+	ch.end = s
+	return nil
+}
+
+// parseStamp is ParseStamp keeping timecodes exact, in frames.
+func parseStamp(t string, rate ...Rate) (Stamp, error) {
+	if IsTimecode(t) {
+		var r Rate
+		if len(rate) > 0 {
+			r = rate[0]
+		}
+		return ParseTimecode(t, r)
+	}
+	d, err := ParseStamp(t)
+	if err != nil {
+		return Stamp{}, err
+	}
+	return stampOf(d), nil
+}
+
+// SetStamps sets the exact start and end of the chapter.
+func (ch *Chapter) SetStamps(start, end Stamp) {
+	ch.start = start
+	ch.end = end
+}
+
+// Stamps returns the start and end of the chapter. Unset ones are 0 in the
+// timebase of the other.
+func (ch Chapter) Stamps() (start, end Stamp) {
+	start, end = ch.start, ch.end
+	if !start.Valid() {
+		start = NewStamp(0, ch.base())
+	}
+	if !end.Valid() {
+		end = NewStamp(0, ch.base())
+	}
+	return start, end
 }
 
+// Start is the start of the chapter as a duration.
 func (ch Chapter) Start() dur.Timestamp {
-	return ch.StartStamp
+	ss, _ := ch.Stamps()
+	ts, _ := dur.New(ss.Duration())
+	return ts
 }
 
+// End is the end of the chapter as a duration.
 func (ch Chapter) End() dur.Timestamp {
-	return ch.StartStamp
+	_, to := ch.Stamps()
+	ts, _ := dur.New(to.Duration())
+	return ts
 }
 
 func (ch Chapter) Title() string {
@@ -160,7 +255,7 @@ func IsPlainText(mtype string) error {
 	if strings.Contains(mtype, "text/plain") {
 		return nil
 	}
-	return fmt.Errorf("needs to be plain text file")
+	return fmt.Errorf("%w: %s", ErrNotPlainText, mtype)
 }
 
 //func (ch Chapter) Dur() (Time, error) {
@@ -0,0 +1,202 @@
+package media
+
+import (
+	"fmt"
+
+	"github.com/ohzqq/avtools"
+	"github.com/ohzqq/avtools/codec"
+	"github.com/ohzqq/avtools/ff"
+	"github.com/ohzqq/avtools/scene"
+	"github.com/ohzqq/avtools/silence"
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// EditChapters applies edit to the chapters of input. Media files are
+// updated like Update, chapters coming from a metadata file flag when one
+// is set; metadata files are written next to the input with an -edited
+// suffix, in their own format.
+func (cmd Command) EditChapters(input string, edit func(*avtools.Chapters) error) (Cmd, error) {
+	f, err := codec.Detect(input)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.Encode == nil {
+		m, err := cmd.updateMeta(input)
+		if err != nil {
+			return nil, err
+		}
+		chaps := avtools.Chapters(m.Chapters())
+		err = edit(&chaps)
+		if err != nil {
+			return nil, err
+		}
+		m.SetChapters(chaps)
+		m.MetaChanged = true
+		return UpdateCmd{Media: m}, nil
+	}
+
+	if f.Decode == nil {
+		return nil, fmt.Errorf("%w: %s can't be read", avtools.ErrUnsupported, f.Name)
+	}
+	file, err := NewFile(input)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := f.Decode(file.Abs)
+	if err != nil {
+		return nil, err
+	}
+	m := avtools.NewMedia().Merge(meta)
+
+	chaps := avtools.Chapters(m.Chapters())
+	err = edit(&chaps)
+	if err != nil {
+		return nil, err
+	}
+	m.SetChapters(chaps)
+
+	data, err := codec.Encode(f.Name, m)
+	if err != nil {
+		return nil, err
+	}
+	out := file.NewName().Suffix("-edited").WithExt(file.Ext)
+	err = out.Save(data)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Chapterize makes chapters for input from its silences. They are written
+// as a cue sheet or ffmetadata with Flags.Bool.Cue or Flags.Bool.Meta, and
+// embedded in an updated copy of input otherwise.
+func (cmd Command) Chapterize(input string, opts silence.Options) ([]Cmd, error) {
+	m, err := cmd.load(input)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := avtools.ParseStamp(m.GetTag("duration"))
+	if err != nil {
+		return nil, err
+	}
+
+	pause := silence.Pause
+	if opts.Silence > 0 && opts.Silence < pause {
+		pause = opts.Silence
+	}
+	silences, err := silence.DetectContext(cmd.ctx(), m.Input.Abs, opts.Noise, pause)
+	if err != nil {
+		return nil, err
+	}
+
+	m.SetChapters(silence.Chapters(silences, d, opts))
+	m.MetaChanged = true
+
+	var cmds []Cmd
+	for _, f := range []struct {
+		on     bool
+		format string
+	}{
+		{cmd.Flags.Bool.Cue, "cue"},
+		{cmd.Flags.Bool.Meta, "ini"},
+	} {
+		if !f.on {
+			continue
+		}
+		c, err := m.SaveMetaFmt(f.format)
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, c)
+	}
+	if len(cmds) == 0 {
+		cmds = append(cmds, UpdateCmd{Media: m})
+	}
+
+	return cmds, nil
+}
+
+// Scenes makes chapters for the video input at its scene changes. They are
+// written as ffmetadata, matroska xml or yygif clips with Flags.Bool.Meta,
+// Mkv or Clips, and embedded in an updated copy of input otherwise.
+// Flags.Bool.Thumbs adds a thumbnail of the middle of every scene.
+func (cmd Command) Scenes(input string, opts scene.Options) ([]Cmd, error) {
+	m, err := cmd.load(input)
+	if err != nil {
+		return nil, err
+	}
+	if !m.IsVideo() {
+		return nil, fmt.Errorf("%w: %s has no video", avtools.ErrUnsupported, m.Input.Base)
+	}
+
+	d, err := avtools.ParseStamp(m.GetTag("duration"))
+	if err != nil {
+		return nil, err
+	}
+
+	cuts, err := scene.DetectContext(cmd.ctx(), m.Input.Abs, opts.Threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	m.SetChapters(scene.Chapters(cuts, d, opts))
+	m.MetaChanged = true
+
+	var cmds []Cmd
+	for _, f := range []struct {
+		on     bool
+		format string
+	}{
+		{cmd.Flags.Bool.Meta, "ini"},
+		{cmd.Flags.Bool.Mkv, "mkv"},
+	} {
+		if !f.on {
+			continue
+		}
+		c, err := m.SaveMetaFmt(f.format)
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, c)
+	}
+
+	if cmd.Flags.Bool.Clips {
+		data, err := scene.DumpClips(m.Input.Abs, m.Chapters())
+		if err != nil {
+			return nil, err
+		}
+		file := m.Input.NewName().Suffix("-scenes").WithExt(".yml")
+		err = file.Save(data)
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, file)
+	}
+
+	if len(cmds) == 0 {
+		cmds = append(cmds, UpdateCmd{Media: m})
+	}
+
+	if cmd.Flags.Bool.Thumbs {
+		for i, ch := range m.Chapters() {
+			cmds = append(cmds, sceneThumb(m, i+1, ch))
+		}
+	}
+
+	return cmds, nil
+}
+
+// sceneThumb grabs the frame in the middle of a scene.
+func sceneThumb(m *Media, num int, ch *avtools.Chapter) Cmd {
+	ss, to := ch.Stamps()
+	mid := ss.Add(to.Sub(ss).Scale(1, 2, avtools.RoundDown))
+
+	f := ff.New("quiet")
+	f.In(m.Input.Abs, ffmpeg.KwArgs{"ss": mid.FFmpeg()})
+	f.Output.Set("frames:v", 1)
+	f.Output.Name("thumb-" + m.Input.Name + "-scene").Num(num).Ext(".jpg")
+
+	return f.Compile()
+}
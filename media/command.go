@@ -1,14 +1,20 @@
 package media
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"log"
-	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ohzqq/avtools"
+	"github.com/ohzqq/avtools/codec"
 	"github.com/ohzqq/avtools/ff"
+	"github.com/ohzqq/avtools/vorbis"
+	"github.com/ohzqq/avtools/youtube"
 	ffmpeg "github.com/u2takey/ffmpeg-go"
 )
 
@@ -16,14 +22,98 @@ type Cmd interface {
 	Run() error
 }
 
+// Cmds runs commands in order as one.
+type Cmds []Cmd
+
+func (c Cmds) Run() error {
+	return RunAll(context.Background(), c...)
+}
+
+func (c Cmds) RunContext(ctx context.Context) error {
+	return RunAll(ctx, c...)
+}
+
+func (c Cmds) Plan() ([]ff.Step, error) {
+	return Plan(c...)
+}
+
+// Plan returns the steps of cmds, which must all be ff.Planners.
+func Plan(cmds ...Cmd) ([]ff.Step, error) {
+	var steps []ff.Step
+	for _, c := range cmds {
+		p, ok := c.(ff.Planner)
+		if !ok {
+			return nil, fmt.Errorf("%w: can't plan %T", avtools.ErrUnsupported, c)
+		}
+		s, err := p.Plan()
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, s...)
+	}
+	return steps, nil
+}
+
+// ContextCmd is a Cmd that can be stopped, like an ff.Cmd.
+type ContextCmd interface {
+	Cmd
+	RunContext(ctx context.Context) error
+}
+
+// RunAll runs cmds in order until one fails or ctx is done, stopping the
+// running one when it is a ContextCmd.
+func RunAll(ctx context.Context, cmds ...Cmd) error {
+	for _, c := range cmds {
+		if err := ctx.Err(); err != nil {
+			return ff.Canceled(err)
+		}
+		var err error
+		if cc, ok := c.(ContextCmd); ok {
+			err = cc.RunContext(ctx)
+		} else {
+			err = c.Run()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type Command struct {
 	Flags
+	// Warn is given the problems found along the way that don't stop the
+	// command, like repaired chapters, for the caller to show.
+	Warn func(input, msg string)
+	// Context stops the ffprobe and ffmpeg runs made while commands are
+	// built, like probes and silence detection, when it is done.
+	Context context.Context
+}
+
+func (cmd Command) warn(input, msg string) {
+	if cmd.Warn != nil {
+		cmd.Warn(input, msg)
+	}
+}
+
+func (cmd Command) ctx() context.Context {
+	if cmd.Context == nil {
+		return context.Background()
+	}
+	return cmd.Context
+}
+
+// load probes input, see NewContext.
+func (cmd Command) load(input string) (*Media, error) {
+	return NewContext(cmd.ctx(), input)
 }
 
 type Flags struct {
 	Bool    Bool
 	File    Files
 	Profile string
+	Pregap  string
+	Name    string
 }
 
 type Bool struct {
@@ -31,35 +121,97 @@ type Bool struct {
 	Cue      bool
 	Cover    bool
 	Chapters bool
+	Tracks   bool
+	Mkv      bool
+	Vtt      bool
+	JSON     bool
+	Psc      bool
+	Youtube  bool
+	Labels   bool
+	Reaper   bool
+	EDL      bool
+	Strict   bool
+	Clips    bool
+	Thumbs   bool
 }
 
 type Files struct {
-	Meta  string
-	Cue   string
-	Cover string
+	Meta   string
+	Cue    string
+	Cover  string
+	Mkv    string
+	Labels string
 }
 
 type UpdateCmd struct {
 	*Media
 }
 
-func (cmd Command) updateMeta(input string) *Media {
-	m := New(input)
+func (cmd Command) updateMeta(input string) (*Media, error) {
+	m, err := cmd.load(input)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range []string{
+		cmd.Flags.File.Meta,
+		cmd.Flags.File.Cue,
+		cmd.Flags.File.Mkv,
+		cmd.Flags.File.Labels,
+	} {
+		if file != "" {
+			err := m.LoadMeta(file)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	err = cmd.checkChapters(m)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// checkChapters repairs the chapters of m, warning of the problems fixed, or
+// refuses chapters with errors when Flags.Bool.Strict is set.
+func (cmd Command) checkChapters(m *Media) error {
+	if !m.HasChapters() {
+		return nil
+	}
+
+	var d time.Duration
+	if dur := m.GetTag("duration"); dur != "" {
+		var err error
+		d, err = avtools.ParseStamp(dur)
+		if err != nil {
+			return err
+		}
+	}
 
-	switch {
-	case cmd.Flags.File.Meta != "":
-		m.LoadIni(cmd.Flags.File.Meta)
-		m.MetaChanged = true
-	case cmd.Flags.File.Cue != "":
-		m.LoadCue(cmd.Flags.File.Cue)
-		m.MetaChanged = true
+	chaps := avtools.Chapters(m.Chapters())
+	if cmd.Flags.Bool.Strict {
+		err := chaps.Validate(d).Err()
+		if err != nil {
+			return fmt.Errorf("%s: %w", m.Input.Base, err)
+		}
+	}
+	for _, p := range chaps.Repair(d) {
+		cmd.warn(m.Input.Base, p.String())
 	}
+	m.SetChapters(chaps)
 
-	return m
+	return nil
 }
 
-func (cmd Command) Thumbnail(input string, output string) Cmd {
-	m := New(input)
+func (cmd Command) Thumbnail(input string, output string) (Cmd, error) {
+	m, err := cmd.load(input)
+	if err != nil {
+		return nil, err
+	}
 
 	out := "thumb-"
 	if output != "tmp" {
@@ -76,20 +228,23 @@ func (cmd Command) Thumbnail(input string, output string) Cmd {
 
 	c := f.Compile()
 
-	return c
+	return c, nil
 }
 
-func (cmd Command) Remove(input string) Cmd {
-	m := New(input)
+func (cmd Command) Remove(input string) (Cmd, error) {
+	m, err := cmd.load(input)
+	if err != nil {
+		return nil, err
+	}
 
 	f := m.Command()
 
 	if cmd.Flags.Bool.Meta {
-		f.Input.MapMetadata("-1")
+		f.Output.MapMetadata("-1")
 	}
 
 	if cmd.Flags.Bool.Chapters {
-		f.Input.MapChapters("-1")
+		f.Output.MapChapters("-1")
 	}
 
 	if cmd.Flags.Bool.Cover {
@@ -101,21 +256,49 @@ func (cmd Command) Remove(input string) Cmd {
 	//f.Output.Set("c", "copy")
 	f.Output.Ext(m.Input.Ext).Name(name).Pad("")
 
-	return f.Compile()
+	return f.Compile(), nil
 }
 
-func (cmd Command) Extract(input string) []Cmd {
-	m := New(input)
+// Extract writes the selected metadata formats and cover next to the input.
+// Chapter formats are skipped when the input has no chapters.
+func (cmd Command) Extract(input string) ([]Cmd, error) {
+	m, err := cmd.load(input)
+	if err != nil {
+		return nil, err
+	}
 
 	var cmds []Cmd
 
-	if cmd.Flags.Bool.Cue {
-		c := m.SaveMetaFmt("cue")
-		cmds = append(cmds, c)
-	}
-
-	if cmd.Flags.Bool.Meta {
-		c := m.SaveMetaFmt("ffmeta")
+	for _, f := range []struct {
+		on     bool
+		format string
+	}{
+		{cmd.Flags.Bool.Cue, "cue"},
+		{cmd.Flags.Bool.Meta, "ffmeta"},
+		{cmd.Flags.Bool.Mkv, "mkv"},
+		{cmd.Flags.Bool.Vtt, "vtt"},
+		{cmd.Flags.Bool.JSON, "podcast"},
+		{cmd.Flags.Bool.Psc, "psc"},
+		{cmd.Flags.Bool.Youtube, "youtube"},
+		{cmd.Flags.Bool.Labels, "audacity"},
+		{cmd.Flags.Bool.Reaper, "reaper"},
+		{cmd.Flags.Bool.EDL, "edl"},
+	} {
+		if !f.on {
+			continue
+		}
+		c, err := m.SaveMetaFmt(f.format)
+		if errors.Is(err, avtools.ErrNoChapters) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if f.format == "youtube" {
+			for _, w := range youtube.Check(m) {
+				cmd.warn(m.Input.Base, "youtube: "+w)
+			}
+		}
 		cmds = append(cmds, c)
 	}
 
@@ -124,10 +307,15 @@ func (cmd Command) Extract(input string) []Cmd {
 		cmds = append(cmds, ff)
 	}
 
-	return cmds
+	return cmds, nil
+}
+
+func Join(ext string, dir ...string) (Cmd, map[string]Cmd, error) {
+	return JoinContext(context.Background(), ext, dir...)
 }
 
-func Join(ext string, dir ...string) (Cmd, map[string]Cmd) {
+// JoinContext is Join, stopping the probes of the files when ctx is done.
+func JoinContext(ctx context.Context, ext string, dir ...string) (Cmd, map[string]Cmd, error) {
 	d := "."
 	if len(dir) > 0 {
 		d = dir[0]
@@ -135,78 +323,97 @@ func Join(ext string, dir ...string) (Cmd, map[string]Cmd) {
 
 	path, err := filepath.Abs(d)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, err
 	}
 
 	files, err := filepath.Glob(path + "/*" + ext)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, err
 	}
-
-	tmp, err := os.CreateTemp("", "")
-	if err != nil {
-		log.Fatal(err)
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("no %s files in %s", ext, path)
 	}
-	defer tmp.Close()
 
-	var media []*Media
+	base := filepath.Base(d)
+
+	var (
+		media  []*Media
+		concat bytes.Buffer
+	)
+	concat.WriteString("ffconcat version 1.0\n")
 	for _, f := range files {
-		media = append(media, New(f))
-		str := "file '" + f + "'\n"
-		if _, err := tmp.WriteString(str); err != nil {
-			log.Fatal(err)
+		m, err := NewContext(ctx, f)
+		if err != nil {
+			return nil, nil, err
 		}
+		media = append(media, m)
+		concat.WriteString("file '" + strings.ReplaceAll(f, "'", `'\''`) + "'\n")
+	}
+
+	chaps, err := GenerateChapters(media)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	formats := make(map[string]Cmd)
 	tmpMedia := media[0]
-	tmpMedia.SetChapters(GenerateChapters(media))
-	formats["ini"] = tmpMedia.SaveMetaFmt("ini")
-	formats["cue"] = tmpMedia.SaveMetaFmt("cue")
-
-	cmd := ff.New("audio")
-	cmd.In(tmp.Name())
-	cmd.Input.Set("f", "concat")
-	cmd.Input.Set("safe", "0")
-	cmd.Input.Set("y", "")
-
-	if media[0].HasCover {
-		cmd.Output.Set("vn", "")
+	tmpMedia.SetChapters(chaps)
+	for _, f := range []string{"ini", "cue"} {
+		formats[f], err = tmpMedia.SaveMetaFmt(f)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
-	base := filepath.Base(d)
+	hasCover := media[0].HasCover
 	name := filepath.Join(path, base)
-	//cmd.Output.Set("c", "copy")
-	cmd.Output.Ext(ext).Name(name)
+	// the concat list is written to a temp file when the join runs
+	join := TempCmd{
+		Pattern: "avtools-join-*.txt",
+		Data:    concat.Bytes(),
+		Cmd: func(list string) *ff.Cmd {
+			cmd := ff.New("audio")
+			cmd.In(list)
+			cmd.Input.Set("f", "concat")
+			cmd.Input.Set("safe", "0")
+			cmd.Input.Set("y", "")
+
+			if hasCover {
+				cmd.Output.Set("vn", "")
+			}
+
+			//cmd.Output.Set("c", "copy")
+			cmd.Output.Ext(ext).Name(name)
+			return cmd.Compile()
+		},
+	}
 
-	return cmd.Compile(), formats
+	return join, formats, nil
 }
 
-func GenerateChapters(media []*Media) []*avtools.Chapter {
+func GenerateChapters(media []*Media) ([]*avtools.Chapter, error) {
 	var chapters []*avtools.Chapter
 
-	var start = []int64{0}
-	var end []int64
+	// durations are summed exactly and only the chapter bounds rounded, so
+	// long joins don't drift
+	total := avtools.StampOf(0)
 	for idx, m := range media {
-		d := m.GetTag("duration")
-		dur := avtools.ParseStamp(d)
-		e := start[idx] + dur.Milliseconds()
-		end = append(end, e)
-		if idx < len(media)-1 {
-			s := end[idx]
-			start = append(start, s)
+		d, err := avtools.ParseStamp(m.GetTag("duration"))
+		if err != nil {
+			return nil, err
 		}
-		ss := avtools.ParseStampDuration(start[idx], 1000)
-		to := avtools.ParseStampDuration(end[idx], 1000)
+		ss := total.Rescale(avtools.Millisecond, avtools.RoundNearest)
+		total = total.Add(avtools.StampOf(d))
+		to := total.Rescale(avtools.Millisecond, avtools.RoundNearest)
+
 		chapter := &avtools.Chapter{
-			StartTime: avtools.Timestamp(ss),
-			EndTime:   avtools.Timestamp(to),
 			ChapTitle: "Chapter " + strconv.Itoa(idx+1),
 		}
+		chapter.SetStamps(ss, to)
 		chapters = append(chapters, chapter)
 	}
 
-	return chapters
+	return chapters, nil
 }
 
 func ExtractCover(m *Media) Cmd {
@@ -235,31 +442,46 @@ func ExtractCover(m *Media) Cmd {
 	return cmd.Compile()
 }
 
-func (m Media) SaveMetaFmt(f string) Cmd {
-	var cmd Cmd
-	switch f {
-	case "ini":
-		name := m.Input.NewName()
-		file := name.WithExt(".ini")
-		file.Save(m.DumpIni())
-		cmd = file
+// SaveMetaFmt writes the metadata next to the input in the named codec
+// format, ini being the ffmeta encoder and ffmeta ffmpeg's own dump. It
+// returns ErrNoChapters for chapter formats when there are no chapters.
+func (m Media) SaveMetaFmt(format string) (Cmd, error) {
+	switch format {
 	case "ffmeta":
-		cmd = m.DumpFFMeta()
-	case "cue":
-		if m.HasChapters() {
-			name := m.Input.NewName()
-			file := name.WithExt(".cue")
-			file.Save(m.DumpCue())
-			cmd = file
-		}
+		return m.DumpFFMeta(), nil
+	case "ini":
+		format = "ffmeta"
 	}
-	return cmd
+
+	f, ok := codec.Lookup(format)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", avtools.ErrUnknownFormat, format)
+	}
+	if f.Chapters && !m.HasChapters() {
+		return nil, fmt.Errorf("%w: %s", avtools.ErrNoChapters, m.Input.Base)
+	}
+
+	data, err := codec.Encode(f.Name, m)
+	if err != nil {
+		return nil, err
+	}
+
+	file := m.Input.NewName().Suffix(f.Suffix).WithExt(f.Ext())
+	err = file.Save(data)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
 }
 
-func (cmd Command) CutStamp(input, start, end string) Cmd {
+func (cmd Command) CutStamp(input, start, end string) (Cmd, error) {
+	media, err := cmd.load(input)
+	if err != nil {
+		return nil, err
+	}
+
 	var (
 		chapter = &avtools.Chapter{}
-		media   = New(input)
 		ss      = "0"
 		to      = media.GetTag("duration")
 	)
@@ -267,25 +489,44 @@ func (cmd Command) CutStamp(input, start, end string) Cmd {
 	if start != "" {
 		ss = start
 	}
-	chapter.SS(ss)
+	err = chapter.SS(ss, media.FrameRate())
+	if err != nil {
+		return nil, err
+	}
 
 	if end != "" {
 		to = end
 	}
-	chapter.To(to)
+	err = chapter.To(to, media.FrameRate())
+	if err != nil {
+		return nil, err
+	}
 
-	return CutChapter(media, chapter)
+	ff := CutChapter(media, chapter)
+	return ff.Compile(), nil
 }
 
-func (cmd Command) CutChapter(input string, num int) Cmd {
-	media := New(input)
+func (cmd Command) CutChapter(input string, num int) (Cmd, error) {
+	media, err := cmd.load(input)
+	if err != nil {
+		return nil, err
+	}
 	chapter := media.GetChapter(num)
+	if chapter == nil {
+		return nil, fmt.Errorf("%w: no chapter %d in %s", avtools.ErrNoChapters, num, media.Input.Base)
+	}
 	ff := CutChapter(media, chapter)
-	return ff.Compile()
+	return ff.Compile(), nil
 }
 
-func (cmd Command) Split(input string) []Cmd {
-	media := cmd.updateMeta(input)
+func (cmd Command) Split(input string) ([]Cmd, error) {
+	media, err := cmd.updateMeta(input)
+	if err != nil {
+		return nil, err
+	}
+	if !media.HasChapters() {
+		return nil, fmt.Errorf("%w: %s", avtools.ErrNoChapters, media.Input.Base)
+	}
 
 	var cmds []Cmd
 	for _, chapter := range media.Chapters() {
@@ -293,22 +534,27 @@ func (cmd Command) Split(input string) []Cmd {
 		cmds = append(cmds, ch.Compile())
 	}
 
-	return cmds
+	return cmds, nil
 }
 
 func CutChapter(media *Media, chapter *avtools.Chapter) ff.Cmd {
 	out := media.Input.NewName()
 
+	ss, to := chapter.Stamps()
+
 	title := chapter.ChapTitle
 	if title == "" {
-		title = fmt.Sprintf("-%s-%s", chapter.StartTime.Dur, chapter.EndTime.Dur)
+		title = fmt.Sprintf("-%s-%s", ss.Duration(), to.Duration())
 	}
 	out.Suffix(title)
 
 	cmd := media.Command()
 
-	cmd.Input.Start(chapter.StartTime.String()).
-		End(chapter.EndTime.String())
+	cmd.Input.Start(ss.FFmpeg()).
+		End(to.FFmpeg())
+	if to.Cmp(ss) > 0 {
+		cmd.Duration = to.Sub(ss).Duration()
+	}
 
 	cmd.Output.Name(out.Join()).Pad("")
 
@@ -319,34 +565,125 @@ func CutChapter(media *Media, chapter *avtools.Chapter) ff.Cmd {
 	return cmd
 }
 
-func (cmd Command) Update(input string) Cmd {
-	m := UpdateCmd{
-		Media: cmd.updateMeta(input),
+func (cmd Command) Update(input string) (Cmd, error) {
+	m, err := cmd.updateMeta(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return UpdateCmd{Media: m}, nil
+}
+
+func isOgg(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".ogg", ".oga", ".opus":
+		return true
 	}
+	return false
+}
 
-	return m
+// oggChapters writes chapters as CHAPTERxxx comments of the audio stream,
+// whose comments are otherwise replaced by the global tags of the input
+// idx, so stale chapter comments are dropped.
+func oggChapters(cmd *ff.Cmd, idx int, chaps []*avtools.Chapter) {
+	cmd.Output.MapChapters("-1")
+	cmd.Output.Set("map_metadata:s:a", strconv.Itoa(idx)+":g")
+	var meta []string
+	for _, c := range vorbis.Comments(chaps) {
+		meta = append(meta, c[0]+"="+c[1])
+	}
+	if len(meta) > 0 {
+		cmd.Output.Set("metadata:s:a", meta)
+	}
 }
 
+// Run writes the metadata natively for formats the codec package can save
+// into, like mp3 and mp4, and through ffmpeg otherwise.
 func (up UpdateCmd) Run() error {
-	if up.MetaChanged {
-		file := up.Input.NewName()
-		file.Tmp(up.DumpIni())
-		file.Run()
-		tmp := file.file.Name()
-		cmd := up.Command()
-		cmd.Input.FFMeta(tmp)
-
-		cmd.Output.Set("c", "copy")
-		name := up.Input.NewName().Prefix("updated-").Join()
-		cmd.Output.Ext(up.Input.Ext).Name(name).Pad("")
-
-		c := cmd.Compile()
-		//fmt.Println(c.String())
-
-		err := c.Run()
-		if err != nil {
-			return err
-		}
+	return up.RunContext(context.Background())
+}
+
+// RunContext is Run, stopping ffmpeg when ctx is done.
+func (up UpdateCmd) RunContext(ctx context.Context) error {
+	if !up.MetaChanged {
+		return nil
 	}
-	return nil
+
+	if f, ok := codec.Saver(up.Input.Abs); ok {
+		return f.Save(up.Input.Abs, up.output(), up)
+	}
+
+	c, err := up.tempCmd()
+	if err != nil {
+		return err
+	}
+	return c.RunContext(ctx)
+}
+
+// Plan writes the metadata to a temp file and runs ffmpeg with it, or
+// saves it natively for formats the codec package can save into.
+func (up UpdateCmd) Plan() ([]ff.Step, error) {
+	if !up.MetaChanged {
+		return nil, nil
+	}
+
+	c, err := up.tempCmd()
+	if err != nil {
+		return nil, err
+	}
+
+	f, ok := codec.Saver(up.Input.Abs)
+	if !ok {
+		return c.Plan()
+	}
+
+	name, err := tempName(c.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	save := &ff.Save{
+		Format: f.Name,
+		Input:  up.Input.Abs,
+		Output: up.output(),
+		Meta:   name,
+	}
+	// scripts can't save natively, so they map the metadata with ffmpeg
+	script := c.Cmd(name).Layout()
+	return []ff.Step{
+		{Write: name, Data: c.Data},
+		{Save: save, Script: &script},
+		{Args: []string{"rm", "-f", name}},
+	}, nil
+}
+
+// tempCmd is the ffmpeg run with the metadata dumped to a temp file.
+func (up UpdateCmd) tempCmd() (TempCmd, error) {
+	data, err := up.Dump("ffmeta")
+	if err != nil {
+		return TempCmd{}, err
+	}
+	return TempCmd{
+		Pattern: "avtools-" + up.Input.Name + "-*.ini",
+		Data:    data,
+		Cmd:     up.command,
+	}, nil
+}
+
+func (up UpdateCmd) output() string {
+	return up.Input.NewName().Prefix("updated-").WithExt(up.Input.Ext).Join()
+}
+
+// command copies the input with the metadata of the ffmetadata file meta.
+func (up UpdateCmd) command(meta string) *ff.Cmd {
+	cmd := up.Command()
+	idx := cmd.AddInput(meta, ff.Metadata)
+
+	cmd.Output.Set("c", "copy")
+	if isOgg(up.Input.Ext) {
+		oggChapters(&cmd, idx, up.Chapters())
+	}
+	name := up.Input.NewName().Prefix("updated-").Join()
+	cmd.Output.Ext(up.Input.Ext).Name(name).Pad("")
+
+	return cmd.Compile()
 }
@@ -0,0 +1,408 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ohzqq/avtools"
+	"github.com/ohzqq/avtools/cue"
+	"github.com/ohzqq/avtools/ff"
+	"github.com/ohzqq/avtools/ff/fftest"
+	"github.com/ohzqq/avtools/silence"
+)
+
+const probeJSON = `{
+  "streams": [{"index": 0, "codec_type": "audio", "codec_name": "flac"}],
+  "format": {"filename": "book", "duration": "10.000000", "tags": {"title": "Book"}},
+  "chapters": [
+    {"time_base": "1/1000", "start": 0, "end": 4000, "tags": {"title": "One"}},
+    {"time_base": "1/1000", "start": 4000, "end": 10000, "tags": {"title": "Two"}}
+  ]
+}`
+
+const ffmeta = `;FFMETADATA1
+title=Book
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=0
+END=2500
+title=First
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=2500
+END=10000
+title=Second
+`
+
+// fake installs a fake runner answering ffprobe with probeJSON, without
+// progress so the argv is only the command's own.
+func fake(t *testing.T) *fftest.Runner {
+	t.Helper()
+	r := fftest.New().Probe(probeJSON)
+	restore := r.Install()
+	progress := ff.DefaultProgress
+	ff.DefaultProgress = nil
+	t.Cleanup(func() {
+		restore()
+		ff.DefaultProgress = progress
+	})
+	return r
+}
+
+// touch makes the named files in dir, returning the path of the first.
+func touch(t *testing.T, dir string, names ...string) string {
+	t.Helper()
+	for _, name := range names {
+		err := os.WriteFile(filepath.Join(dir, name), []byte{0, 1, 2, 3}, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, names[0])
+}
+
+func run(t *testing.T, cmds ...Cmd) {
+	t.Helper()
+	err := RunAll(context.Background(), cmds...)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ffmpegCalls returns the ffmpeg calls of r.
+func ffmpegCalls(r *fftest.Runner) [][]string {
+	var argv [][]string
+	for _, a := range r.Argv() {
+		if a[0] == "ffmpeg" {
+			argv = append(argv, a)
+		}
+	}
+	return argv
+}
+
+func TestSplit(t *testing.T) {
+	r := fake(t)
+	dir := t.TempDir()
+	input := touch(t, dir, "book.flac")
+
+	cmds, err := Command{}.Split(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(t, cmds...)
+
+	want := [][]string{
+		{"ffmpeg", "-hide_banner", "-loglevel", "error", "-ss", "00:00:00.000000", "-to", "00:00:04.000000", "-i", input, "-c:a", "copy", "-c:v", "copy", filepath.Join(dir, "bookOne.flac")},
+		{"ffmpeg", "-hide_banner", "-loglevel", "error", "-ss", "00:00:04.000000", "-to", "00:00:10.000000", "-i", input, "-c:a", "copy", "-c:v", "copy", filepath.Join(dir, "bookTwo.flac")},
+	}
+	if got := ffmpegCalls(r); !reflect.DeepEqual(got, want) {
+		t.Errorf("argv\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestCutStamp(t *testing.T) {
+	r := fake(t)
+	dir := t.TempDir()
+	input := touch(t, dir, "book.flac")
+
+	cmd, err := Command{}.CutStamp(input, "1", "2.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(t, cmd)
+
+	want := [][]string{
+		{"ffmpeg", "-hide_banner", "-loglevel", "error", "-ss", "00:00:01.000000", "-to", "00:00:02.500000", "-i", input, "-c:a", "copy", "-c:v", "copy", filepath.Join(dir, "book-1s-2.5s.flac")},
+	}
+	if got := ffmpegCalls(r); !reflect.DeepEqual(got, want) {
+		t.Errorf("argv\n got %q\nwant %q", got, want)
+	}
+}
+
+// listRunner keeps the concat lists given to ffmpeg, which are removed
+// once it has run.
+type listRunner struct {
+	*fftest.Runner
+	lists []string
+}
+
+func (r *listRunner) Run(ctx context.Context, call ff.Call) error {
+	for i, arg := range call.Args {
+		if arg == "-i" && i+1 < len(call.Args) && strings.HasSuffix(call.Args[i+1], ".txt") {
+			data, err := os.ReadFile(call.Args[i+1])
+			if err != nil {
+				return err
+			}
+			r.lists = append(r.lists, string(data))
+		}
+	}
+	return r.Runner.Run(ctx, call)
+}
+
+func TestJoin(t *testing.T) {
+	r := &listRunner{Runner: fake(t)}
+	ff.DefaultRunner = r
+	dir := filepath.Join(t.TempDir(), "book")
+	err := os.Mkdir(dir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	touch(t, dir, "it's 1.flac", "part 2.flac")
+
+	cmd, _, err := Join(".flac", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(t, cmd)
+
+	got := ffmpegCalls(r.Runner)
+	if len(got) != 1 {
+		t.Fatalf("want 1 ffmpeg call, got %q", got)
+	}
+	argv := got[0]
+	list := argv[len(argv)-6]
+	want := []string{"ffmpeg", "-f", "concat", "-hide_banner", "-loglevel", "error", "-safe", "0", "-y", "-i", list, "-c:a", "copy", "-c:v", "copy", filepath.Join(dir, "book001.flac")}
+	if !reflect.DeepEqual(argv, want) {
+		t.Errorf("argv\n got %q\nwant %q", argv, want)
+	}
+
+	wantList := "ffconcat version 1.0\n" +
+		"file '" + filepath.Join(dir, `it'\''s 1.flac`) + "'\n" +
+		"file '" + filepath.Join(dir, "part 2.flac") + "'\n"
+	if len(r.lists) != 1 || r.lists[0] != wantList {
+		t.Errorf("concat list\n got %q\nwant %q", r.lists, wantList)
+	}
+	if _, err := os.Stat(list); !os.IsNotExist(err) {
+		t.Errorf("concat list %s not removed: %v", list, err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	r := fake(t)
+	dir := t.TempDir()
+	input := touch(t, dir, "book.flac")
+	meta := filepath.Join(dir, "book.ini")
+	err := os.WriteFile(meta, []byte(ffmeta), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cmd Command
+	cmd.Flags.File.Meta = meta
+	up, err := cmd.Update(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(t, up)
+
+	got := ffmpegCalls(r)
+	if len(got) != 1 {
+		t.Fatalf("want 1 ffmpeg call, got %q", got)
+	}
+	argv := got[0]
+	tmp := argv[7]
+	want := []string{"ffmpeg", "-hide_banner", "-loglevel", "error", "-i", input, "-i", tmp, "-map_metadata", "1", "-map_chapters", "1", "-c", "copy", "-c:a", "copy", "-c:v", "copy", filepath.Join(dir, "updated-book.flac")}
+	if !reflect.DeepEqual(argv, want) {
+		t.Errorf("argv\n got %q\nwant %q", argv, want)
+	}
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Errorf("metadata file %s not removed: %v", tmp, err)
+	}
+
+	steps, err := Plan(up)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 3 || !strings.Contains(string(steps[0].Data), "title=Second") {
+		t.Errorf("plan doesn't write the new chapters: %q", steps)
+	}
+}
+
+func TestUpdateScript(t *testing.T) {
+	fake(t)
+	dir := t.TempDir()
+	input := touch(t, dir, "book.mp3")
+	meta := filepath.Join(dir, "book.ini")
+	err := os.WriteFile(meta, []byte(ffmeta), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cmd Command
+	cmd.Flags.File.Meta = meta
+	up, err := cmd.Update(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps, err := Plan(up)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 3 || steps[1].Save == nil {
+		t.Fatalf("want a native save, got %q", steps)
+	}
+
+	// the script maps the metadata with ffmpeg in place of the native save
+	var script strings.Builder
+	err = ff.Script(&script, steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "ffmpeg -hide_banner -loglevel error -i " + input + " -i " + steps[1].Save.Meta +
+		" -map_metadata 1 -map_chapters 1 -c copy"
+	if !strings.Contains(script.String(), want) {
+		t.Errorf("script doesn't update with ffmpeg:\n%s", script.String())
+	}
+
+	steps[1].Script = nil
+	err = ff.Script(&script, steps)
+	if !errors.Is(err, avtools.ErrUnsupported) {
+		t.Errorf("scripted a native save: %v", err)
+	}
+}
+
+func TestCanceledProbe(t *testing.T) {
+	fake(t)
+	input := touch(t, t.TempDir(), "book.flac")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Command{Context: ctx}.Chapterize(input, silence.Options{})
+	if !errors.Is(err, avtools.ErrCanceled) {
+		t.Errorf("got %v, want ErrCanceled", err)
+	}
+}
+
+func TestSplitRepairs(t *testing.T) {
+	const overlapping = `;FFMETADATA1
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=0
+END=6000
+title=First
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=4000
+END=10000
+title=Second
+`
+	r := fake(t)
+	dir := t.TempDir()
+	input := touch(t, dir, "book.flac")
+	meta := filepath.Join(dir, "book.ini")
+	err := os.WriteFile(meta, []byte(overlapping), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var strict Command
+	strict.Flags.File.Meta = meta
+	strict.Flags.Bool.Strict = true
+	_, err = strict.Split(input)
+	if !errors.Is(err, avtools.ErrInvalidChapters) {
+		t.Fatalf("strict split: got %v, want ErrInvalidChapters", err)
+	}
+
+	var warnings []string
+	cmd := Command{Warn: func(input, msg string) {
+		warnings = append(warnings, msg)
+	}}
+	cmd.Flags.File.Meta = meta
+	cmds, err := cmd.Split(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "overlap") {
+		t.Errorf("warnings %q", warnings)
+	}
+	run(t, cmds...)
+
+	got := ffmpegCalls(r)
+	if len(got) != 2 || got[0][7] != "00:00:04.000000" || got[1][5] != "00:00:04.000000" {
+		t.Errorf("split at the repaired times: %q", got)
+	}
+}
+
+func TestSplitTracksHTOA(t *testing.T) {
+	const sheet = `PERFORMER "Band"
+TITLE "Album"
+FILE "album.flac" WAVE
+  TRACK 01 AUDIO
+    TITLE "First"
+    INDEX 00 00:00:00
+    INDEX 01 00:02:00
+  TRACK 02 AUDIO
+    TITLE "Second"
+    INDEX 00 00:05:00
+    INDEX 01 00:06:00
+`
+	for _, tt := range []struct {
+		pregap string
+		want   [][]string
+	}{
+		{
+			pregap: PregapAppend,
+			want: [][]string{
+				{"0", "2", "00 - " + cue.HTOATitle, "0/2"},
+				{"2", "6", "01 - First", "1/2"},
+				{"6", "", "02 - Second", "2/2"},
+			},
+		},
+		{
+			pregap: PregapDrop,
+			want: [][]string{
+				{"2", "5", "01 - First", "1/2"},
+				{"6", "", "02 - Second", "2/2"},
+			},
+		},
+	} {
+		t.Run(tt.pregap, func(t *testing.T) {
+			r := fake(t)
+			dir := t.TempDir()
+			input := touch(t, dir, "album.flac")
+			cueFile := filepath.Join(dir, "album.cue")
+			err := os.WriteFile(cueFile, []byte(sheet), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var cmd Command
+			cmd.Flags.File.Cue = cueFile
+			cmd.Flags.Pregap = tt.pregap
+			cmds, err := cmd.SplitTracks(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			run(t, cmds...)
+
+			got := ffmpegCalls(r)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d tracks, want %d", len(got), len(tt.want))
+			}
+			for i, w := range tt.want {
+				args := strings.Join(got[i], " ")
+				for _, part := range []string{
+					"-ss " + w[0] + " ",
+					filepath.Join(dir, w[2]+".flac"),
+					"track=" + w[3],
+				} {
+					if !strings.Contains(args, part) {
+						t.Errorf("track %d: no %q in %s", i+1, part, args)
+					}
+				}
+				if hasTo := strings.Contains(args, "-to "+w[1]+" "); w[1] != "" && !hasTo {
+					t.Errorf("track %d: not to %ss in %s", i+1, w[1], args)
+				}
+			}
+		})
+	}
+}
@@ -1,14 +1,16 @@
 package media
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"io"
-	"log"
 	"mime"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/ohzqq/avtools/codec"
+	"github.com/ohzqq/avtools/ff"
 )
 
 type File struct {
@@ -28,10 +30,10 @@ type FileName struct {
 	file    *os.File
 }
 
-func NewFile(n string) File {
+func NewFile(n string) (File, error) {
 	abs, err := filepath.Abs(n)
 	if err != nil {
-		log.Fatal(err)
+		return File{}, err
 	}
 
 	f := File{
@@ -46,7 +48,7 @@ func NewFile(n string) File {
 
 	f.Path, f.File = filepath.Split(abs)
 
-	return f
+	return f, nil
 }
 
 func NewFileName() *FileName {
@@ -67,30 +69,17 @@ func (f File) NewName() *FileName {
 }
 
 func (f File) IsFFMeta() bool {
-	if IsPlainText(f.Mimetype) {
-		contents, err := os.Open(f.Abs)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer contents.Close()
-
-		scanner := bufio.NewScanner(contents)
-		line := 0
-		for scanner.Scan() {
-			if line == 0 && scanner.Text() == ";FFMETADATA1" {
-				return true
-				break
-			}
-		}
-	}
-	return false
+	return f.IsFormat("ffmeta")
 }
 
 func (f File) IsCue() bool {
-	if IsPlainText(f.Mimetype) {
-		return f.Ext == ".cue"
-	}
-	return false
+	return f.IsFormat("cue")
+}
+
+// IsFormat reports whether the file is detected as the named codec format.
+func (f File) IsFormat(name string) bool {
+	format, err := codec.Detect(f.Abs)
+	return err == nil && format.Name == name
 }
 
 func (f File) IsImage() bool {
@@ -129,10 +118,16 @@ func (f FileName) Write(wr io.Writer) error {
 	return nil
 }
 
+// Run writes the data to the file, creating it unless it is a temp file.
 func (f FileName) Run() error {
-	if f.file != nil {
-		defer f.file.Close()
+	if f.file == nil {
+		file, err := os.Create(f.Join())
+		if err != nil {
+			return err
+		}
+		f.file = file
 	}
+	defer f.file.Close()
 
 	err := f.Write(f.file)
 	if err != nil {
@@ -142,29 +137,90 @@ func (f FileName) Run() error {
 	return nil
 }
 
-func (f *FileName) Tmp(data []byte) {
+// Plan is the write of the file.
+func (f FileName) Plan() ([]ff.Step, error) {
+	name := f.Join()
+	if f.file != nil {
+		name = f.file.Name()
+	}
+	return []ff.Step{{Write: name, Data: f.data}}, nil
+}
+
+func (f *FileName) Tmp(data []byte) error {
 	file, err := os.CreateTemp("", f.Name)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	f.file = file
 	f.data = data
+	return nil
+}
+
+// TempCmd runs the ffmpeg command made for a temp file holding Data, like a
+// concat list or ffmetadata, and removes the file after.
+type TempCmd struct {
+	// Pattern names the temp file as in os.CreateTemp.
+	Pattern string
+	Data    []byte
+	Cmd     func(name string) *ff.Cmd
+}
+
+func (t TempCmd) Run() error {
+	return t.RunContext(context.Background())
 }
 
-func (f *FileName) Save(data []byte) {
-	file, err := os.Create(f.Join())
+func (t TempCmd) RunContext(ctx context.Context) error {
+	file, err := os.CreateTemp("", t.Pattern)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	f.file = file
-	f.data = data
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(t.Data)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return t.Cmd(file.Name()).RunContext(ctx)
+}
+
+// Plan writes the temp file under a name unique when planned, runs ffmpeg
+// and removes the file.
+func (t TempCmd) Plan() ([]ff.Step, error) {
+	name, err := tempName(t.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	run, err := t.Cmd(name).Plan()
+	if err != nil {
+		return nil, err
+	}
+	steps := []ff.Step{{Write: name, Data: t.Data}}
+	steps = append(steps, run...)
+	return append(steps, ff.Step{Args: []string{"rm", "-f", name}}), nil
 }
 
-func IsPlainText(mtype string) bool {
-	if strings.Contains(mtype, "text/plain") {
-		return true
-	} else {
-		log.Fatalln("needs to be plain text file")
+// tempName is the name of a temp file made with pattern and removed, for
+// plans to write later.
+func tempName(pattern string) (string, error) {
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
 	}
-	return false
+	name := file.Name()
+	file.Close()
+	return name, os.Remove(name)
+}
+
+func (t TempCmd) String() string {
+	return t.Cmd(filepath.Join(os.TempDir(), t.Pattern)).String()
+}
+
+// Save sets the data written to the file when it runs.
+func (f *FileName) Save(data []byte) error {
+	f.data = data
+	return nil
 }
@@ -1,11 +1,12 @@
 package media
 
 import (
+	"context"
 	"strings"
 
 	"github.com/ohzqq/avtools"
 	"github.com/ohzqq/avtools/ff"
-	"github.com/ohzqq/avtools/meta"
+	"github.com/ohzqq/avtools/probe"
 )
 
 type Media struct {
@@ -19,6 +20,9 @@ type Media struct {
 	Profile     string
 	HasCover    bool
 	MetaChanged bool
+	// Format and StreamInfo are what ffprobe reports for the input.
+	Format     probe.Format
+	StreamInfo probe.Streams
 }
 
 type Stream struct {
@@ -28,35 +32,29 @@ type Stream struct {
 	IsCover   bool
 }
 
-func New(input string) *Media {
-	m := avtools.NewMedia()
+func New(input string) (*Media, error) {
+	return NewContext(context.Background(), input)
+}
+
+// NewContext is New, stopping ffprobe when ctx is done.
+func NewContext(ctx context.Context, input string) (*Media, error) {
+	in, err := NewFile(input)
+	if err != nil {
+		return nil, err
+	}
+
 	med := &Media{
-		Media: m,
-		Input: NewFile(input),
+		Media: avtools.NewMedia(),
+		Input: in,
 	}
 	med.Output = File{FileName: med.Input.NewName()}
-	med.Probe()
-
-	return med
-}
 
-func (m *Media) LoadMeta(name string) *Media {
-	file := NewFile(name)
-	switch {
-	case file.IsFFMeta():
-		ini := meta.LoadIni(file.Abs)
-		m.Media.SetMeta(ini)
-		m.Ini = file
-	case file.IsCue():
-		cue := meta.LoadCueSheet(file.Abs)
-		m.Media.SetMeta(cue)
-		dur := m.GetTag("duration")
-		last := m.Chapters()[len(m.Chapters())-1]
-		last.EndTime = avtools.Timestamp(avtools.ParseStamp(dur))
-		m.Cue = file
+	err = med.ProbeContext(ctx)
+	if err != nil {
+		return nil, err
 	}
-	m.MetaChanged = true
-	return m
+
+	return med, nil
 }
 
 func (m Media) Command() ff.Cmd {
@@ -71,6 +69,9 @@ func (m Media) Command() ff.Cmd {
 	}
 	cmd := ff.New(pro)
 	cmd.In(m.Input.Abs)
+	if d, err := avtools.ParseStamp(m.GetTag("duration")); err == nil {
+		cmd.Duration = d
+	}
 	return cmd
 }
 
@@ -89,6 +90,13 @@ func (m Media) GetChapter(num int) *avtools.Chapter {
 	return chapter
 }
 
+// FrameRate is the frame rate of the video, used to read timecodes. It is
+// the zero Rate for audio.
+func (m Media) FrameRate() avtools.Rate {
+	r, _ := avtools.StreamRate(m.Streams())
+	return r
+}
+
 func (m Media) AudioStreams() []Stream {
 	var streams []Stream
 	for _, stream := range m.streams {
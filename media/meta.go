@@ -1,47 +1,103 @@
 package media
 
 import (
-	"html/template"
+	"context"
 
 	"github.com/ohzqq/avtools"
+	"github.com/ohzqq/avtools/codec"
 	"github.com/ohzqq/avtools/ff"
-	"github.com/ohzqq/avtools/meta"
+	"github.com/ohzqq/avtools/probe"
 )
 
-func (m *Media) LoadIni(name string) {
-	file := NewFile(name)
-	if file.IsFFMeta() {
-		ini := meta.LoadIni(file.Abs)
-		m.Media.SetMeta(ini)
-		m.Ini = file
-		m.MetaChanged = true
+// probeTags describe the input file rather than its content, so they aren't
+// taken from metadata files.
+var probeTags = []string{
+	"filename",
+	"duration",
+	"size",
+	"bit_rate",
+}
+
+// sidecar is a metadata file applied to the input.
+type sidecar struct {
+	avtools.Metaz
+}
+
+func (s sidecar) Tags() map[string]string {
+	tags := make(map[string]string)
+	for k, v := range s.Metaz.Tags() {
+		tags[k] = v
+	}
+	for _, k := range probeTags {
+		delete(tags, k)
 	}
+	return tags
 }
 
-func (m Media) DumpIni() []byte {
-	return meta.DumpIni(m)
+func (s sidecar) Streams() []map[string]string {
+	return nil
 }
 
-func (m *Media) LoadCue(name string) {
-	file := NewFile(name)
-	if file.IsCue() {
-		cue := meta.LoadCueSheet(file.Abs)
-		m.Media.SetMeta(cue)
-		dur := m.GetTag("duration")
+// LoadMeta reads the tags and chapters of a metadata file in any format
+// known to the codec package. A last chapter without an end is ended at the
+// duration of the input.
+func (m *Media) LoadMeta(name string) error {
+	file, err := NewFile(name)
+	if err != nil {
+		return err
+	}
+	f, err := codec.Detect(file.Abs)
+	if err != nil {
+		return err
+	}
+	meta, err := f.Decode(file.Abs)
+	if err != nil {
+		return err
+	}
+
+	m.Media.Merge(sidecar{meta})
+
+	if d := m.GetTag("duration"); d != "" && m.HasChapters() {
 		last := m.Chapters()[len(m.Chapters())-1]
-		last.EndTime = avtools.Timestamp(avtools.ParseStamp(dur))
+		if last.End().Dur <= last.Start().Dur {
+			end, err := avtools.ParseStamp(d)
+			if err != nil {
+				return err
+			}
+			start, _ := last.Stamps()
+			last.SetStamps(start, avtools.StampOf(end))
+		}
+	}
+
+	switch f.Name {
+	case "ffmeta":
+		m.Ini = file
+	case "cue":
 		m.Cue = file
-		m.MetaChanged = true
 	}
+	m.MetaChanged = true
+
+	return nil
+}
+
+// Dump writes the metadata in the named format.
+func (m Media) Dump(format string) ([]byte, error) {
+	return codec.Encode(format, m)
 }
 
-func (m Media) DumpCue() []byte {
-	return meta.DumpCueSheet(m.Input.Abs, m)
+func (m *Media) Probe() error {
+	return m.ProbeContext(context.Background())
 }
 
-func (m *Media) Probe() *Media {
-	p := meta.FFProbe(m.Input.Abs)
-	m.Media.SetMeta(p)
+// ProbeContext is Probe, stopping ffprobe when ctx is done.
+func (m *Media) ProbeContext(ctx context.Context) error {
+	p, err := probe.ProbeContext(ctx, m.Input.Abs)
+	if err != nil {
+		return err
+	}
+	m.Media.Merge(p)
+	m.Format = p.Format
+	m.StreamInfo = p.StreamInfo
 
 	if len(m.Media.Streams()) > 0 {
 		for _, stream := range m.Media.Streams() {
@@ -65,29 +121,10 @@ func (m *Media) Probe() *Media {
 		}
 	}
 
-	return m
+	return nil
 }
 
 func (m Media) DumpFFMeta() *ff.Cmd {
-	cmd := meta.DumpFFMeta(m.Input.Abs)
+	cmd := probe.DumpFFMeta(m.Input.Abs)
 	return cmd
 }
-
-var tmplFuncs = template.FuncMap{
-	"inc": Inc,
-}
-
-func Inc(n int) int {
-	return n + 1
-}
-
-const cueTmpl = `FILE "{{.Input.Name}}" {{.Input.Ext -}}
-{{range $idx, $ch := .Chapters}}
-TRACK {{inc $idx}} AUDIO
-{{- if eq $ch.Title ""}}
-  TITLE "Chapter {{inc $idx}}"
-{{- else}}
-  TITLE "{{$ch.Title}}"
-{{- end}}
-  INDEX 01 {{$ch.Start.MMSS}}
-{{- end -}}`
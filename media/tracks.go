@@ -0,0 +1,179 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/ohzqq/avtools/cue"
+)
+
+const (
+	PregapAppend = "append"
+	PregapDrop   = "drop"
+)
+
+const DefaultTrackName = "{{.Num}} - {{.Title}}"
+
+// TrackName is the data passed to the output filename template when
+// splitting an album image into tracks.
+type TrackName struct {
+	Num       string
+	Total     int
+	Title     string
+	Performer string
+	Album     string
+}
+
+// SplitTracks cuts a single file album image into one tagged file per cue
+// sheet track. An INDEX 00 pregap is either appended to the previous track
+// or dropped, depending on Flags.Pregap; appended, the pregap of the first
+// track, a hidden track one audio, becomes a track 00 of its own.
+func (cmd Command) SplitTracks(input string) ([]Cmd, error) {
+	if cmd.Flags.File.Cue == "" {
+		return nil, fmt.Errorf("splitting tracks requires a cue sheet")
+	}
+
+	pregap := cmd.Flags.Pregap
+	switch pregap {
+	case "":
+		pregap = PregapAppend
+	case PregapAppend, PregapDrop:
+	default:
+		return nil, fmt.Errorf("unknown pregap mode %q", pregap)
+	}
+
+	name := cmd.Flags.Name
+	if name == "" {
+		name = DefaultTrackName
+	}
+	tmpl, err := template.New("track").Parse(name)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := cue.Load(cmd.Flags.File.Cue)
+	if err != nil {
+		return nil, err
+	}
+	sheet := meta.(*cue.Sheet)
+
+	m, err := cmd.load(input)
+	if err != nil {
+		return nil, err
+	}
+	file := imageFile(sheet, m.Input.Base)
+	if file == nil || len(file.Tracks) == 0 {
+		return nil, fmt.Errorf("no tracks in %s for %s", cmd.Flags.File.Cue, m.Input.Base)
+	}
+	tracks := file.Tracks
+	if start, _, ok := file.HTOA(); ok && pregap == PregapAppend {
+		hidden := cue.NewTrack(0)
+		hidden.TrackTitle = cue.HTOATitle
+		hidden.SetIndex(1, start)
+		tracks = append([]*cue.Track{hidden}, tracks...)
+	}
+
+	total := len(sheet.Tracks())
+	pad := len(strconv.Itoa(total))
+	if pad < 2 {
+		pad = 2
+	}
+
+	tags := sheet.Tags()
+
+	var cmds []Cmd
+	for i, track := range tracks {
+		start, _ := track.Index(1)
+
+		var end cue.Frames
+		if i+1 < len(tracks) {
+			next := tracks[i+1]
+			end, _ = next.Index(1)
+			if gap, ok := next.Index(0); ok && pregap == PregapDrop {
+				end = gap
+			}
+		}
+
+		performer := track.Performer
+		if performer == "" {
+			performer = sheet.Performer
+		}
+
+		data := TrackName{
+			Num:       fmt.Sprintf("%0*d", pad, track.Number),
+			Total:     total,
+			Title:     track.Title(),
+			Performer: performer,
+			Album:     sheet.Title,
+		}
+		var buf bytes.Buffer
+		err := tmpl.Execute(&buf, data)
+		if err != nil {
+			return nil, err
+		}
+		out := m.Input.NewName()
+		out.Name = strings.ReplaceAll(buf.String(), "/", "-")
+
+		c := m.Command()
+		c.Input.Start(seconds(start))
+		if end > 0 {
+			c.Input.End(seconds(end))
+		}
+		c.Output.MapMetadata("-1")
+		c.Output.MapChapters("-1")
+
+		c.Output.Metadata("title", track.Title())
+		c.Output.Metadata("artist", performer)
+		c.Output.Metadata("album", sheet.Title)
+		c.Output.Metadata("album_artist", sheet.Performer)
+		c.Output.Metadata("track", fmt.Sprintf("%d/%d", track.Number, total))
+		c.Output.Metadata("tracktotal", strconv.Itoa(total))
+		if track.Songwriter != "" {
+			c.Output.Metadata("composer", track.Songwriter)
+		} else if sheet.Songwriter != "" {
+			c.Output.Metadata("composer", sheet.Songwriter)
+		}
+		for _, key := range []string{"genre", "date", "comment"} {
+			if val, ok := tags[key]; ok {
+				c.Output.Metadata(key, val)
+			}
+		}
+		if track.ISRC != "" {
+			c.Output.Metadata("isrc", track.ISRC)
+		}
+
+		ext := m.Input.Ext
+		switch strings.ToLower(ext) {
+		case ".ape", ".wv", ".tta":
+			c.Output.AudioCodec("flac")
+			ext = ".flac"
+		}
+		c.Output.Name(out.Join()).Pad("").Ext(ext)
+
+		cmds = append(cmds, c.Compile())
+	}
+
+	return cmds, nil
+}
+
+// imageFile returns the FILE block naming the image. Sheets with a single
+// FILE are assumed to describe the image whatever it is called.
+func imageFile(sheet *cue.Sheet, image string) *cue.File {
+	if len(sheet.Files) == 1 {
+		return sheet.Files[0]
+	}
+	for _, f := range sheet.Files {
+		if filepath.Base(f.Name) == image {
+			return f
+		}
+	}
+	return nil
+}
+
+func seconds(f cue.Frames) string {
+	return strconv.FormatFloat(f.Duration().Seconds(), 'f', -1, 64)
+}
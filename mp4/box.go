@@ -0,0 +1,247 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// box is an ISO BMFF box. Containers hold their children, everything else
+// keeps its payload as is. For full box containers like meta, data holds
+// the version and flags preceding the children.
+type box struct {
+	typ       string
+	data      []byte
+	children  []*box
+	container bool
+}
+
+var containers = map[string]bool{
+	"moov": true,
+	"trak": true,
+	"mdia": true,
+	"minf": true,
+	"stbl": true,
+	"udta": true,
+	"edts": true,
+	"dinf": true,
+	"tref": true,
+	"ilst": true,
+	"meta": true,
+}
+
+type header struct {
+	typ    string
+	size   int64
+	offset int64
+	hdr    int64
+}
+
+// readHeader reads a box header at the reader's current position. A size of
+// zero means the box runs to the end of the file.
+func readHeader(r io.ReadSeeker) (header, error) {
+	var h header
+
+	off, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return h, err
+	}
+	h.offset = off
+
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return h, err
+	}
+	h.size = int64(binary.BigEndian.Uint32(buf[:4]))
+	h.typ = string(buf[4:8])
+	h.hdr = 8
+
+	switch h.size {
+	case 1:
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return h, err
+		}
+		h.size = int64(binary.BigEndian.Uint64(buf))
+		h.hdr = 16
+	case 0:
+		end, err := r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return h, err
+		}
+		h.size = end - off
+		if _, err := r.Seek(off+h.hdr, io.SeekStart); err != nil {
+			return h, err
+		}
+	}
+
+	if h.size < h.hdr {
+		return h, fmt.Errorf("mp4: invalid size for box %q at %d", h.typ, off)
+	}
+
+	return h, nil
+}
+
+// parseBoxes parses a run of boxes held in memory.
+func parseBoxes(data []byte) ([]*box, error) {
+	var boxes []*box
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return boxes, fmt.Errorf("mp4: truncated box")
+		}
+		size := uint64(binary.BigEndian.Uint32(data[:4]))
+		typ := string(data[4:8])
+		hdr := uint64(8)
+		switch size {
+		case 1:
+			if len(data) < 16 {
+				return boxes, fmt.Errorf("mp4: truncated box %q", typ)
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			hdr = 16
+		case 0:
+			size = uint64(len(data))
+		}
+		if size < hdr || size > uint64(len(data)) {
+			return boxes, fmt.Errorf("mp4: invalid size for box %q", typ)
+		}
+
+		b, err := parseBox(typ, data[hdr:size])
+		if err != nil {
+			return boxes, err
+		}
+		boxes = append(boxes, b)
+		data = data[size:]
+	}
+	return boxes, nil
+}
+
+func parseBox(typ string, payload []byte) (*box, error) {
+	b := &box{typ: typ}
+	if !containers[typ] {
+		b.data = payload
+		return b, nil
+	}
+
+	b.container = true
+	if typ == "meta" && isFullMeta(payload) {
+		b.data = payload[:4]
+		payload = payload[4:]
+	}
+
+	children, err := parseBoxes(payload)
+	if err != nil {
+		return b, err
+	}
+	b.children = children
+
+	return b, nil
+}
+
+// isFullMeta reports whether a meta box has the version and flags of an
+// ISO full box, which QuickTime style meta boxes lack.
+func isFullMeta(payload []byte) bool {
+	if len(payload) >= 8 && string(payload[4:8]) == "hdlr" {
+		return false
+	}
+	return len(payload) >= 4
+}
+
+func (b *box) child(typ string) *box {
+	for _, c := range b.children {
+		if c.typ == typ {
+			return c
+		}
+	}
+	return nil
+}
+
+// find follows a path of box types, returning the first match.
+func (b *box) find(path ...string) *box {
+	cur := b
+	for _, typ := range path {
+		if cur = cur.child(typ); cur == nil {
+			return nil
+		}
+	}
+	return cur
+}
+
+func (b *box) all(typ string) []*box {
+	var boxes []*box
+	for _, c := range b.children {
+		if c.typ == typ {
+			boxes = append(boxes, c)
+		}
+	}
+	return boxes
+}
+
+func (b *box) remove(typ string) {
+	var children []*box
+	for _, c := range b.children {
+		if c.typ != typ {
+			children = append(children, c)
+		}
+	}
+	b.children = children
+}
+
+func (b *box) replace(n *box) {
+	for i, c := range b.children {
+		if c.typ == n.typ {
+			b.children[i] = n
+			return
+		}
+	}
+	b.children = append(b.children, n)
+}
+
+// walk calls fn for b and every box below it.
+func (b *box) walk(fn func(*box)) {
+	fn(b)
+	for _, c := range b.children {
+		c.walk(fn)
+	}
+}
+
+func (b *box) size() int64 {
+	n := int64(8 + len(b.data))
+	for _, c := range b.children {
+		n += c.size()
+	}
+	return n
+}
+
+func (b *box) bytes() []byte {
+	var buf bytes.Buffer
+	b.write(&buf)
+	return buf.Bytes()
+}
+
+func (b *box) write(buf *bytes.Buffer) {
+	var hdr [8]byte
+	binary.BigEndian.PutUint32(hdr[:4], uint32(b.size()))
+	copy(hdr[4:], b.typ)
+	buf.Write(hdr[:])
+	buf.Write(b.data)
+	for _, c := range b.children {
+		c.write(buf)
+	}
+}
+
+func newBox(typ string, data []byte) *box {
+	return &box{typ: typ, data: data}
+}
+
+func newContainer(typ string, children ...*box) *box {
+	return &box{typ: typ, container: true, children: children}
+}
+
+// fullBox returns the version and flags of a full box payload.
+func fullBox(data []byte) (uint8, []byte) {
+	if len(data) < 4 {
+		return 0, nil
+	}
+	return data[0], data[4:]
+}
@@ -0,0 +1,454 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+	"unicode/utf16"
+)
+
+// neroUnit is the timescale of chpl chapter starts, 100ns.
+const neroUnit = 10000000
+
+type Chapter struct {
+	start time.Duration
+	end   time.Duration
+	title string
+}
+
+func (ch Chapter) Start() time.Duration {
+	return ch.start
+}
+
+func (ch Chapter) End() time.Duration {
+	return ch.end
+}
+
+func (ch Chapter) Title() string {
+	return ch.title
+}
+
+func setEnds(chaps []*Chapter, dur time.Duration) {
+	for i, ch := range chaps {
+		if i+1 < len(chaps) {
+			ch.end = chaps[i+1].start
+		} else {
+			ch.end = dur
+		}
+	}
+}
+
+// neroChapters reads the chapters of a moov/udta/chpl box.
+func neroChapters(moov *box) []*Chapter {
+	chpl := moov.find("udta", "chpl")
+	if chpl == nil {
+		return nil
+	}
+
+	version, data := fullBox(chpl.data)
+	if version > 0 {
+		if len(data) < 4 {
+			return nil
+		}
+		data = data[4:]
+	}
+	if len(data) < 1 {
+		return nil
+	}
+	count := int(data[0])
+	data = data[1:]
+
+	var chaps []*Chapter
+	for i := 0; i < count && len(data) >= 9; i++ {
+		start := binary.BigEndian.Uint64(data[:8])
+		l := int(data[8])
+		data = data[9:]
+		if len(data) < l {
+			break
+		}
+		chaps = append(chaps, &Chapter{
+			start: scaled(start, neroUnit),
+			title: string(data[:l]),
+		})
+		data = data[l:]
+	}
+
+	return chaps
+}
+
+func neroBox(chaps []*Chapter) (*box, error) {
+	if len(chaps) > 255 {
+		return nil, fmt.Errorf("mp4: chpl holds at most 255 chapters, got %d", len(chaps))
+	}
+
+	data := []byte{1, 0, 0, 0, 0, 0, 0, 0, byte(len(chaps))}
+	for _, ch := range chaps {
+		title := []byte(ch.title)
+		if len(title) > 255 {
+			title = title[:255]
+		}
+		var start [8]byte
+		binary.BigEndian.PutUint64(start[:], uint64(ch.start/100))
+		data = append(data, start[:]...)
+		data = append(data, byte(len(title)))
+		data = append(data, title...)
+	}
+
+	return newBox("chpl", data), nil
+}
+
+// chapterTrack returns the text track referenced by another track's
+// tref/chap box.
+func chapterTrack(moov *box) *box {
+	var ids []uint32
+	for _, trak := range moov.all("trak") {
+		chap := trak.find("tref", "chap")
+		if chap == nil {
+			continue
+		}
+		for d := chap.data; len(d) >= 4; d = d[4:] {
+			ids = append(ids, binary.BigEndian.Uint32(d[:4]))
+		}
+	}
+
+	for _, trak := range moov.all("trak") {
+		id := trackID(trak)
+		for _, ref := range ids {
+			if id == ref && handlerType(trak) == "text" {
+				return trak
+			}
+		}
+	}
+	return nil
+}
+
+// textChapters reads the samples of a QuickTime chapter text track.
+func textChapters(r io.ReadSeeker, moov *box) ([]*Chapter, error) {
+	trak := chapterTrack(moov)
+	if trak == nil {
+		return nil, nil
+	}
+
+	mdhd := trak.find("mdia", "mdhd")
+	stbl := trak.find("mdia", "minf", "stbl")
+	if mdhd == nil || stbl == nil {
+		return nil, nil
+	}
+	scale, _ := timing(mdhd.data)
+
+	tbl, err := readSampleTable(stbl)
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		chaps []*Chapter
+		t     uint64
+	)
+	for i, off := range tbl.offsets {
+		if off < 0 || int64(tbl.sizes[i]) > size-off {
+			return nil, fmt.Errorf("mp4: chapter sample %d at %d runs past the end of the file", i+1, off)
+		}
+		if _, err := r.Seek(off, io.SeekStart); err != nil {
+			return nil, err
+		}
+		sample := make([]byte, tbl.sizes[i])
+		if _, err := io.ReadFull(r, sample); err != nil {
+			return nil, err
+		}
+		title := decodeText(sample)
+		start := t
+		if i < len(tbl.durations) {
+			t += uint64(tbl.durations[i])
+		}
+		// an empty first sample is the gap before a later first chapter
+		if i == 0 && title == "" && len(tbl.offsets) > 1 {
+			continue
+		}
+		chaps = append(chaps, &Chapter{
+			start: scaled(start, scale),
+			title: title,
+		})
+	}
+
+	return chaps, nil
+}
+
+// decodeText decodes a text sample, a 16 bit length followed by UTF-8 or
+// byte order marked UTF-16 text.
+func decodeText(sample []byte) string {
+	if len(sample) < 2 {
+		return ""
+	}
+	l := int(binary.BigEndian.Uint16(sample[:2]))
+	text := sample[2:]
+	if l < len(text) {
+		text = text[:l]
+	}
+
+	if len(text) >= 2 && text[0] == 0xfe && text[1] == 0xff {
+		text = text[2:]
+		u := make([]uint16, len(text)/2)
+		for i := range u {
+			u[i] = binary.BigEndian.Uint16(text[i*2:])
+		}
+		return string(utf16.Decode(u))
+	}
+
+	return string(text)
+}
+
+// encodeText encodes a title as a text sample with an encd atom marking it
+// as UTF-8.
+func encodeText(title string) []byte {
+	t := []byte(title)
+	if len(t) > 0xffff {
+		t = t[:0xffff]
+	}
+	sample := make([]byte, 2, 2+len(t)+12)
+	binary.BigEndian.PutUint16(sample, uint16(len(t)))
+	sample = append(sample, t...)
+	sample = append(sample, 0, 0, 0, 12, 'e', 'n', 'c', 'd', 0, 0, 1, 0)
+	return sample
+}
+
+type sampleTable struct {
+	sizes     []uint32
+	offsets   []int64
+	durations []uint32
+}
+
+func readSampleTable(stbl *box) (sampleTable, error) {
+	var tbl sampleTable
+
+	if stts := stbl.child("stts"); stts != nil {
+		_, d := fullBox(stts.data)
+		if len(d) >= 4 {
+			n := int(binary.BigEndian.Uint32(d[:4]))
+			d = d[4:]
+			for i := 0; i < n && len(d) >= 8; i++ {
+				count := binary.BigEndian.Uint32(d[:4])
+				delta := binary.BigEndian.Uint32(d[4:8])
+				for j := uint32(0); j < count; j++ {
+					tbl.durations = append(tbl.durations, delta)
+				}
+				d = d[8:]
+			}
+		}
+	}
+
+	stsz := stbl.child("stsz")
+	if stsz == nil {
+		return tbl, fmt.Errorf("mp4: chapter track has no stsz")
+	}
+	_, d := fullBox(stsz.data)
+	if len(d) < 8 {
+		return tbl, fmt.Errorf("mp4: truncated stsz")
+	}
+	size := binary.BigEndian.Uint32(d[:4])
+	count := int(binary.BigEndian.Uint32(d[4:8]))
+	d = d[8:]
+	for i := 0; i < count; i++ {
+		if size != 0 {
+			tbl.sizes = append(tbl.sizes, size)
+			continue
+		}
+		if len(d) < 4 {
+			return tbl, fmt.Errorf("mp4: truncated stsz")
+		}
+		tbl.sizes = append(tbl.sizes, binary.BigEndian.Uint32(d[:4]))
+		d = d[4:]
+	}
+
+	chunks := chunkOffsets(stbl)
+
+	type stscEntry struct {
+		first, samples uint32
+	}
+	var stsc []stscEntry
+	if b := stbl.child("stsc"); b != nil {
+		_, d := fullBox(b.data)
+		if len(d) >= 4 {
+			n := int(binary.BigEndian.Uint32(d[:4]))
+			d = d[4:]
+			for i := 0; i < n && len(d) >= 12; i++ {
+				stsc = append(stsc, stscEntry{
+					first:   binary.BigEndian.Uint32(d[:4]),
+					samples: binary.BigEndian.Uint32(d[4:8]),
+				})
+				d = d[12:]
+			}
+		}
+	}
+
+	sample := 0
+	for c, off := range chunks {
+		per := uint32(1)
+		for _, e := range stsc {
+			if uint32(c+1) >= e.first {
+				per = e.samples
+			}
+		}
+		for j := uint32(0); j < per && sample < len(tbl.sizes); j++ {
+			tbl.offsets = append(tbl.offsets, off)
+			off += int64(tbl.sizes[sample])
+			sample++
+		}
+	}
+
+	if len(tbl.offsets) < len(tbl.sizes) {
+		tbl.sizes = tbl.sizes[:len(tbl.offsets)]
+	}
+
+	return tbl, nil
+}
+
+func chunkOffsets(stbl *box) []int64 {
+	var offsets []int64
+	if stco := stbl.child("stco"); stco != nil {
+		_, d := fullBox(stco.data)
+		if len(d) >= 4 {
+			n := int(binary.BigEndian.Uint32(d[:4]))
+			d = d[4:]
+			for i := 0; i < n && len(d) >= 4; i++ {
+				offsets = append(offsets, int64(binary.BigEndian.Uint32(d[:4])))
+				d = d[4:]
+			}
+		}
+	}
+	if co64 := stbl.child("co64"); co64 != nil {
+		_, d := fullBox(co64.data)
+		if len(d) >= 4 {
+			n := int(binary.BigEndian.Uint32(d[:4]))
+			d = d[4:]
+			for i := 0; i < n && len(d) >= 8; i++ {
+				offsets = append(offsets, int64(binary.BigEndian.Uint64(d[:8])))
+				d = d[8:]
+			}
+		}
+	}
+	return offsets
+}
+
+// textSamples encodes chapters as the samples of a chapter text track and
+// their durations in ticks of scale, each lasting until the next chapter
+// starts. A first chapter starting after 0 is preceded by an empty sample.
+func textSamples(chaps []*Chapter, scale uint32) ([][]byte, []uint64) {
+	var (
+		samples [][]byte
+		durs    []uint64
+	)
+	ticks := func(d time.Duration) uint64 {
+		return uint64(d) * uint64(scale) / uint64(time.Second)
+	}
+	if len(chaps) > 0 && chaps[0].start > 0 {
+		samples = append(samples, encodeText(""))
+		durs = append(durs, ticks(chaps[0].start))
+	}
+	for i, ch := range chaps {
+		end := ch.end
+		if i+1 < len(chaps) {
+			end = chaps[i+1].start
+		}
+		samples = append(samples, encodeText(ch.title))
+		durs = append(durs, ticks(end)-ticks(ch.start))
+	}
+	return samples, durs
+}
+
+// textSampleTable rebuilds the sample tables of a chapter text track with
+// one sample per chunk.
+func textSampleTable(stbl *box, durs []uint64, sizes []int, offset int64) {
+	stts := []byte{0, 0, 0, 0}
+	stts = appendUint32(stts, uint32(len(durs)))
+	for _, d := range durs {
+		stts = appendUint32(stts, 1)
+		stts = appendUint32(stts, uint32(d))
+	}
+
+	stsc := []byte{0, 0, 0, 0}
+	stsc = appendUint32(stsc, 1)
+	stsc = appendUint32(stsc, 1)
+	stsc = appendUint32(stsc, 1)
+	stsc = appendUint32(stsc, 1)
+
+	stsz := []byte{0, 0, 0, 0, 0, 0, 0, 0}
+	stsz = appendUint32(stsz, uint32(len(sizes)))
+	for _, s := range sizes {
+		stsz = appendUint32(stsz, uint32(s))
+	}
+
+	co64 := []byte{0, 0, 0, 0}
+	co64 = appendUint32(co64, uint32(len(sizes)))
+	for _, s := range sizes {
+		var b [8]byte
+		binary.BigEndian.PutUint64(b[:], uint64(offset))
+		co64 = append(co64, b[:]...)
+		offset += int64(s)
+	}
+
+	stbl.replace(newBox("stts", stts))
+	stbl.replace(newBox("stsc", stsc))
+	stbl.replace(newBox("stsz", stsz))
+	stbl.remove("stco")
+	stbl.replace(newBox("co64", co64))
+	stbl.remove("stss")
+}
+
+// setTrackDuration sets the duration of a track to dur ticks of its media
+// timescale, in its mdhd and, in ticks of the movie timescale, its tkhd.
+// An edit list is replaced by one presenting the whole track.
+func setTrackDuration(trak *box, dur uint64, scale, movieScale uint32) {
+	if mdhd := trak.find("mdia", "mdhd"); mdhd != nil {
+		version, body := fullBox(mdhd.data)
+		switch {
+		case version == 1 && len(body) >= 28:
+			binary.BigEndian.PutUint64(body[20:28], dur)
+		case version == 0 && len(body) >= 16:
+			binary.BigEndian.PutUint32(body[12:16], clampUint32(dur))
+		}
+	}
+
+	var movieDur uint64
+	if scale > 0 {
+		movieDur = dur * uint64(movieScale) / uint64(scale)
+	}
+	if tkhd := trak.child("tkhd"); tkhd != nil {
+		version, body := fullBox(tkhd.data)
+		switch {
+		case version == 1 && len(body) >= 32:
+			binary.BigEndian.PutUint64(body[24:32], movieDur)
+		case version == 0 && len(body) >= 20:
+			binary.BigEndian.PutUint32(body[16:20], clampUint32(movieDur))
+		}
+	}
+
+	if trak.child("edts") != nil {
+		elst := []byte{0, 0, 0, 0}
+		elst = appendUint32(elst, 1)
+		elst = appendUint32(elst, clampUint32(movieDur))
+		elst = appendUint32(elst, 0)
+		elst = appendUint32(elst, 0x00010000)
+		trak.replace(newContainer("edts", newBox("elst", elst)))
+	}
+}
+
+func clampUint32(v uint64) uint32 {
+	if v > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(v)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], v)
+	return append(b, n[:]...)
+}
@@ -0,0 +1,244 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// data box types
+const (
+	typeUTF8    = 1
+	typeJPEG    = 13
+	typePNG     = 14
+	typeInteger = 21
+	typeImplied = 0
+)
+
+// itunesTags maps ilst item types to ffmpeg's tag names.
+var itunesTags = map[string]string{
+	"\xa9nam": "title",
+	"\xa9ART": "artist",
+	"aART":    "album_artist",
+	"\xa9alb": "album",
+	"\xa9day": "date",
+	"\xa9gen": "genre",
+	"\xa9wrt": "composer",
+	"\xa9cmt": "comment",
+	"\xa9too": "encoder",
+	"\xa9lyr": "lyrics",
+	"\xa9grp": "grouping",
+	"desc":    "description",
+	"ldes":    "synopsis",
+	"cprt":    "copyright",
+	"tvsh":    "show",
+	"tven":    "episode_id",
+	"tvnn":    "network",
+	"sonm":    "sort_name",
+	"soar":    "sort_artist",
+	"soaa":    "sort_album_artist",
+	"soal":    "sort_album",
+	"soco":    "sort_composer",
+	"trkn":    "track",
+	"disk":    "disc",
+	"stik":    "media_type",
+	"tvsn":    "season_number",
+	"tves":    "episode_sort",
+	"pgap":    "gapless_playback",
+	"cpil":    "compilation",
+}
+
+var integerTags = map[string]int{
+	"stik": 1,
+	"pgap": 1,
+	"cpil": 1,
+	"tvsn": 4,
+	"tves": 4,
+}
+
+// skipTags are added by the probe and don't belong in a file.
+var skipTags = map[string]bool{
+	"filename":          true,
+	"duration":          true,
+	"size":              true,
+	"bit_rate":          true,
+	"major_brand":       true,
+	"minor_version":     true,
+	"compatible_brands": true,
+}
+
+func (m *Meta) parseIlst(moov *box) error {
+	ilst := moov.find("udta", "meta", "ilst")
+	if ilst == nil {
+		return nil
+	}
+
+	for _, item := range ilst.children {
+		boxes, err := parseBoxes(item.data)
+		if err != nil {
+			return err
+		}
+
+		var (
+			name   string
+			values []*box
+		)
+		for _, b := range boxes {
+			switch b.typ {
+			case "name":
+				if len(b.data) >= 4 {
+					name = string(b.data[4:])
+				}
+			case "data":
+				values = append(values, b)
+			}
+		}
+
+		for _, d := range values {
+			if len(d.data) < 8 {
+				continue
+			}
+			kind := binary.BigEndian.Uint32(d.data[:4]) & 0xffffff
+			val := d.data[8:]
+
+			switch item.typ {
+			case "covr":
+				if len(m.cover) == 0 {
+					m.cover = val
+					m.coverType = "mjpeg"
+					if kind == typePNG {
+						m.coverType = "png"
+					}
+				}
+			case "trkn", "disk":
+				if len(val) >= 6 {
+					n := binary.BigEndian.Uint16(val[2:4])
+					total := binary.BigEndian.Uint16(val[4:6])
+					tag := strconv.Itoa(int(n))
+					if total > 0 {
+						tag = fmt.Sprintf("%d/%d", n, total)
+					}
+					m.tags[itunesTags[item.typ]] = tag
+				}
+			case "----":
+				if name != "" {
+					m.tags[strings.ToLower(name)] = string(val)
+				}
+			default:
+				key, ok := itunesTags[item.typ]
+				if !ok {
+					continue
+				}
+				if kind == typeInteger || kind == typeImplied && integerTags[item.typ] > 0 {
+					m.tags[key] = strconv.FormatInt(readInt(val), 10)
+				} else {
+					m.tags[key] = string(val)
+				}
+			}
+			break
+		}
+	}
+
+	return nil
+}
+
+func readInt(b []byte) int64 {
+	var n int64
+	for _, c := range b {
+		n = n<<8 | int64(c)
+	}
+	return n
+}
+
+// ilstBox builds an ilst box from ffmpeg style tags. Known tags become
+// iTunes atoms, anything else is written as a com.apple.iTunes freeform
+// item. The cover, if any, is kept.
+func ilstBox(tags map[string]string, cover []byte, coverType string) *box {
+	atoms := make(map[string]string)
+	for atom, key := range itunesTags {
+		atoms[key] = atom
+	}
+
+	var keys []string
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ilst := newContainer("ilst")
+	for _, key := range keys {
+		val := tags[key]
+		if skipTags[key] || val == "" {
+			continue
+		}
+
+		atom, ok := atoms[key]
+		switch {
+		case !ok:
+			mean := append([]byte{0, 0, 0, 0}, "com.apple.iTunes"...)
+			name := append([]byte{0, 0, 0, 0}, key...)
+			ilst.children = append(ilst.children, newContainer("----",
+				newBox("mean", mean),
+				newBox("name", name),
+				dataBox(typeUTF8, []byte(val)),
+			))
+		case atom == "trkn" || atom == "disk":
+			var n, total int
+			split := strings.SplitN(val, "/", 2)
+			n, _ = strconv.Atoi(strings.TrimSpace(split[0]))
+			if len(split) == 2 {
+				total, _ = strconv.Atoi(strings.TrimSpace(split[1]))
+			}
+			b := make([]byte, 6)
+			if atom == "trkn" {
+				b = make([]byte, 8)
+			}
+			binary.BigEndian.PutUint16(b[2:4], uint16(n))
+			binary.BigEndian.PutUint16(b[4:6], uint16(total))
+			ilst.children = append(ilst.children, newContainer(atom, dataBox(typeImplied, b)))
+		case integerTags[atom] > 0:
+			n, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				continue
+			}
+			size := integerTags[atom]
+			b := make([]byte, size)
+			for i := size - 1; i >= 0; i-- {
+				b[i] = byte(n)
+				n >>= 8
+			}
+			ilst.children = append(ilst.children, newContainer(atom, dataBox(typeInteger, b)))
+		default:
+			ilst.children = append(ilst.children, newContainer(atom, dataBox(typeUTF8, []byte(val))))
+		}
+	}
+
+	if len(cover) > 0 {
+		kind := uint32(typeJPEG)
+		if coverType == "png" {
+			kind = typePNG
+		}
+		ilst.children = append(ilst.children, newContainer("covr", dataBox(kind, cover)))
+	}
+
+	return ilst
+}
+
+func dataBox(kind uint32, val []byte) *box {
+	data := appendUint32(nil, kind)
+	data = append(data, 0, 0, 0, 0)
+	data = append(data, val...)
+	return newBox("data", data)
+}
+
+// metaBox wraps an ilst in an ISO full meta box with an mdir handler.
+func metaBox(ilst *box) *box {
+	hdlr := make([]byte, 25)
+	copy(hdlr[8:12], "mdir")
+	copy(hdlr[12:16], "appl")
+	meta := newContainer("meta", newBox("hdlr", hdlr), ilst)
+	meta.data = []byte{0, 0, 0, 0}
+	return meta
+}
@@ -0,0 +1,259 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ohzqq/avtools"
+	"github.com/ohzqq/fidi"
+)
+
+// Meta is the metadata of an MP4/M4A/M4B file, read without ffprobe.
+type Meta struct {
+	fidi.File
+	tags      map[string]string
+	chapters  []avtools.ChapterMeta
+	streams   []map[string]string
+	cover     []byte
+	coverType string
+	duration  time.Duration
+}
+
+// Load reads chapters from either a Nero chpl atom or a QuickTime chapter
+// text track, and iTunes ilst tags, including cover art.
+func Load(input string) (avtools.Metaz, error) {
+	src, err := avtools.NewFile(input)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &Meta{
+		File: src,
+		tags: make(map[string]string),
+	}
+
+	f, err := os.Open(input)
+	if err != nil {
+		return meta, err
+	}
+	defer f.Close()
+
+	moov, _, err := readMoov(f)
+	if err != nil {
+		return meta, err
+	}
+
+	meta.duration = movieDuration(moov)
+
+	err = meta.parseIlst(moov)
+	if err != nil {
+		return meta, err
+	}
+
+	meta.streams = trackStreams(moov)
+	if len(meta.cover) > 0 {
+		meta.streams = append(meta.streams, map[string]string{
+			"index":      strconv.Itoa(len(meta.streams)),
+			"codec_type": "video",
+			"codec_name": meta.coverType,
+			"cover":      "true",
+		})
+	}
+
+	chaps, err := textChapters(f, moov)
+	if err != nil {
+		return meta, err
+	}
+	if len(chaps) == 0 {
+		chaps = neroChapters(moov)
+	}
+	setEnds(chaps, meta.duration)
+	for _, ch := range chaps {
+		meta.chapters = append(meta.chapters, ch)
+	}
+
+	meta.tags["filename"] = meta.Path()
+	meta.tags["duration"] = strconv.FormatFloat(meta.duration.Seconds(), 'f', 6, 64)
+
+	return meta, nil
+}
+
+// readMoov finds the moov box among the top level boxes and parses it,
+// returning its header too.
+func readMoov(r io.ReadSeeker) (*box, header, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, header{}, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, header{}, err
+	}
+
+	for {
+		h, err := readHeader(r)
+		if err == io.EOF {
+			return nil, h, fmt.Errorf("mp4: no moov box")
+		}
+		if err != nil {
+			return nil, h, err
+		}
+
+		if h.typ == "moov" {
+			if h.size > size-h.offset {
+				return nil, h, fmt.Errorf("mp4: moov box at %d runs past the end of the file", h.offset)
+			}
+			data := make([]byte, h.size-h.hdr)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, h, err
+			}
+			moov, err := parseBox("moov", data)
+			return moov, h, err
+		}
+
+		if _, err := r.Seek(h.offset+h.size, io.SeekStart); err != nil {
+			return nil, h, err
+		}
+	}
+}
+
+func (m Meta) Chapters() []avtools.ChapterMeta {
+	return m.chapters
+}
+
+func (m Meta) Tags() map[string]string {
+	return m.tags
+}
+
+func (m Meta) Streams() []map[string]string {
+	return m.streams
+}
+
+func (m Meta) Source() fidi.File {
+	return m.File
+}
+
+// Cover returns the embedded cover art and its codec, either mjpeg or png.
+func (m Meta) Cover() ([]byte, string) {
+	return m.cover, m.coverType
+}
+
+func (m Meta) Duration() time.Duration {
+	return m.duration
+}
+
+// movieDuration reads the duration from the mvhd box.
+func movieDuration(moov *box) time.Duration {
+	mvhd := moov.child("mvhd")
+	if mvhd == nil {
+		return 0
+	}
+	scale, dur := timing(mvhd.data)
+	return scaled(dur, scale)
+}
+
+// timing reads the timescale and duration shared by mvhd and mdhd.
+func timing(data []byte) (uint32, uint64) {
+	version, body := fullBox(data)
+	switch {
+	case version == 1 && len(body) >= 28:
+		return binary.BigEndian.Uint32(body[16:20]), binary.BigEndian.Uint64(body[20:28])
+	case version == 0 && len(body) >= 16:
+		return binary.BigEndian.Uint32(body[8:12]), uint64(binary.BigEndian.Uint32(body[12:16]))
+	}
+	return 0, 0
+}
+
+func scaled(t uint64, scale uint32) time.Duration {
+	if scale == 0 {
+		return 0
+	}
+	secs := t / uint64(scale)
+	rem := t % uint64(scale)
+	return time.Duration(secs)*time.Second + time.Duration(rem)*time.Second/time.Duration(scale)
+}
+
+func trackID(trak *box) uint32 {
+	tkhd := trak.child("tkhd")
+	if tkhd == nil {
+		return 0
+	}
+	version, body := fullBox(tkhd.data)
+	switch {
+	case version == 1 && len(body) >= 20:
+		return binary.BigEndian.Uint32(body[16:20])
+	case version == 0 && len(body) >= 12:
+		return binary.BigEndian.Uint32(body[8:12])
+	}
+	return 0
+}
+
+func handlerType(trak *box) string {
+	hdlr := trak.find("mdia", "hdlr")
+	if hdlr == nil || len(hdlr.data) < 12 {
+		return ""
+	}
+	return string(hdlr.data[8:12])
+}
+
+func sampleFormat(trak *box) string {
+	stsd := trak.find("mdia", "minf", "stbl", "stsd")
+	if stsd == nil || len(stsd.data) < 16 {
+		return ""
+	}
+	return string(stsd.data[12:16])
+}
+
+var codecTypes = map[string]string{
+	"soun": "audio",
+	"vide": "video",
+	"text": "subtitle",
+	"sbtl": "subtitle",
+	"subt": "subtitle",
+}
+
+var codecNames = map[string]string{
+	"mp4a": "aac",
+	"alac": "alac",
+	"ac-3": "ac3",
+	"ec-3": "eac3",
+	"Opus": "opus",
+	"fLaC": "flac",
+	"avc1": "h264",
+	"avc3": "h264",
+	"hvc1": "hevc",
+	"hev1": "hevc",
+	"av01": "av1",
+	"mp4v": "mpeg4",
+	"text": "mov_text",
+	"tx3g": "mov_text",
+}
+
+func trackStreams(moov *box) []map[string]string {
+	var streams []map[string]string
+	for i, trak := range moov.all("trak") {
+		format := sampleFormat(trak)
+		stream := map[string]string{
+			"index":      strconv.Itoa(i),
+			"codec_type": "data",
+			"codec_name": format,
+			"cover":      "false",
+		}
+		if t, ok := codecTypes[handlerType(trak)]; ok {
+			stream["codec_type"] = t
+		}
+		if n, ok := codecNames[format]; ok {
+			stream["codec_name"] = n
+		}
+		streams = append(streams, stream)
+	}
+	return streams
+}
+
+// Sniff reports whether data starts with an ftyp box.
+func Sniff(data []byte) bool {
+	return len(data) >= 8 && string(data[4:8]) == "ftyp"
+}
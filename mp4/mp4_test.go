@@ -0,0 +1,267 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ohzqq/avtools"
+)
+
+// meta is the avtools.Meta written by the tests.
+type meta struct {
+	tags  map[string]string
+	chaps []*avtools.Chapter
+}
+
+func (m meta) Chapters() []*avtools.Chapter { return m.chaps }
+func (m meta) Tags() map[string]string      { return m.tags }
+func (m meta) Streams() []map[string]string { return nil }
+
+func chapters(t *testing.T, starts ...string) []*avtools.Chapter {
+	t.Helper()
+	var chaps []*avtools.Chapter
+	for i, ss := range starts {
+		ch := &avtools.Chapter{ChapTitle: "Chapter " + string(rune('A'+i))}
+		err := ch.SS(ss)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chaps = append(chaps, ch)
+	}
+	return chaps
+}
+
+func fullData(body ...[]byte) []byte {
+	data := []byte{0, 0, 0, 0}
+	for _, b := range body {
+		data = append(data, b...)
+	}
+	return data
+}
+
+func u32(v uint32) []byte {
+	return appendUint32(nil, v)
+}
+
+// track is a trak box of the handler kind lasting dur ms, with the sample
+// table entries given.
+func track(id, dur uint32, kind, format string, extra []*box, stbl ...*box) *box {
+	tkhd := make([]byte, 80)
+	binary.BigEndian.PutUint32(tkhd[8:12], id)
+	binary.BigEndian.PutUint32(tkhd[16:20], dur)
+
+	stsd := fullData(u32(1), u32(16), []byte(format), make([]byte, 8))
+	stbl = append([]*box{newBox("stsd", stsd)}, stbl...)
+
+	mdia := newContainer("mdia",
+		newBox("mdhd", fullData(u32(0), u32(0), u32(1000), u32(dur), make([]byte, 4))),
+		newBox("hdlr", fullData(u32(0), []byte(kind), make([]byte, 13))),
+		newContainer("minf", newContainer("stbl", stbl...)),
+	)
+
+	trak := newContainer("trak", newBox("tkhd", fullData(tkhd)))
+	trak.children = append(trak.children, extra...)
+	trak.children = append(trak.children, mdia)
+	return trak
+}
+
+// fixture writes a minute long m4b with an audio track whose chapters are
+// in a text track of one 45s sample, Old, stored in an mdat before moov.
+func fixture(t *testing.T) string {
+	t.Helper()
+
+	ftyp := newBox("ftyp", []byte("M4B \x00\x00\x02\x00isomM4B "))
+	sample := encodeText("Old")
+	mdat := newBox("mdat", sample)
+	offset := uint32(len(ftyp.bytes()) + 8)
+
+	mvhd := make([]byte, 96)
+	binary.BigEndian.PutUint32(mvhd[8:12], 1000)
+	binary.BigEndian.PutUint32(mvhd[12:16], 60000)
+
+	audio := track(1, 60000, "soun", "mp4a",
+		[]*box{newContainer("tref", newBox("chap", u32(2)))},
+	)
+	text := track(2, 45000, "text", "text",
+		[]*box{newContainer("edts", newBox("elst", fullData(u32(1), u32(45000), u32(0), u32(0x00010000))))},
+		newBox("stts", fullData(u32(1), u32(1), u32(45000))),
+		newBox("stsc", fullData(u32(1), u32(1), u32(1), u32(1))),
+		newBox("stsz", fullData(u32(0), u32(1), u32(uint32(len(sample))))),
+		newBox("stco", fullData(u32(1), u32(offset))),
+	)
+	moov := newContainer("moov", newBox("mvhd", fullData(mvhd)), audio, text)
+
+	var data []byte
+	for _, b := range []*box{ftyp, mdat, moov} {
+		data = append(data, b.bytes()...)
+	}
+	name := filepath.Join(t.TempDir(), "book.m4b")
+	err := os.WriteFile(name, data, 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func load(t *testing.T, name string) *Meta {
+	t.Helper()
+	m, err := Load(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m.(*Meta)
+}
+
+func TestLoadFixture(t *testing.T) {
+	m := load(t, fixture(t))
+	chaps := m.Chapters()
+	if len(chaps) != 1 || chaps[0].Title() != "Old" || chaps[0].Start() != 0 {
+		t.Errorf("chapters %v", chaps)
+	}
+}
+
+func TestSaveRoundTrip(t *testing.T) {
+	input := fixture(t)
+	output := filepath.Join(filepath.Dir(input), "updated-book.m4b")
+
+	tags := map[string]string{
+		"title":  "A Book",
+		"artist": "Some Body",
+	}
+	// the first chapter starts late, so the text track has a gap before it
+	err := Save(input, output, meta{tags: tags, chaps: chapters(t, "5", "10", "20.5")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("mode %s, want 0640", info.Mode().Perm())
+	}
+
+	m := load(t, output)
+	for k, v := range tags {
+		if m.Tags()[k] != v {
+			t.Errorf("tag %s: got %q, want %q", k, m.Tags()[k], v)
+		}
+	}
+
+	want := []struct {
+		start, end time.Duration
+		title      string
+	}{
+		{5 * time.Second, 10 * time.Second, "Chapter A"},
+		{10 * time.Second, 20500 * time.Millisecond, "Chapter B"},
+		{20500 * time.Millisecond, time.Minute, "Chapter C"},
+	}
+	got := m.Chapters()
+	if len(got) != len(want) {
+		t.Fatalf("got %d chapters, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Start() != w.start || got[i].End() != w.end || got[i].Title() != w.title {
+			t.Errorf("chapter %d: got %s-%s %q, want %s-%s %q", i+1,
+				got[i].Start(), got[i].End(), got[i].Title(), w.start, w.end, w.title)
+		}
+	}
+
+	f, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	moov, _, err := readMoov(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nero := neroChapters(moov)
+	if len(nero) != len(want) || nero[0].start != want[0].start || nero[2].title != want[2].title {
+		t.Errorf("chpl chapters %v", nero)
+	}
+
+	text := chapterTrack(moov)
+	if _, d := timing(text.find("mdia", "mdhd").data); d != 60000 {
+		t.Errorf("mdhd duration %d, want 60000", d)
+	}
+	if _, body := fullBox(text.child("tkhd").data); binary.BigEndian.Uint32(body[16:20]) != 60000 {
+		t.Errorf("tkhd duration %d, want 60000", binary.BigEndian.Uint32(body[16:20]))
+	}
+	elst := text.find("edts", "elst")
+	if _, body := fullBox(elst.data); binary.BigEndian.Uint32(body[0:4]) != 1 || binary.BigEndian.Uint32(body[4:8]) != 60000 {
+		t.Errorf("elst %x", elst.data)
+	}
+}
+
+func TestSaveUnordered(t *testing.T) {
+	input := fixture(t)
+	err := Save(input, input, meta{chaps: chapters(t, "10", "5")})
+	if err == nil {
+		t.Fatal("saved chapters out of order")
+	}
+	if m := load(t, input); len(m.Chapters()) != 1 {
+		t.Errorf("input changed: %v", m.Chapters())
+	}
+}
+
+func TestSaveAgain(t *testing.T) {
+	input := fixture(t)
+	size := func() int64 {
+		t.Helper()
+		info, err := os.Stat(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Size()
+	}
+
+	err := Save(input, input, meta{chaps: chapters(t, "0", "10", "20")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := size()
+
+	for i := 0; i < 3; i++ {
+		err := Save(input, input, meta{chaps: chapters(t, "0", "10", "20")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := size(); got != first {
+			t.Fatalf("save %d: size %d, want %d", i+2, got, first)
+		}
+	}
+
+	err = Save(input, input, meta{chaps: chapters(t, "0", "30")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// one chapter less makes the samples and the moov smaller
+	if got := size(); got >= first {
+		t.Errorf("size %d, want less than %d", got, first)
+	}
+	chaps := load(t, input).Chapters()
+	if len(chaps) != 2 || chaps[1].Title() != "Chapter B" || chaps[1].Start() != 30*time.Second {
+		t.Errorf("chapters %v", chaps)
+	}
+}
+
+func TestOversizedMoov(t *testing.T) {
+	ftyp := newBox("ftyp", []byte("M4B \x00\x00\x02\x00isomM4B "))
+	data := ftyp.bytes()
+	// a 64 bit size of a terabyte
+	data = append(data, 0, 0, 0, 1, 'm', 'o', 'o', 'v', 0, 0, 1, 0, 0, 0, 0, 0)
+	name := filepath.Join(t.TempDir(), "book.m4b")
+	err := os.WriteFile(name, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(name); err == nil {
+		t.Fatal("loaded a moov larger than the file")
+	}
+}
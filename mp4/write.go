@@ -0,0 +1,312 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ohzqq/avtools"
+)
+
+// Save writes the tags and chapters of meta to output, copying the media
+// data of input untouched. Only the moov box is rewritten: chapters go into
+// a Nero chpl atom and, when the file already has one, the QuickTime chapter
+// text track, whose new samples are appended to the file in their own mdat.
+// That mdat replaces the one a previous save appended, so saving again does
+// not grow the file. Chunk offsets of the other tracks are fixed up when moov
+// precedes the media data. Input and output may be the same file.
+func Save(input, output string, meta avtools.Meta) error {
+	in, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	stat, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	moov, hdr, err := readMoov(in)
+	if err != nil {
+		return err
+	}
+
+	old := &Meta{tags: make(map[string]string)}
+	err = old.parseIlst(moov)
+	if err != nil {
+		return err
+	}
+
+	chaps, err := toChapters(meta.Chapters(), movieDuration(moov))
+	if err != nil {
+		return err
+	}
+
+	udta := moov.child("udta")
+	if udta == nil {
+		udta = newContainer("udta")
+		moov.children = append(moov.children, udta)
+	}
+
+	if len(chaps) > 0 {
+		chpl, err := neroBox(chaps)
+		if err != nil {
+			return err
+		}
+		udta.replace(chpl)
+	}
+
+	ilst := ilstBox(meta.Tags(), old.cover, old.coverType)
+	if m := udta.child("meta"); m != nil {
+		m.replace(ilst)
+	} else {
+		udta.children = append(udta.children, metaBox(ilst))
+	}
+
+	var (
+		text    = chapterTrack(moov)
+		samples [][]byte
+		durs    []uint64
+		sizes   []int
+		stbl    *box
+		// end is where the data copied after moov ends
+		end = stat.Size()
+	)
+	if text != nil && len(chaps) > 0 {
+		mdhd := text.find("mdia", "mdhd")
+		stbl = text.find("mdia", "minf", "stbl")
+		if mdhd == nil || stbl == nil {
+			return fmt.Errorf("mp4: chapter track has no sample table")
+		}
+		if off, ok := chapterMdat(in, moov, text); ok && off >= hdr.offset+hdr.size {
+			end = off
+		}
+		scale, _ := timing(mdhd.data)
+		samples, durs = textSamples(chaps, scale)
+		var total uint64
+		for i, s := range samples {
+			sizes = append(sizes, len(s))
+			total += durs[i]
+		}
+		var movieScale uint32
+		if mvhd := moov.child("mvhd"); mvhd != nil {
+			movieScale, _ = timing(mvhd.data)
+		}
+		setTrackDuration(text, total, scale, movieScale)
+		textSampleTable(stbl, durs, sizes, 0)
+	}
+
+	moovEnd := hdr.offset + hdr.size
+	delta := moov.size() - hdr.size
+	err = fixOffsets(moov, text, moovEnd, delta)
+	if err != nil {
+		return err
+	}
+
+	after := end - moovEnd
+	mdat := hdr.offset + moov.size() + after
+	if stbl != nil {
+		textSampleTable(stbl, durs, sizes, mdat+8)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(output), filepath.Base(output)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// CreateTemp makes the file 0600
+	if err := tmp.Chmod(stat.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(tmp, in, hdr.offset); err != nil {
+		return err
+	}
+	if _, err := tmp.Write(moov.bytes()); err != nil {
+		return err
+	}
+	if _, err := in.Seek(moovEnd, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(tmp, in, after); err != nil {
+		return err
+	}
+
+	if len(samples) > 0 {
+		var data []byte
+		for _, s := range samples {
+			data = append(data, s...)
+		}
+		if _, err := tmp.Write(newBox("mdat", data).bytes()); err != nil {
+			return err
+		}
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	in.Close()
+
+	return os.Rename(tmp.Name(), output)
+}
+
+// chapterMdat returns the offset of the last top level box when it is an
+// mdat holding the samples of the text track and nothing else, as Save
+// appends them.
+func chapterMdat(r io.ReadSeeker, moov, text *box) (int64, bool) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, false
+	}
+	var last header
+	for {
+		h, err := readHeader(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, false
+		}
+		last = h
+		if _, err := r.Seek(h.offset+h.size, io.SeekStart); err != nil {
+			return 0, false
+		}
+	}
+	if last.typ != "mdat" {
+		return 0, false
+	}
+
+	stbl := text.find("mdia", "minf", "stbl")
+	if stbl == nil {
+		return 0, false
+	}
+	tbl, err := readSampleTable(stbl)
+	if err != nil {
+		return 0, false
+	}
+	var (
+		next  = last.offset + last.hdr
+		total int64
+	)
+	for i, off := range tbl.offsets {
+		if off != next {
+			return 0, false
+		}
+		next += int64(tbl.sizes[i])
+		total += int64(tbl.sizes[i])
+	}
+	if total != last.size-last.hdr {
+		return 0, false
+	}
+
+	for _, trak := range moov.all("trak") {
+		if trak == text {
+			continue
+		}
+		stbl := trak.find("mdia", "minf", "stbl")
+		if stbl == nil {
+			continue
+		}
+		for _, off := range chunkOffsets(stbl) {
+			if off >= last.offset {
+				return 0, false
+			}
+		}
+	}
+
+	return last.offset, true
+}
+
+// toChapters converts chapters for writing, filling in missing ends from the
+// next start or the movie duration. Chapters must be in order, without a
+// negative start.
+func toChapters(chapters []*avtools.Chapter, dur time.Duration) ([]*Chapter, error) {
+	var chaps []*Chapter
+	for i, ch := range chapters {
+		c := &Chapter{
+			start: ch.Start().Dur,
+			end:   ch.End().Dur,
+			title: ch.Title(),
+		}
+		switch {
+		case c.start < 0:
+			return nil, fmt.Errorf("%w: chapter %d starts before 0", avtools.ErrInvalidChapters, i+1)
+		case i > 0 && c.start < chaps[i-1].start:
+			return nil, fmt.Errorf("%w: chapter %d starts before chapter %d", avtools.ErrInvalidChapters, i+1, i)
+		}
+		chaps = append(chaps, c)
+	}
+
+	for i, ch := range chaps {
+		if ch.end > ch.start {
+			continue
+		}
+		if i+1 < len(chaps) {
+			ch.end = chaps[i+1].start
+		} else {
+			ch.end = dur
+		}
+		if ch.end < ch.start {
+			ch.end = ch.start
+		}
+	}
+
+	return chaps, nil
+}
+
+// fixOffsets shifts the chunk offsets pointing past the end of the old moov
+// box by delta, skipping the given track.
+func fixOffsets(moov *box, skip *box, from, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+
+	for _, trak := range moov.all("trak") {
+		if trak == skip {
+			continue
+		}
+		stbl := trak.find("mdia", "minf", "stbl")
+		if stbl == nil {
+			continue
+		}
+
+		if stco := stbl.child("stco"); stco != nil && len(stco.data) >= 8 {
+			n := int(binary.BigEndian.Uint32(stco.data[4:8]))
+			d := stco.data[8:]
+			for i := 0; i < n && len(d) >= 4; i++ {
+				off := int64(binary.BigEndian.Uint32(d[:4]))
+				if off >= from {
+					off += delta
+					if off > math.MaxUint32 {
+						return fmt.Errorf("mp4: chunk offset overflows stco, remux the file first")
+					}
+					binary.BigEndian.PutUint32(d[:4], uint32(off))
+				}
+				d = d[4:]
+			}
+		}
+
+		if co64 := stbl.child("co64"); co64 != nil && len(co64.data) >= 8 {
+			n := int(binary.BigEndian.Uint32(co64.data[4:8]))
+			d := co64.data[8:]
+			for i := 0; i < n && len(d) >= 8; i++ {
+				off := int64(binary.BigEndian.Uint64(d[:8]))
+				if off >= from {
+					binary.BigEndian.PutUint64(d[:8], uint64(off+delta))
+				}
+				d = d[8:]
+			}
+		}
+	}
+
+	return nil
+}
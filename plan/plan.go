@@ -0,0 +1,573 @@
+// Package plan saves the commands avtools would run as a job plan, a JSON
+// or YAML document that can be checked and run later, elsewhere.
+package plan
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ohzqq/avtools"
+	"github.com/ohzqq/avtools/batch"
+	"github.com/ohzqq/avtools/codec"
+	"github.com/ohzqq/avtools/ff"
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the version of the plan document written. Version 2 plans
+// are built from commands whose inputs have roles; older ones are refused
+// and have to be planned again.
+const Version = 2
+
+var (
+	ErrVersion      = errors.New("unsupported plan version")
+	ErrInputChanged = errors.New("plan input changed")
+)
+
+// Plan is a list of jobs and the input files they read. Names below Dir
+// are kept relative to it, and Dir relative to the plan file, so a plan
+// can be moved along with the files it reads.
+type Plan struct {
+	Version int       `json:"version" yaml:"version"`
+	Created time.Time `json:"created" yaml:"created"`
+	Dir     string    `json:"dir" yaml:"dir"`
+	Inputs  []Input   `json:"inputs" yaml:"inputs"`
+	Jobs    []Job     `json:"jobs" yaml:"jobs"`
+}
+
+// Job is the steps of one command, run in order.
+type Job struct {
+	Steps []Step `json:"steps" yaml:"steps"`
+}
+
+// Step is an ff.Step, with the data of written files as text so plans can
+// be read. ffmpeg runs are kept as the layout of their command, without
+// Args.
+type Step struct {
+	Args  []string   `json:"args,omitempty" yaml:"args,omitempty"`
+	Cmd   *ff.Layout `json:"cmd,omitempty" yaml:"cmd,omitempty"`
+	Write string     `json:"write,omitempty" yaml:"write,omitempty"`
+	Data  string     `json:"data,omitempty" yaml:"data,omitempty"`
+	Save  *ff.Save   `json:"save,omitempty" yaml:"save,omitempty"`
+	// Script is the ffmpeg run scripts make of Save.
+	Script *ff.Layout `json:"script,omitempty" yaml:"script,omitempty"`
+}
+
+// Input is a file read by the plan, as it was when planned.
+type Input struct {
+	Path    string    `json:"path" yaml:"path"`
+	Size    int64     `json:"size" yaml:"size"`
+	ModTime time.Time `json:"mtime" yaml:"mtime"`
+	SHA256  string    `json:"sha256" yaml:"sha256"`
+}
+
+// New makes a plan of jobs, each the steps of one command, recording the
+// files read by ffmpeg or saved into that no step writes. Steps that only
+// note what would be done can't be replayed, so they are refused.
+func New(jobs ...[]ff.Step) (*Plan, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	p := &Plan{
+		Version: Version,
+		Created: time.Now().UTC().Truncate(time.Second),
+		Dir:     dir,
+		Inputs:  []Input{},
+	}
+
+	written := make(map[string][]byte)
+	seen := make(map[string]bool)
+	add := func(name string) error {
+		in, err := Stat(name)
+		if err != nil {
+			return err
+		}
+		if !seen[in.Path] {
+			seen[in.Path] = true
+			p.Inputs = append(p.Inputs, in)
+		}
+		return nil
+	}
+	for _, steps := range jobs {
+		var job Job
+		for _, s := range steps {
+			if s.Note != "" {
+				return nil, fmt.Errorf("%w: can't plan %q", avtools.ErrUnsupported, s.Note)
+			}
+			if s.Write != "" {
+				written[s.Write] = s.Data
+			}
+
+			for _, name := range reads(s) {
+				data, ok := written[name]
+				if !ok {
+					err := add(name)
+					if err != nil {
+						return nil, err
+					}
+					continue
+				}
+				// the files of a concat list are inputs too
+				for _, f := range concatFiles(data) {
+					err := add(f)
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+
+			step := Step{
+				Cmd:    s.Cmd,
+				Write:  s.Write,
+				Data:   string(s.Data),
+				Save:   s.Save,
+				Script: s.Script,
+			}
+			if s.Cmd == nil {
+				step.Args = s.Args
+			}
+			job.Steps = append(job.Steps, step)
+		}
+		p.Jobs = append(p.Jobs, job)
+	}
+
+	for i := range p.Inputs {
+		p.Inputs[i].Path = rel(dir, p.Inputs[i].Path)
+	}
+	for i := range p.Jobs {
+		p.Jobs[i] = p.Jobs[i].mapNames(func(name string) string {
+			return rel(dir, name)
+		})
+	}
+
+	return p, nil
+}
+
+// reads are the files a step reads: the inputs of ffmpeg, the file given
+// with -i to other programs and the files of a native save.
+func reads(s ff.Step) []string {
+	var names []string
+	switch {
+	case s.Cmd != nil:
+		for _, in := range s.Cmd.Inputs {
+			names = append(names, in.File)
+		}
+	case s.Save != nil:
+		names = append(names, s.Save.Input, s.Save.Meta)
+	default:
+		for i, arg := range s.Args {
+			if arg == "-i" && i+1 < len(s.Args) {
+				names = append(names, s.Args[i+1])
+			}
+		}
+	}
+
+	var files []string
+	for _, name := range names {
+		if !special(name) {
+			files = append(files, name)
+		}
+	}
+	return files
+}
+
+// mapNames returns the job with the file names of its steps passed
+// through fn, including those in concat lists it writes.
+func (j Job) mapNames(fn func(string) string) Job {
+	job := Job{Steps: make([]Step, len(j.Steps))}
+	for i, s := range j.Steps {
+		if s.Cmd != nil {
+			s.Cmd = mapLayout(*s.Cmd, fn)
+		}
+		if s.Script != nil {
+			s.Script = mapLayout(*s.Script, fn)
+		}
+		if s.Save != nil {
+			s.Save = &ff.Save{
+				Format: s.Save.Format,
+				Input:  fn(s.Save.Input),
+				Output: fn(s.Save.Output),
+				Meta:   fn(s.Save.Meta),
+			}
+		}
+		if s.Write != "" {
+			s.Write = fn(s.Write)
+			s.Data = mapConcat(s.Data, fn)
+		}
+		if len(s.Args) > 0 && s.Args[0] == "rm" {
+			args := []string{"rm"}
+			for _, arg := range s.Args[1:] {
+				if !strings.HasPrefix(arg, "-") {
+					arg = fn(arg)
+				}
+				args = append(args, arg)
+			}
+			s.Args = args
+		}
+		job.Steps[i] = s
+	}
+	return job
+}
+
+// mapLayout returns l with the names of its inputs and outputs passed
+// through fn.
+func mapLayout(l ff.Layout, fn func(string) string) *ff.Layout {
+	cmd := ff.Layout{Filters: l.Filters}
+	for _, in := range l.Inputs {
+		in.File = fn(in.File)
+		cmd.Inputs = append(cmd.Inputs, in)
+	}
+	for _, out := range l.Outputs {
+		out.File = fn(out.File)
+		cmd.Outputs = append(cmd.Outputs, out)
+	}
+	return &cmd
+}
+
+// special reports whether name is a pipe rather than a file.
+func special(name string) bool {
+	return name == "" || name == "-" || strings.HasPrefix(name, "pipe:")
+}
+
+// rel is name relative to dir when it is below it.
+func rel(dir, name string) string {
+	if special(name) || !filepath.IsAbs(name) {
+		return name
+	}
+	r, err := filepath.Rel(dir, name)
+	if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return name
+	}
+	return r
+}
+
+// abs is name resolved against dir.
+func abs(dir, name string) string {
+	if special(name) || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
+
+// concatHeader starts the concat lists whose file names are mapped.
+const concatHeader = "ffconcat version 1.0"
+
+// concatFiles returns the files listed in an ffmpeg concat list.
+func concatFiles(data []byte) []string {
+	var files []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if name, ok := concatFile(line); ok {
+			files = append(files, name)
+		}
+	}
+	return files
+}
+
+// concatFile is the file of a concat list line.
+func concatFile(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "file ") {
+		return "", false
+	}
+	name := strings.TrimSpace(strings.TrimPrefix(line, "file "))
+	name = strings.TrimPrefix(strings.TrimSuffix(name, "'"), "'")
+	return strings.ReplaceAll(name, `'\''`, "'"), true
+}
+
+// mapConcat passes the files of a concat list through fn, leaving other
+// data as it is.
+func mapConcat(data string, fn func(string) string) string {
+	if !strings.HasPrefix(data, concatHeader) {
+		return data
+	}
+	lines := strings.Split(data, "\n")
+	for i, line := range lines {
+		if name, ok := concatFile(line); ok {
+			lines[i] = "file '" + strings.ReplaceAll(fn(name), "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Stat records the file name as an Input.
+func Stat(name string) (Input, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return Input{}, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return Input{}, err
+	}
+	sum, err := hash(abs)
+	if err != nil {
+		return Input{}, err
+	}
+	return Input{
+		Path:    abs,
+		Size:    info.Size(),
+		ModTime: info.ModTime().UTC(),
+		SHA256:  sum,
+	}, nil
+}
+
+func hash(name string) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, file)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Check reports the inputs that are missing or differ in size or mtime, or
+// in content too when hash is set.
+func (p Plan) Check(hash bool) error {
+	var changed []string
+	for _, in := range p.Inputs {
+		in.Path = abs(p.Dir, in.Path)
+		info, err := os.Stat(in.Path)
+		if err != nil {
+			changed = append(changed, err.Error())
+			continue
+		}
+		switch {
+		case info.Size() != in.Size:
+			changed = append(changed, fmt.Sprintf("%s: size %d, planned %d", in.Path, info.Size(), in.Size))
+			continue
+		case !info.ModTime().Equal(in.ModTime):
+			changed = append(changed, fmt.Sprintf("%s: modified %s, planned %s", in.Path, info.ModTime().UTC(), in.ModTime))
+			continue
+		}
+		if hash {
+			now, err := Stat(in.Path)
+			if err != nil {
+				changed = append(changed, err.Error())
+				continue
+			}
+			if now.SHA256 != in.SHA256 {
+				changed = append(changed, fmt.Sprintf("%s: content differs", in.Path))
+			}
+		}
+	}
+	if len(changed) > 0 {
+		return fmt.Errorf("%w: %s", ErrInputChanged, strings.Join(changed, "; "))
+	}
+	return nil
+}
+
+// Steps returns the steps of every job, in order, with the names in them
+// resolved against Dir.
+func (p Plan) Steps() []ff.Step {
+	var steps []ff.Step
+	for _, job := range p.jobs() {
+		steps = append(steps, job.steps()...)
+	}
+	return steps
+}
+
+// jobs are the jobs with the names in them resolved against Dir.
+func (p Plan) jobs() []Job {
+	jobs := make([]Job, len(p.Jobs))
+	for i, j := range p.Jobs {
+		jobs[i] = j.mapNames(func(name string) string {
+			return abs(p.Dir, name)
+		})
+	}
+	return jobs
+}
+
+func (j Job) steps() []ff.Step {
+	steps := make([]ff.Step, len(j.Steps))
+	for i, s := range j.Steps {
+		steps[i] = ff.Step{
+			Args:   s.args(),
+			Cmd:    s.Cmd,
+			Write:  s.Write,
+			Data:   []byte(s.Data),
+			Save:   s.Save,
+			Script: s.Script,
+		}
+	}
+	return steps
+}
+
+// args are the Args of the step, or those of its ffmpeg run.
+func (s Step) args() []string {
+	if s.Cmd != nil {
+		return append([]string{"ffmpeg"}, s.Cmd.Args()...)
+	}
+	return s.Args
+}
+
+// Run runs the jobs as a batch.
+func (p Plan) Run(ctx context.Context, opts batch.Options) batch.Summary {
+	resolved := p.jobs()
+	jobs := make([]batch.Job, len(resolved))
+	for i, j := range resolved {
+		jobs[i] = j
+	}
+	return batch.Run(ctx, opts, jobs...)
+}
+
+func (j Job) Run() error {
+	return j.RunContext(context.Background())
+}
+
+// RunContext runs the steps in order: files are written, ffmpeg and ffprobe
+// run with ff.DefaultRunner, metadata is saved with the codec package and
+// rm removes files. Relative names are relative to the working directory.
+func (j Job) RunContext(ctx context.Context) error {
+	for _, s := range j.Steps {
+		err := s.run(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j Job) String() string {
+	for _, s := range j.Steps {
+		if args := s.args(); len(args) > 0 && args[0] == "ffmpeg" {
+			return ff.Step{Args: args}.String()
+		}
+	}
+	if len(j.Steps) > 0 {
+		return ff.Step{Write: j.Steps[0].Write, Data: []byte(j.Steps[0].Data)}.String()
+	}
+	return ""
+}
+
+func (s Step) run(ctx context.Context) error {
+	if s.Write != "" {
+		return os.WriteFile(s.Write, []byte(s.Data), 0644)
+	}
+	if s.Save != nil {
+		return save(*s.Save)
+	}
+	args := s.args()
+	if len(args) == 0 {
+		return nil
+	}
+
+	switch name := args[0]; name {
+	case "ffmpeg", "ffprobe":
+		var stderr bytes.Buffer
+		err := ff.DefaultRunner.Run(ctx, ff.Call{
+			Name:   name,
+			Args:   args[1:],
+			Stderr: &stderr,
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w\n%s", ff.Step{Args: args}, err, stderr.String())
+		}
+		return nil
+	case "rm":
+		for _, arg := range args[1:] {
+			if strings.HasPrefix(arg, "-") {
+				continue
+			}
+			err := os.Remove(arg)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: plan step runs %q", avtools.ErrUnsupported, args[0])
+}
+
+// save writes the metadata of a file natively into a copy of the input.
+func save(s ff.Save) error {
+	f, ok := codec.Lookup(s.Format)
+	if !ok || f.Save == nil {
+		return fmt.Errorf("%w: can't save %s", avtools.ErrUnsupported, s.Format)
+	}
+	meta, err := codec.Decode(s.Meta)
+	if err != nil {
+		return err
+	}
+	return f.Save(s.Input, s.Output, avtools.NewMedia().Merge(meta))
+}
+
+// Marshal writes the plan as YAML for .yml and .yaml names and JSON
+// otherwise, with Dir relative to the directory of the file name.
+func (p Plan) Marshal(name string) ([]byte, error) {
+	if filepath.IsAbs(p.Dir) {
+		at, err := filepath.Abs(filepath.Dir(name))
+		if err != nil {
+			return nil, err
+		}
+		if dir, err := filepath.Rel(at, p.Dir); err == nil {
+			p.Dir = dir
+		}
+	}
+	if isYAML(name) {
+		return yaml.Marshal(p)
+	}
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// Save writes the plan to the file name.
+func (p Plan) Save(name string) error {
+	data, err := p.Marshal(name)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, data, 0644)
+}
+
+// Load reads a plan from the file name, resolving its Dir against the
+// directory of the file.
+func Load(name string) (*Plan, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Plan
+	if isYAML(name) {
+		err = yaml.Unmarshal(data, &p)
+	} else {
+		err = json.Unmarshal(data, &p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if p.Version != Version {
+		return nil, fmt.Errorf("%w %d in %s, want %d: plan it again", ErrVersion, p.Version, name, Version)
+	}
+	if !filepath.IsAbs(p.Dir) {
+		at, err := filepath.Abs(filepath.Dir(name))
+		if err != nil {
+			return nil, err
+		}
+		p.Dir = filepath.Join(at, p.Dir)
+	}
+	return &p, nil
+}
+
+func isYAML(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yml", ".yaml":
+		return true
+	}
+	return false
+}
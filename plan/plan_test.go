@@ -0,0 +1,120 @@
+package plan
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ohzqq/avtools/ff"
+)
+
+// chdir changes to dir for the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestMovedPlan(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "book")
+	for _, d := range []string{dir, filepath.Join(dir, "plans")} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.mp3"), []byte("audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, dir)
+
+	list := filepath.Join(os.TempDir(), "avtools-join-test.txt")
+	steps := []ff.Step{
+		{Write: list, Data: []byte("ffconcat version 1.0\nfile '" + filepath.Join(dir, "a.mp3") + "'\n")},
+		{Cmd: &ff.Layout{
+			Inputs:  []ff.Part{{File: list, Role: ff.Media, Flags: []string{"-f", "concat", "-safe", "0"}}},
+			Outputs: []ff.Part{{File: filepath.Join(dir, "out.mp3"), Flags: []string{"-c", "copy"}}},
+		}},
+		{
+			Save: &ff.Save{Format: "id3", Input: "a.mp3", Output: "updated-a.mp3", Meta: list},
+			Script: &ff.Layout{
+				Inputs:  []ff.Part{{File: "a.mp3", Role: ff.Media}, {File: list, Role: ff.Metadata}},
+				Outputs: []ff.Part{{File: "updated-a.mp3"}},
+			},
+		},
+		{Args: []string{"rm", "-f", list}},
+	}
+	p, err := New(steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Inputs) != 1 || p.Inputs[0].Path != "a.mp3" {
+		t.Errorf("inputs %+v", p.Inputs)
+	}
+	if err := p.Save(filepath.Join("plans", "p.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	moved := filepath.Join(root, "moved")
+	if err := os.Rename(dir, moved); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, root)
+
+	loaded, err := Load(filepath.Join(moved, "plans", "p.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Dir != moved {
+		t.Errorf("dir %s, want %s", loaded.Dir, moved)
+	}
+	if err := loaded.Check(true); err != nil {
+		t.Error(err)
+	}
+
+	got := loaded.Steps()
+	want := []ff.Step{
+		{Write: list, Data: []byte("ffconcat version 1.0\nfile '" + filepath.Join(moved, "a.mp3") + "'\n")},
+		{Args: []string{"ffmpeg", "-f", "concat", "-safe", "0", "-i", list, "-c", "copy", filepath.Join(moved, "out.mp3")}},
+		{Save: &ff.Save{Format: "id3", Input: filepath.Join(moved, "a.mp3"), Output: filepath.Join(moved, "updated-a.mp3"), Meta: list}},
+		{Args: []string{"rm", "-f", list}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d steps, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		g := got[i]
+		if !reflect.DeepEqual(g.Args, w.Args) || string(g.Data) != string(w.Data) || !reflect.DeepEqual(g.Save, w.Save) {
+			t.Errorf("step %d\n got %q %q %+v\nwant %q %q %+v", i+1, g.Args, g.Data, g.Save, w.Args, w.Data, w.Save)
+		}
+	}
+	if s := got[2].Script; s == nil || s.Inputs[0].File != filepath.Join(moved, "a.mp3") || s.Outputs[0].File != filepath.Join(moved, "updated-a.mp3") {
+		t.Errorf("save script %+v", s)
+	}
+}
+
+func TestNote(t *testing.T) {
+	_, err := New([]ff.Step{{Note: "something by hand"}})
+	if err == nil {
+		t.Error("planned a note")
+	}
+}
+
+func TestLoadVersion(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "old.json")
+	if err := os.WriteFile(name, []byte(`{"version": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Load(name)
+	if !errors.Is(err, ErrVersion) {
+		t.Errorf("got %v, want ErrVersion", err)
+	}
+}
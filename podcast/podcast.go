@@ -0,0 +1,211 @@
+package podcast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ohzqq/avtools"
+	"github.com/ohzqq/fidi"
+)
+
+// Version is the version of the Podcast Namespace JSON chapters format
+// written by Dump.
+const Version = "1.2.0"
+
+// Meta is a Podcast Namespace JSON chapters file.
+type Meta struct {
+	fidi.File   `json:"-"`
+	Version     string     `json:"version"`
+	Author      string     `json:"author,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	PodcastName string     `json:"podcastName,omitempty"`
+	Description string     `json:"description,omitempty"`
+	FileName    string     `json:"fileName,omitempty"`
+	Waypoints   bool       `json:"waypoints,omitempty"`
+	Chaps       []*Chapter `json:"chapters"`
+}
+
+type Chapter struct {
+	StartTime float64   `json:"startTime"`
+	EndTime   float64   `json:"endTime,omitempty"`
+	ChTitle   string    `json:"title,omitempty"`
+	Img       string    `json:"img,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	TOC       *bool     `json:"toc,omitempty"`
+	Location  *Location `json:"location,omitempty"`
+	end       time.Duration
+}
+
+type Location struct {
+	Name string `json:"name"`
+	Geo  string `json:"geo"`
+	OSM  string `json:"osm,omitempty"`
+}
+
+func Load(file string) (avtools.Metaz, error) {
+	src, err := avtools.NewFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	chaps := &Meta{}
+	chaps.File = src
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return chaps, err
+	}
+
+	err = chaps.Parse(data)
+	if err != nil {
+		return chaps, err
+	}
+
+	return chaps, nil
+}
+
+func (m *Meta) Parse(data []byte) error {
+	err := json.Unmarshal(data, m)
+	if err != nil {
+		return fmt.Errorf("podcast chapters: %w", err)
+	}
+	if m.Version == "" {
+		return fmt.Errorf("podcast chapters: missing version")
+	}
+
+	chaps := m.Chaps
+	for i, ch := range chaps {
+		ch.end = seconds(ch.EndTime)
+		if ch.EndTime == 0 && i+1 < len(chaps) {
+			ch.end = seconds(chaps[i+1].StartTime)
+		}
+	}
+
+	return nil
+}
+
+// Dump writes the chapters of meta as JSON chapters. The img, url and toc
+// chapter tags are kept, as are the title, artist, album and description
+// tags of the media.
+func Dump(meta avtools.Meta) ([]byte, error) {
+	tags := meta.Tags()
+	chaps := Meta{
+		Version:     Version,
+		Title:       tags["title"],
+		Author:      tags["artist"],
+		PodcastName: tags["album"],
+		Description: tags["description"],
+		Chaps:       []*Chapter{},
+	}
+
+	for _, ch := range meta.Chapters() {
+		c := &Chapter{
+			StartTime: ch.Start().Dur.Seconds(),
+			ChTitle:   ch.Title(),
+			Img:       ch.Tags["img"],
+			URL:       ch.Tags["url"],
+		}
+		if end := ch.End().Dur; end > ch.Start().Dur {
+			c.EndTime = end.Seconds()
+		}
+		if toc, err := strconv.ParseBool(ch.Tags["toc"]); err == nil {
+			c.TOC = &toc
+		}
+		if name, ok := ch.Tags["location"]; ok {
+			c.Location = &Location{
+				Name: name,
+				Geo:  ch.Tags["geo"],
+				OSM:  ch.Tags["osm"],
+			}
+		}
+		chaps.Chaps = append(chaps.Chaps, c)
+	}
+
+	data, err := json.MarshalIndent(chaps, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("podcast chapters: %w", err)
+	}
+
+	return append(data, '\n'), nil
+}
+
+func (m Meta) Chapters() []avtools.ChapterMeta {
+	var chaps []avtools.ChapterMeta
+	for _, ch := range m.Chaps {
+		chaps = append(chaps, ch)
+	}
+	return chaps
+}
+
+func (m Meta) Tags() map[string]string {
+	tags := make(map[string]string)
+	for key, val := range map[string]string{
+		"title":       m.Title,
+		"artist":      m.Author,
+		"album":       m.PodcastName,
+		"description": m.Description,
+		"filename":    m.FileName,
+	} {
+		if val != "" {
+			tags[key] = val
+		}
+	}
+	return tags
+}
+
+func (m Meta) Streams() []map[string]string {
+	return []map[string]string{}
+}
+
+func (m Meta) Source() fidi.File {
+	return m.File
+}
+
+func (ch Chapter) Start() time.Duration {
+	return seconds(ch.StartTime)
+}
+
+func (ch Chapter) End() time.Duration {
+	return ch.end
+}
+
+func (ch Chapter) Title() string {
+	return ch.ChTitle
+}
+
+// Tags holds img, url, toc and location, when set.
+func (ch Chapter) Tags() map[string]string {
+	tags := make(map[string]string)
+	if ch.Img != "" {
+		tags["img"] = ch.Img
+	}
+	if ch.URL != "" {
+		tags["url"] = ch.URL
+	}
+	if ch.TOC != nil {
+		tags["toc"] = strconv.FormatBool(*ch.TOC)
+	}
+	if ch.Location != nil {
+		tags["location"] = ch.Location.Name
+		tags["geo"] = ch.Location.Geo
+		if ch.Location.OSM != "" {
+			tags["osm"] = ch.Location.OSM
+		}
+	}
+	return tags
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Millisecond)
+}
+
+// Sniff reports whether data looks like a JSON chapters file.
+func Sniff(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return bytes.HasPrefix(data, []byte("{")) &&
+		bytes.Contains(data, []byte(`"startTime"`))
+}
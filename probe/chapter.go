@@ -1,34 +1,57 @@
 package probe
 
 import (
-	"log"
+	"fmt"
 	"time"
 
+	"github.com/ohzqq/avtools"
 	"github.com/ohzqq/dur"
 )
 
 type Chapter struct {
 	Base         string            `json:"time_base" ini:"TIMEBASE"`
+	StartTicks   int64             `json:"start"`
+	EndTicks     int64             `json:"end"`
 	StartTime    string            `json:"start_time" ini:"START"`
 	EndTime      string            `json:"end_time" ini:"END"`
 	ChapterTitle string            `ini:"title"`
 	Tags         map[string]string `json:"tags"`
+	start        avtools.Stamp
+	end          avtools.Stamp
 }
 
-func (c Chapter) Start() time.Duration {
-	f, err := dur.Parse(c.StartTime)
+// parse takes the chapter times from the start and end ticks, or from the
+// rounded start_time and end_time when there's no usable time_base.
+func (c *Chapter) parse() error {
+	if base, err := avtools.ParseTimebase(c.Base); err == nil {
+		c.start = avtools.NewStamp(c.StartTicks, base)
+		c.end = avtools.NewStamp(c.EndTicks, base)
+		return nil
+	}
+
+	ss, err := dur.Parse(c.StartTime)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("probe: chapter start %w %q", avtools.ErrInvalidTimestamp, c.StartTime)
 	}
-	return f.Dur
+	to, err := dur.Parse(c.EndTime)
+	if err != nil {
+		return fmt.Errorf("probe: chapter end %w %q", avtools.ErrInvalidTimestamp, c.EndTime)
+	}
+	c.start = avtools.StampOf(ss.Dur)
+	c.end = avtools.StampOf(to.Dur)
+	return nil
+}
+
+func (c Chapter) Start() time.Duration {
+	return c.start.Duration()
 }
 
 func (c Chapter) End() time.Duration {
-	f, err := dur.Parse(c.EndTime)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return f.Dur
+	return c.end.Duration()
+}
+
+func (c Chapter) Stamps() (start, end avtools.Stamp) {
+	return c.start, c.end
 }
 
 func (c Chapter) Title() string {
@@ -1,13 +1,18 @@
 package probe
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ohzqq/avtools"
 	"github.com/ohzqq/avtools/ff"
+	"github.com/ohzqq/avtools/vorbis"
+	"github.com/ohzqq/dur"
 	"github.com/ohzqq/fidi"
 	ffmpeg "github.com/u2takey/ffmpeg-go"
 )
@@ -16,33 +21,106 @@ type Meta struct {
 	StreamEntry  []map[string]any `json:"streams"`
 	Format       Format           `json:"format"`
 	ChapterEntry []Chapter        `json:"chapters"`
+	// StreamInfo are the streams, typed.
+	StreamInfo Streams `json:"-"`
 	fidi.File
 }
 
 type Format struct {
-	Filename string            `json:"filename"`
-	Dur      string            `json:"duration"`
-	Size     string            `json:"size"`
-	BitRate  string            `json:"bit_rate"`
-	Tags     map[string]string `json:"tags"`
+	Filename       string            `json:"filename"`
+	NbStreams      int               `json:"nb_streams"`
+	NbPrograms     int               `json:"nb_programs"`
+	FormatName     string            `json:"format_name"`
+	FormatLongName string            `json:"format_long_name"`
+	StartTime      string            `json:"start_time"`
+	Dur            string            `json:"duration"`
+	Size           string            `json:"size"`
+	BitRate        string            `json:"bit_rate"`
+	ProbeScore     int               `json:"probe_score"`
+	Tags           map[string]string `json:"tags"`
 }
 
 func Load(input string) (avtools.Metaz, error) {
-	args := ffmpeg.MergeKwArgs(probeArgs)
-	info, err := ffmpeg.ProbeWithTimeoutExec(input, 0, args)
+	return Probe(input)
+}
+
+// Timeout is how long ffprobe may run before it is stopped, unlimited when
+// 0.
+var Timeout = time.Minute
+
+// Probe runs ffprobe on input.
+func Probe(input string) (Meta, error) {
+	return ProbeContext(context.Background(), input)
+}
+
+// ProbeContext runs ffprobe on input, stopping it when ctx is done or after
+// Timeout.
+func ProbeContext(ctx context.Context, input string) (Meta, error) {
+	return ProbeWith(ctx, nil, input)
+}
+
+// ProbeWith is ProbeContext running ffprobe with r, ff.DefaultRunner when
+// nil.
+func ProbeWith(ctx context.Context, r ff.Runner, input string) (Meta, error) {
+	if r == nil {
+		r = ff.DefaultRunner
+	}
+
+	src, err := avtools.NewFile(input)
 	if err != nil {
 		return Meta{}, err
 	}
-	//fmt.Println(info)
 
-	data := []byte(info)
+	if Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, Timeout)
+		defer cancel()
+	}
+
+	args := ffmpeg.ConvertKwargsToCmdLineArgs(ffmpeg.MergeKwArgs(probeArgs))
+	args = append(args, input)
+
+	var stdout, stderr bytes.Buffer
+	err = r.Run(ctx, ff.Call{
+		Name:   "ffprobe",
+		Args:   args,
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		return Meta{}, &avtools.ProbeError{Input: input, Stderr: stderr.String(), Err: err}
+	}
+
+	return Parse(stdout.Bytes(), src)
+}
 
+// Parse reads ffprobe's json output for the file src.
+func Parse(data []byte, src fidi.File) (Meta, error) {
 	var meta Meta
-	err = json.Unmarshal(data, &meta)
+	err := json.Unmarshal(data, &meta)
 	if err != nil {
-		return Meta{}, err
+		return Meta{}, &avtools.ProbeError{Input: src.Path(), Err: err}
+	}
+	meta.File = src
+
+	var raw struct {
+		Streams []json.RawMessage `json:"streams"`
+	}
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return Meta{}, &avtools.ProbeError{Input: src.Path(), Err: err}
+	}
+	meta.StreamInfo, err = ParseStreams(raw.Streams)
+	if err != nil {
+		return Meta{}, &avtools.ProbeError{Input: src.Path(), Err: err}
+	}
+
+	for i := range meta.ChapterEntry {
+		err := meta.ChapterEntry[i].parse()
+		if err != nil {
+			return Meta{}, err
+		}
 	}
-	meta.File = fidi.NewFile(input)
 
 	return meta, nil
 }
@@ -59,14 +137,48 @@ func DumpFFMeta(file string) *ff.Cmd {
 	return cmd.Compile()
 }
 
+// Chapters returns the probed chapters or, when there are none, the
+// chapters stored as CHAPTERxxx comments.
 func (m Meta) Chapters() []avtools.ChapterMeta {
 	var chaps []avtools.ChapterMeta
 	for _, ch := range m.ChapterEntry {
 		chaps = append(chaps, ch)
 	}
+	if len(chaps) == 0 {
+		for _, ch := range vorbis.Chapters(m.comments(), m.duration()) {
+			chaps = append(chaps, ch)
+		}
+	}
 	return chaps
 }
 
+// comments merges the format tags with the tags of the audio streams, where
+// Ogg files keep their comments.
+func (m Meta) comments() map[string]string {
+	tags := make(map[string]string)
+	for _, stream := range m.StreamEntry {
+		if st, ok := stream["tags"].(map[string]any); ok {
+			for k, v := range st {
+				if s, ok := v.(string); ok {
+					tags[k] = s
+				}
+			}
+		}
+	}
+	for k, v := range m.Format.Tags {
+		tags[k] = v
+	}
+	return tags
+}
+
+func (m Meta) duration() time.Duration {
+	d, err := dur.Parse(m.Format.Dur)
+	if err != nil {
+		return 0
+	}
+	return d.Dur
+}
+
 func (m Meta) Streams() []map[string]string {
 	var streams []map[string]string
 	for _, stream := range m.StreamEntry {
@@ -74,14 +186,13 @@ func (m Meta) Streams() []map[string]string {
 		for key, raw := range stream {
 			switch val := raw.(type) {
 			case float64:
-				meta[key] = strconv.Itoa(int(val))
+				meta[key] = strconv.FormatFloat(val, 'f', -1, 64)
 			case string:
 				meta[key] = val
 			case map[string]any:
 				if key == "disposition" {
-					if val["attached_pic"].(float64) == 0 {
-						meta["cover"] = "false"
-					} else {
+					meta["cover"] = "false"
+					if pic, ok := val["attached_pic"].(float64); ok && pic != 0 {
 						meta["cover"] = "true"
 					}
 				}
@@ -92,12 +203,14 @@ func (m Meta) Streams() []map[string]string {
 	return streams
 }
 
+// Tags returns the format tags, without CHAPTERxxx comments.
 func (m Meta) Tags() map[string]string {
-	m.Format.Tags["filename"] = m.Format.Filename
-	m.Format.Tags["duration"] = m.Format.Dur
-	m.Format.Tags["size"] = m.Format.Size
-	m.Format.Tags["bit_rate"] = m.Format.BitRate
-	return m.Format.Tags
+	tags := vorbis.StripChapters(m.Format.Tags)
+	tags["filename"] = m.Format.Filename
+	tags["duration"] = m.Format.Dur
+	tags["size"] = m.Format.Size
+	tags["bit_rate"] = m.Format.BitRate
+	return tags
 }
 
 func (m Meta) Source() fidi.File {
@@ -106,8 +219,7 @@ func (m Meta) Source() fidi.File {
 
 var probeArgs = []ffmpeg.KwArgs{
 	ffmpeg.KwArgs{"show_chapters": ""},
-	ffmpeg.KwArgs{"pretty": ""},
 	//ffmpeg.KwArgs{"select_streams": "a"},
-	ffmpeg.KwArgs{"show_entries": "stream:format=filename, start_time, duration, size, bit_rate:format_tags"},
+	ffmpeg.KwArgs{"show_entries": "stream:format=filename,nb_streams,nb_programs,format_name,format_long_name,start_time,duration,size,bit_rate,probe_score:format_tags"},
 	ffmpeg.KwArgs{"of": "json"},
 }
@@ -0,0 +1,160 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ohzqq/avtools"
+	"github.com/ohzqq/avtools/ff/fftest"
+)
+
+const probeJSON = `{
+  "streams": [
+    {"index": 0, "codec_type": "audio", "codec_name": "opus", "tags": {"CHAPTER001": "00:00:00.000", "CHAPTER001NAME": "Comment"}},
+    {"index": 1, "codec_type": "video", "codec_name": "mjpeg", "disposition": {"attached_pic": 1}}
+  ],
+  "format": {"filename": "book", "duration": "10.000000", "size": "4", "tags": {"title": "Book", "CHAPTER001": "00:00:00.000"}},
+  "chapters": [
+    {"time_base": "1/1000", "start": 0, "end": 4000, "tags": {"title": "One"}},
+    {"time_base": "1/44100", "start": 176400, "end": 441000, "tags": {"title": "Two"}}
+  ]
+}`
+
+const noChapters = `{
+  "streams": [{"index": 0, "codec_type": "audio", "tags": {"CHAPTER001": "00:00:00.000", "CHAPTER001NAME": "Only", "CHAPTER002": "00:00:06.500", "CHAPTER002NAME": "Last"}}],
+  "format": {"filename": "book", "duration": "10.000000"}
+}`
+
+func input(t *testing.T) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "book.opus")
+	err := os.WriteFile(name, []byte{0, 1, 2, 3}, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestProbe(t *testing.T) {
+	type chapter struct {
+		title      string
+		start, end time.Duration
+	}
+	for _, tt := range []struct {
+		name  string
+		json  string
+		chaps []chapter
+	}{
+		{
+			name: "chapters",
+			json: probeJSON,
+			chaps: []chapter{
+				{"One", 0, 4 * time.Second},
+				{"Two", 4 * time.Second, 10 * time.Second},
+			},
+		},
+		{
+			name: "comments",
+			json: noChapters,
+			chaps: []chapter{
+				{"Only", 0, 6500 * time.Millisecond},
+				{"Last", 6500 * time.Millisecond, 10 * time.Second},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := fftest.New().Probe(tt.json)
+			in := input(t)
+			m, err := ProbeWith(context.Background(), r, in)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			argv := r.Argv()
+			if len(argv) != 1 || argv[0][0] != "ffprobe" || argv[0][len(argv[0])-1] != in ||
+				!strings.Contains(strings.Join(argv[0], " "), "-of json") {
+				t.Errorf("argv %q", argv)
+			}
+
+			chaps := m.Chapters()
+			if len(chaps) != len(tt.chaps) {
+				t.Fatalf("got %d chapters, want %d", len(chaps), len(tt.chaps))
+			}
+			for i, w := range tt.chaps {
+				c := chaps[i]
+				if c.Title() != w.title || c.Start() != w.start || c.End() != w.end {
+					t.Errorf("chapter %d: got %q %s-%s, want %q %s-%s", i+1,
+						c.Title(), c.Start(), c.End(), w.title, w.start, w.end)
+				}
+			}
+			if _, ok := m.Tags()["CHAPTER001"]; ok {
+				t.Error("chapter comments left in the tags")
+			}
+			if m.Tags()["duration"] != "10.000000" {
+				t.Errorf("tags %v", m.Tags())
+			}
+		})
+	}
+}
+
+func TestProbeStreams(t *testing.T) {
+	m, err := ProbeWith(context.Background(), fftest.New().Probe(probeJSON), input(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	streams := m.Streams()
+	if len(streams) != 2 || streams[0]["codec_type"] != "audio" || streams[0]["cover"] != "" || streams[1]["cover"] != "true" {
+		t.Errorf("streams %v", streams)
+	}
+}
+
+func TestProbeErrors(t *testing.T) {
+	failed := errors.New("exit status 1")
+	for _, tt := range []struct {
+		name   string
+		resp   fftest.Response
+		cancel bool
+		is     error
+		stderr string
+	}{
+		{
+			name:   "ffprobe fails",
+			resp:   fftest.Response{Stderr: "book.opus: Invalid data found", Err: failed},
+			is:     failed,
+			stderr: "Invalid data found",
+		},
+		{
+			name: "bad json",
+			resp: fftest.Response{Stdout: "{"},
+			is:   avtools.ErrProbeFailed,
+		},
+		{
+			name:   "canceled",
+			cancel: true,
+			is:     avtools.ErrCanceled,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+			r := fftest.New().On("ffprobe", tt.resp)
+			_, err := ProbeWith(ctx, r, input(t))
+
+			if !errors.Is(err, avtools.ErrProbeFailed) || !errors.Is(err, tt.is) {
+				t.Fatalf("got %v, want %v", err, tt.is)
+			}
+			var pe *avtools.ProbeError
+			if !errors.As(err, &pe) || !strings.Contains(pe.Stderr, tt.stderr) {
+				t.Errorf("stderr %q, want %q", pe.Stderr, tt.stderr)
+			}
+		})
+	}
+}
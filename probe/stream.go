@@ -0,0 +1,240 @@
+package probe
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/ohzqq/avtools"
+)
+
+// Streams are the probed streams by type, each in file order.
+type Streams struct {
+	Audio      []AudioStream
+	Video      []VideoStream
+	Subtitle   []SubtitleStream
+	Attachment []AttachmentStream
+	// Other holds data streams and any type without its own struct.
+	Other []Stream
+}
+
+// Stream holds the fields ffprobe reports for every type of stream.
+type Stream struct {
+	Index         int               `json:"index"`
+	CodecName     string            `json:"codec_name"`
+	CodecLongName string            `json:"codec_long_name"`
+	CodecType     string            `json:"codec_type"`
+	CodecTag      string            `json:"codec_tag_string"`
+	Profile       string            `json:"profile"`
+	TimeBase      string            `json:"time_base"`
+	RFrameRate    string            `json:"r_frame_rate"`
+	AvgFrameRate  string            `json:"avg_frame_rate"`
+	StartPts      int64             `json:"start_pts"`
+	StartTime     string            `json:"start_time"`
+	DurationTs    int64             `json:"duration_ts"`
+	Duration      string            `json:"duration"`
+	BitRate       string            `json:"bit_rate"`
+	NbFrames      string            `json:"nb_frames"`
+	Disposition   Disposition       `json:"disposition"`
+	Tags          map[string]string `json:"tags"`
+	SideData      []SideData        `json:"side_data_list"`
+}
+
+// Language is the stream's language tag, an ISO 639-2 code like eng.
+func (s Stream) Language() string {
+	return s.Tags["language"]
+}
+
+// Title is the stream's title tag.
+func (s Stream) Title() string {
+	return s.Tags["title"]
+}
+
+// AudioStream is an audio stream.
+type AudioStream struct {
+	Stream
+	SampleFmt        string `json:"sample_fmt"`
+	SampleRate       int    `json:"sample_rate,string"`
+	Channels         int    `json:"channels"`
+	ChannelLayout    string `json:"channel_layout"`
+	BitsPerSample    int    `json:"bits_per_sample"`
+	BitsPerRawSample int    `json:"bits_per_raw_sample,string"`
+}
+
+// BitDepth is the bits per sample of the decoded audio, or of the coded
+// audio when the decoded depth isn't known.
+func (a AudioStream) BitDepth() int {
+	if a.BitsPerRawSample > 0 {
+		return a.BitsPerRawSample
+	}
+	return a.BitsPerSample
+}
+
+// VideoStream is a video stream, including attached pictures like cover
+// art.
+type VideoStream struct {
+	Stream
+	Width          int    `json:"width"`
+	Height         int    `json:"height"`
+	CodedWidth     int    `json:"coded_width"`
+	CodedHeight    int    `json:"coded_height"`
+	HasBFrames     int    `json:"has_b_frames"`
+	SAR            string `json:"sample_aspect_ratio"`
+	DAR            string `json:"display_aspect_ratio"`
+	PixFmt         string `json:"pix_fmt"`
+	Level          int    `json:"level"`
+	ColorRange     string `json:"color_range"`
+	ColorSpace     string `json:"color_space"`
+	ColorTransfer  string `json:"color_transfer"`
+	ColorPrimaries string `json:"color_primaries"`
+	ChromaLocation string `json:"chroma_location"`
+	FieldOrder     string `json:"field_order"`
+}
+
+// IsCover reports whether the stream is an attached picture.
+func (v VideoStream) IsCover() bool {
+	return bool(v.Disposition.AttachedPic)
+}
+
+// Rate is the stream's r_frame_rate.
+func (v VideoStream) Rate() (avtools.Rate, error) {
+	return avtools.ParseRate(v.RFrameRate)
+}
+
+// Rotation is the rotation in degrees from the display matrix side data,
+// or the rotate tag of older files.
+func (v VideoStream) Rotation() int {
+	for _, sd := range v.SideData {
+		if sd.Rotation != nil {
+			return int(*sd.Rotation)
+		}
+	}
+	r, _ := strconv.Atoi(v.Tags["rotate"])
+	return r
+}
+
+// SubtitleStream is a subtitle stream. Bitmap subtitles have a size.
+type SubtitleStream struct {
+	Stream
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// AttachmentStream is an attached file, like a font in a matroska file.
+type AttachmentStream struct {
+	Stream
+}
+
+// Filename is the name of the attached file.
+func (a AttachmentStream) Filename() string {
+	return a.Tags["filename"]
+}
+
+// Mimetype is the type of the attached file.
+func (a AttachmentStream) Mimetype() string {
+	return a.Tags["mimetype"]
+}
+
+// SideData is an entry of a stream's side data list. Only the common
+// fields are typed; Raw keeps the whole entry.
+type SideData struct {
+	Type     string   `json:"side_data_type"`
+	Rotation *float64 `json:"rotation"`
+	Raw      map[string]any
+}
+
+func (sd *SideData) UnmarshalJSON(data []byte) error {
+	type sideData SideData
+	var s sideData
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(data, &s.Raw)
+	if err != nil {
+		return err
+	}
+	*sd = SideData(s)
+	return nil
+}
+
+// Disposition holds the disposition flags of a stream.
+type Disposition struct {
+	Default         Flag `json:"default"`
+	Dub             Flag `json:"dub"`
+	Original        Flag `json:"original"`
+	Comment         Flag `json:"comment"`
+	Lyrics          Flag `json:"lyrics"`
+	Karaoke         Flag `json:"karaoke"`
+	Forced          Flag `json:"forced"`
+	HearingImpaired Flag `json:"hearing_impaired"`
+	VisualImpaired  Flag `json:"visual_impaired"`
+	CleanEffects    Flag `json:"clean_effects"`
+	AttachedPic     Flag `json:"attached_pic"`
+	TimedThumbnails Flag `json:"timed_thumbnails"`
+	NonDiegetic     Flag `json:"non_diegetic"`
+	Captions        Flag `json:"captions"`
+	Descriptions    Flag `json:"descriptions"`
+	Metadata        Flag `json:"metadata"`
+	Dependent       Flag `json:"dependent"`
+	StillImage      Flag `json:"still_image"`
+}
+
+// Flag is a disposition flag, which ffprobe writes as 0 or 1.
+type Flag bool
+
+func (f *Flag) UnmarshalJSON(data []byte) error {
+	var n float64
+	err := json.Unmarshal(data, &n)
+	if err != nil {
+		var b bool
+		if json.Unmarshal(data, &b) != nil {
+			return err
+		}
+		*f = Flag(b)
+		return nil
+	}
+	*f = n != 0
+	return nil
+}
+
+// ParseStreams reads the typed streams from the streams of ffprobe's json
+// output.
+func ParseStreams(entries []json.RawMessage) (Streams, error) {
+	var streams Streams
+	for _, raw := range entries {
+		var kind struct {
+			CodecType string `json:"codec_type"`
+		}
+		err := json.Unmarshal(raw, &kind)
+		if err != nil {
+			return streams, err
+		}
+
+		switch kind.CodecType {
+		case "audio":
+			var s AudioStream
+			err = json.Unmarshal(raw, &s)
+			streams.Audio = append(streams.Audio, s)
+		case "video":
+			var s VideoStream
+			err = json.Unmarshal(raw, &s)
+			streams.Video = append(streams.Video, s)
+		case "subtitle":
+			var s SubtitleStream
+			err = json.Unmarshal(raw, &s)
+			streams.Subtitle = append(streams.Subtitle, s)
+		case "attachment":
+			var s AttachmentStream
+			err = json.Unmarshal(raw, &s)
+			streams.Attachment = append(streams.Attachment, s)
+		default:
+			var s Stream
+			err = json.Unmarshal(raw, &s)
+			streams.Other = append(streams.Other, s)
+		}
+		if err != nil {
+			return streams, err
+		}
+	}
+	return streams, nil
+}
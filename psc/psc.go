@@ -0,0 +1,289 @@
+package psc
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ohzqq/avtools"
+	"github.com/ohzqq/fidi"
+)
+
+const (
+	Namespace = "http://podlove.org/simple-chapters"
+	Version   = "1.2"
+)
+
+// Chapters is a Podlove Simple Chapters file, either the XML element or
+// its JSON form. Duration, when set, ends the last chapter.
+type Chapters struct {
+	fidi.File `xml:"-" json:"-"`
+	XMLName   xml.Name      `xml:"chapters" json:"-"`
+	Version   string        `xml:"version,attr" json:"version,omitempty"`
+	Chaps     []*Chapter    `xml:"chapter" json:"chapters"`
+	Duration  time.Duration `xml:"-" json:"-"`
+}
+
+type Chapter struct {
+	StartTime string `xml:"start,attr" json:"start"`
+	ChTitle   string `xml:"title,attr" json:"title"`
+	Href      string `xml:"href,attr,omitempty" json:"href,omitempty"`
+	Image     string `xml:"image,attr,omitempty" json:"image,omitempty"`
+	start     time.Duration
+	end       time.Duration
+}
+
+func Load(file string) (avtools.Metaz, error) {
+	src, err := avtools.NewFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := &Chapters{
+		File: src,
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return ch, err
+	}
+
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		err = ch.ParseJSON(data)
+	} else {
+		err = ch.Parse(data)
+	}
+	if err != nil {
+		return ch, err
+	}
+
+	return ch, nil
+}
+
+// Parse reads the psc:chapters element, which may be the root of the
+// document or nested in a feed item.
+func (c *Chapters) Parse(data []byte) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("psc: no chapters element")
+		}
+		if el, ok := tok.(xml.StartElement); ok && el.Name.Local == "chapters" {
+			err := dec.DecodeElement(c, &el)
+			if err != nil {
+				return fmt.Errorf("psc: %w", err)
+			}
+			break
+		}
+	}
+	return c.parseTimes()
+}
+
+// ParseJSON reads a JSON chapter list, either an object with a chapters
+// array or the bare array.
+func (c *Chapters) ParseJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	var err error
+	if len(data) > 0 && data[0] == '[' {
+		err = json.Unmarshal(data, &c.Chaps)
+	} else {
+		err = json.Unmarshal(data, c)
+	}
+	if err != nil {
+		return fmt.Errorf("psc: %w", err)
+	}
+	return c.parseTimes()
+}
+
+func (c *Chapters) parseTimes() error {
+	for _, ch := range c.Chaps {
+		var err error
+		ch.start, err = ParseTime(ch.StartTime)
+		if err != nil {
+			return err
+		}
+	}
+	c.setEnds()
+	return nil
+}
+
+// SetDuration sets the media duration, which ends the last chapter.
+func (c *Chapters) SetDuration(d time.Duration) {
+	c.Duration = d
+	c.setEnds()
+}
+
+func (c *Chapters) setEnds() {
+	for i, ch := range c.Chaps {
+		ch.end = c.Duration
+		if i+1 < len(c.Chaps) {
+			ch.end = c.Chaps[i+1].start
+		}
+	}
+}
+
+// Dump writes the chapters of meta as psc XML.
+func Dump(meta avtools.Meta) ([]byte, error) {
+	chaps := fromMeta(meta)
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	err := enc.Encode(struct {
+		XMLName xml.Name   `xml:"psc:chapters"`
+		NS      string     `xml:"xmlns:psc,attr"`
+		Version string     `xml:"version,attr"`
+		Chaps   []*Chapter `xml:"psc:chapter"`
+	}{
+		NS:      Namespace,
+		Version: Version,
+		Chaps:   chaps.Chaps,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("psc: %w", err)
+	}
+	buf.WriteString("\n")
+
+	return buf.Bytes(), nil
+}
+
+// DumpJSON writes the chapters of meta as psc JSON.
+func DumpJSON(meta avtools.Meta) ([]byte, error) {
+	data, err := json.MarshalIndent(fromMeta(meta), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("psc: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+func fromMeta(meta avtools.Meta) Chapters {
+	chaps := Chapters{
+		Version: Version,
+		Chaps:   []*Chapter{},
+	}
+	for _, ch := range meta.Chapters() {
+		chaps.Chaps = append(chaps.Chaps, &Chapter{
+			StartTime: FormatTime(ch.Start().Dur),
+			ChTitle:   ch.Title(),
+			Href:      ch.Tags["href"],
+			Image:     ch.Tags["image"],
+		})
+	}
+	return chaps
+}
+
+func (c Chapters) Chapters() []avtools.ChapterMeta {
+	var chaps []avtools.ChapterMeta
+	for _, ch := range c.Chaps {
+		chaps = append(chaps, ch)
+	}
+	return chaps
+}
+
+func (c Chapters) Tags() map[string]string {
+	tags := make(map[string]string)
+	if c.Duration > 0 {
+		tags["duration"] = strconv.FormatFloat(c.Duration.Seconds(), 'f', 3, 64)
+	}
+	return tags
+}
+
+func (c Chapters) Streams() []map[string]string {
+	return []map[string]string{}
+}
+
+func (c Chapters) Source() fidi.File {
+	return c.File
+}
+
+func (ch Chapter) Start() time.Duration {
+	return ch.start
+}
+
+func (ch Chapter) End() time.Duration {
+	return ch.end
+}
+
+func (ch Chapter) Title() string {
+	return ch.ChTitle
+}
+
+// Tags holds href and image, when set.
+func (ch Chapter) Tags() map[string]string {
+	tags := make(map[string]string)
+	if ch.Href != "" {
+		tags["href"] = ch.Href
+	}
+	if ch.Image != "" {
+		tags["image"] = ch.Image
+	}
+	return tags
+}
+
+// ParseTime parses a normal play time: [[HH:]MM:]SS[.mmm].
+func ParseTime(t string) (time.Duration, error) {
+	t = strings.TrimSpace(t)
+	invalid := fmt.Errorf("psc: %w %q", avtools.ErrInvalidTimestamp, t)
+
+	secs, frac, _ := strings.Cut(t, ".")
+	split := strings.Split(secs, ":")
+	if len(split) > 3 {
+		return 0, invalid
+	}
+
+	var d time.Duration
+	for _, s := range split {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return 0, invalid
+		}
+		d = d*60 + time.Duration(n)
+	}
+	d *= time.Second
+
+	if frac != "" {
+		if len(frac) > 3 {
+			frac = frac[:3]
+		}
+		frac += strings.Repeat("0", 3-len(frac))
+		ms, err := strconv.Atoi(frac)
+		if err != nil {
+			return 0, invalid
+		}
+		d += time.Duration(ms) * time.Millisecond
+	}
+
+	return d, nil
+}
+
+func FormatTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d",
+		ms/3600000,
+		ms/60000%60,
+		ms/1000%60,
+		ms%1000,
+	)
+}
+
+// Sniff reports whether data holds psc XML, or a JSON chapter list with
+// start times as strings.
+func Sniff(data []byte) bool {
+	if bytes.Contains(data, []byte(Namespace)) {
+		return true
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || (data[0] != '{' && data[0] != '[') {
+		return false
+	}
+	return bytes.Contains(data, []byte(`"start"`)) &&
+		!bytes.Contains(data, []byte(`"startTime"`))
+}
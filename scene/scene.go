@@ -0,0 +1,185 @@
+package scene
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ohzqq/avtools"
+	"github.com/ohzqq/avtools/ff"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultThreshold is the scene score, from 0 to 1, a frame has to
+	// reach to start a new scene.
+	DefaultThreshold = 0.4
+	// DefaultMinLength is the shortest scene kept.
+	DefaultMinLength = time.Second
+	// DefaultTitle is the template scenes are titled with.
+	DefaultTitle = "Scene {{.Num}}"
+)
+
+var (
+	// showinfo, after select='gt(scene,X)'
+	ptsTime = regexp.MustCompile(`pts_time:\s*(-?[\d.]+)`)
+	// scdet
+	scdTime = regexp.MustCompile(`lavfi\.scd\.time:\s*(-?[\d.]+)`)
+)
+
+// Options are how scenes are detected.
+type Options struct {
+	// Threshold is the scene score from 0 to 1 that counts as a cut.
+	Threshold float64
+	// MinLength is the shortest scene; cuts closer than it to the last one
+	// are skipped.
+	MinLength time.Duration
+}
+
+// Detect runs ffmpeg's scene detection over the video of input, returning
+// the times of frames scoring over threshold.
+func Detect(input string, threshold float64) ([]time.Duration, error) {
+	return DetectContext(context.Background(), input, threshold)
+}
+
+// DetectContext is Detect, stopping ffmpeg when ctx is done.
+func DetectContext(ctx context.Context, input string, threshold float64) ([]time.Duration, error) {
+	return DetectWith(ctx, nil, input, threshold)
+}
+
+// DetectWith is DetectContext running ffmpeg with r, ff.DefaultRunner when
+// nil.
+func DetectWith(ctx context.Context, r ff.Runner, input string, threshold float64) ([]time.Duration, error) {
+	if r == nil {
+		r = ff.DefaultRunner
+	}
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+	filter := fmt.Sprintf(
+		"select='gt(scene,%s)',showinfo",
+		strconv.FormatFloat(threshold, 'f', -1, 64),
+	)
+
+	var stderr bytes.Buffer
+	err := r.Run(ctx, ff.Call{
+		Name: "ffmpeg",
+		Args: []string{
+			"-hide_banner",
+			"-nostats",
+			"-i", input,
+			"-an",
+			"-vf", filter,
+			"-f", "null",
+			"-",
+		},
+		Stderr: &stderr,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scene detect %s: %w\n%s", input, err, stderr.String())
+	}
+
+	return Parse(&stderr)
+}
+
+// Parse reads cut times from the log of showinfo following a scene select,
+// or of the scdet filter, in order.
+func Parse(r io.Reader) ([]time.Duration, error) {
+	var cuts []time.Duration
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var m []string
+		switch {
+		case strings.Contains(line, "showinfo"):
+			m = ptsTime.FindStringSubmatch(line)
+		case strings.Contains(line, "lavfi.scd.time"):
+			m = scdTime.FindStringSubmatch(line)
+		}
+		if m == nil {
+			continue
+		}
+		f, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("scene detect: %w %q", avtools.ErrInvalidTimestamp, m[1])
+		}
+		cuts = append(cuts, time.Duration(f*float64(time.Second)).Round(time.Millisecond))
+	}
+	sort.Slice(cuts, func(i, j int) bool { return cuts[i] < cuts[j] })
+
+	return cuts, scanner.Err()
+}
+
+// Cuts drops the cuts that would make a scene of media of duration d
+// shorter than min.
+func Cuts(cuts []time.Duration, d time.Duration, min time.Duration) []time.Duration {
+	var kept []time.Duration
+	last := time.Duration(0)
+	for _, c := range cuts {
+		if c-last < min || c <= last {
+			continue
+		}
+		if d > 0 && d-c < min {
+			break
+		}
+		kept = append(kept, c)
+		last = c
+	}
+	return kept
+}
+
+// Chapters makes a chapter for every scene of media of duration d, titled
+// Scene 1, Scene 2 and so on.
+func Chapters(cuts []time.Duration, d time.Duration, opts Options) avtools.Chapters {
+	if opts.MinLength == 0 {
+		opts.MinLength = DefaultMinLength
+	}
+
+	var chaps avtools.Chapters
+	start := time.Duration(0)
+	for _, c := range append(Cuts(cuts, d, opts.MinLength), d) {
+		ch := &avtools.Chapter{
+			Tags: make(map[string]string),
+		}
+		ch.SetStamps(
+			avtools.StampOf(start).Rescale(avtools.Millisecond, avtools.RoundNearest),
+			avtools.StampOf(c).Rescale(avtools.Millisecond, avtools.RoundNearest),
+		)
+		chaps = append(chaps, ch)
+		start = c
+	}
+	chaps.Renumber(DefaultTitle)
+
+	return chaps
+}
+
+// Clip is a yygif clip.
+type Clip struct {
+	Start string `yaml:"s"`
+	End   string `yaml:"e"`
+}
+
+// DumpClips writes chapters as yygif clip yaml, a scene of one clip per
+// chapter, so each can be made into a gif.
+func DumpClips(video string, chaps []*avtools.Chapter) ([]byte, error) {
+	scenes := make(map[string]map[string]any)
+	for i, ch := range chaps {
+		ss, to := ch.Stamps()
+		scenes[fmt.Sprintf("scene%03d", i+1)] = map[string]any{
+			"video": video,
+			"clip001": Clip{
+				Start: ss.FFmpeg(),
+				End:   to.FFmpeg(),
+			},
+		}
+	}
+	return yaml.Marshal(scenes)
+}
@@ -0,0 +1,81 @@
+package scene
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ohzqq/avtools/ff/fftest"
+)
+
+const showinfo = `Input #0, matroska,webm, from 'film.mkv':
+[Parsed_showinfo_1 @ 0x1] n:   0 pts:  62062 pts_time:62.062  duration: 1001
+[Parsed_showinfo_1 @ 0x1] n:   1 pts:   5005 pts_time:5.005   duration: 1001
+[Parsed_showinfo_1 @ 0x1] n:   2 pts:   5506 pts_time:5.506   duration: 1001
+frame=    3 fps=0.0 q=-0.0 size=N/A time=00:01:02.06
+`
+
+func TestDetect(t *testing.T) {
+	r := fftest.New().On("ffmpeg", fftest.Response{Stderr: showinfo})
+	cuts, err := DetectWith(context.Background(), r, "film.mkv", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantArgv := [][]string{{
+		"ffmpeg", "-hide_banner", "-nostats", "-i", "film.mkv", "-an",
+		"-vf", "select='gt(scene,0.4)',showinfo", "-f", "null", "-",
+	}}
+	if got := r.Argv(); !reflect.DeepEqual(got, wantArgv) {
+		t.Errorf("argv\n got %q\nwant %q", got, wantArgv)
+	}
+
+	want := []time.Duration{5005 * time.Millisecond, 5506 * time.Millisecond, 62062 * time.Millisecond}
+	if !reflect.DeepEqual(cuts, want) {
+		t.Errorf("got %v, want %v", cuts, want)
+	}
+}
+
+func TestDetectFails(t *testing.T) {
+	failed := errors.New("exit status 1")
+	r := fftest.New().On("ffmpeg", fftest.Response{Stderr: "no video", Err: failed})
+	_, err := DetectWith(context.Background(), r, "film.mkv", 0.3)
+	if !errors.Is(err, failed) || !strings.Contains(err.Error(), "no video") {
+		t.Errorf("got %v", err)
+	}
+	if got := r.Argv()[0][7]; got != "select='gt(scene,0.3)',showinfo" {
+		t.Errorf("filter %s", got)
+	}
+}
+
+func TestParseScdet(t *testing.T) {
+	cuts, err := Parse(strings.NewReader("[scdet @ 0x1] lavfi.scd.score: 45.2, lavfi.scd.time: 12.5\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cuts) != 1 || cuts[0] != 12500*time.Millisecond {
+		t.Errorf("got %v", cuts)
+	}
+}
+
+func TestChapters(t *testing.T) {
+	cuts := []time.Duration{5005 * time.Millisecond, 5506 * time.Millisecond, 62062 * time.Millisecond, 64500 * time.Millisecond}
+	chaps := Chapters(cuts, 65*time.Second, Options{})
+
+	// cuts less than a second after the last or before the end are dropped
+	want := []time.Duration{0, 5005 * time.Millisecond, 62062 * time.Millisecond, 65 * time.Second}
+	if len(chaps) != len(want)-1 {
+		t.Fatalf("got %d scenes, want %d", len(chaps), len(want)-1)
+	}
+	for i, ch := range chaps {
+		if ch.Start().Dur != want[i] || ch.End().Dur != want[i+1] {
+			t.Errorf("scene %d: got %s-%s, want %s-%s", i+1, ch.Start().Dur, ch.End().Dur, want[i], want[i+1])
+		}
+		if ch.Title() != "Scene "+string(rune('1'+i)) {
+			t.Errorf("scene %d title %q", i+1, ch.Title())
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package silence
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ohzqq/avtools"
+	"github.com/ohzqq/avtools/ff"
+)
+
+const (
+	// DefaultNoise is the level below which audio counts as silent.
+	DefaultNoise = "-30dB"
+	// DefaultSilence is how long a silence has to be to end a chapter.
+	DefaultSilence = 2 * time.Second
+	// Pause is the shortest silence detected. Pauses shorter than a
+	// chapter break are used to keep chapters under a maximum length or
+	// reach a chapter count.
+	Pause = 500 * time.Millisecond
+)
+
+var (
+	silenceStart = regexp.MustCompile(`silence_start: (-?[\d.]+)`)
+	silenceEnd   = regexp.MustCompile(`silence_end: (-?[\d.]+)`)
+)
+
+// Silence is a stretch of audio below the noise level.
+type Silence struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+func (s Silence) Len() time.Duration {
+	return s.End - s.Start
+}
+
+// Mid is the middle of the silence, where a chapter break goes.
+func (s Silence) Mid() time.Duration {
+	return s.Start + s.Len()/2
+}
+
+// Options are how chapters are made from silences. Lengths of 0 are
+// unlimited.
+type Options struct {
+	// Noise is the silencedetect noise level, in dB like -30dB or as an
+	// amplitude ratio.
+	Noise string
+	// Silence is the shortest silence that breaks chapters.
+	Silence time.Duration
+	// MinLength and MaxLength bound the length of chapters.
+	MinLength time.Duration
+	MaxLength time.Duration
+	// Count, when set, is the number of chapters wanted, breaking at the
+	// longest silences.
+	Count int
+}
+
+// Detect runs ffmpeg's silencedetect filter over the audio of input,
+// finding silences of at least d below noise.
+func Detect(input string, noise string, d time.Duration) ([]Silence, error) {
+	return DetectContext(context.Background(), input, noise, d)
+}
+
+// DetectContext is Detect, stopping ffmpeg when ctx is done.
+func DetectContext(ctx context.Context, input string, noise string, d time.Duration) ([]Silence, error) {
+	return DetectWith(ctx, nil, input, noise, d)
+}
+
+// DetectWith is DetectContext running ffmpeg with r, ff.DefaultRunner when
+// nil.
+func DetectWith(ctx context.Context, r ff.Runner, input string, noise string, d time.Duration) ([]Silence, error) {
+	if r == nil {
+		r = ff.DefaultRunner
+	}
+	if noise == "" {
+		noise = DefaultNoise
+	}
+	filter := fmt.Sprintf("silencedetect=noise=%s:d=%s", noise, strconv.FormatFloat(d.Seconds(), 'f', -1, 64))
+
+	var stderr bytes.Buffer
+	err := r.Run(ctx, ff.Call{
+		Name: "ffmpeg",
+		Args: []string{
+			"-hide_banner",
+			"-nostats",
+			"-i", input,
+			"-vn",
+			"-af", filter,
+			"-f", "null",
+			"-",
+		},
+		Stderr: &stderr,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("silencedetect %s: %w\n%s", input, err, stderr.String())
+	}
+
+	return Parse(&stderr)
+}
+
+// Parse reads silences from silencedetect's log. A silence still running
+// when the input ends is left out.
+func Parse(r io.Reader) ([]Silence, error) {
+	var (
+		silences []Silence
+		start    *time.Duration
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.Contains(line, "silencedetect") {
+			continue
+		}
+		if m := silenceStart.FindStringSubmatch(line); m != nil {
+			d, err := seconds(m[1])
+			if err != nil {
+				return nil, err
+			}
+			if d < 0 {
+				d = 0
+			}
+			start = &d
+			continue
+		}
+		if m := silenceEnd.FindStringSubmatch(line); m != nil && start != nil {
+			d, err := seconds(m[1])
+			if err != nil {
+				return nil, err
+			}
+			silences = append(silences, Silence{Start: *start, End: d})
+			start = nil
+		}
+	}
+
+	return silences, scanner.Err()
+}
+
+func seconds(s string) (time.Duration, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("silencedetect: %w %q", avtools.ErrInvalidTimestamp, s)
+	}
+	return time.Duration(f * float64(time.Second)).Round(time.Millisecond), nil
+}
+
+// Chapters breaks media of duration d into chapters at silences, see
+// Breaks, titled Chapter 1, Chapter 2 and so on.
+func Chapters(silences []Silence, d time.Duration, opts Options) avtools.Chapters {
+	var chaps avtools.Chapters
+
+	start := time.Duration(0)
+	for _, b := range append(Breaks(silences, d, opts), d) {
+		ch := &avtools.Chapter{
+			Tags: make(map[string]string),
+		}
+		ch.SetStamps(
+			avtools.StampOf(start).Rescale(avtools.Millisecond, avtools.RoundNearest),
+			avtools.StampOf(b).Rescale(avtools.Millisecond, avtools.RoundNearest),
+		)
+		chaps = append(chaps, ch)
+		start = b
+	}
+	chaps.Renumber(avtools.DefaultChapterTitle)
+
+	return chaps
+}
+
+// Breaks returns the times chapters of media of duration d should break
+// at, in the middle of silences. With a Count, the longest silences are
+// taken; otherwise every silence of at least opts.Silence. No chapter is
+// made shorter than MinLength, and chapters over MaxLength are broken at
+// their longest pause, as far as pauses allow.
+func Breaks(silences []Silence, d time.Duration, opts Options) []time.Duration {
+	if opts.Silence == 0 {
+		opts.Silence = DefaultSilence
+	}
+
+	var breaks []time.Duration
+	fits := func(at time.Duration) bool {
+		if at < opts.MinLength || d-at < opts.MinLength || at <= 0 || at >= d {
+			return false
+		}
+		for _, b := range breaks {
+			if abs(b-at) < opts.MinLength || b == at {
+				return false
+			}
+		}
+		return true
+	}
+
+	if opts.Count > 0 {
+		longest := append([]Silence(nil), silences...)
+		sort.SliceStable(longest, func(i, j int) bool {
+			return longest[i].Len() > longest[j].Len()
+		})
+		for _, s := range longest {
+			if len(breaks) >= opts.Count-1 {
+				break
+			}
+			if fits(s.Mid()) {
+				breaks = append(breaks, s.Mid())
+			}
+		}
+	} else {
+		for _, s := range silences {
+			if s.Len() >= opts.Silence && fits(s.Mid()) {
+				breaks = append(breaks, s.Mid())
+			}
+		}
+	}
+	sort.Slice(breaks, func(i, j int) bool { return breaks[i] < breaks[j] })
+
+	if opts.MaxLength > 0 {
+		breaks = splitLong(breaks, silences, d, opts)
+	}
+
+	return breaks
+}
+
+// splitLong breaks chapters longer than opts.MaxLength at their longest
+// pause that leaves both parts at least opts.MinLength long.
+func splitLong(breaks []time.Duration, silences []Silence, d time.Duration, opts Options) []time.Duration {
+	for {
+		bounds := append(append([]time.Duration{0}, breaks...), d)
+		split := false
+		for i := 1; i < len(bounds); i++ {
+			start, end := bounds[i-1], bounds[i]
+			if end-start <= opts.MaxLength {
+				continue
+			}
+			var best *Silence
+			for j, s := range silences {
+				at := s.Mid()
+				if at-start < opts.MinLength || end-at < opts.MinLength || at <= start || at >= end {
+					continue
+				}
+				if best == nil || s.Len() > best.Len() {
+					best = &silences[j]
+				}
+			}
+			if best == nil {
+				continue
+			}
+			breaks = append(breaks, best.Mid())
+			sort.Slice(breaks, func(i, j int) bool { return breaks[i] < breaks[j] })
+			split = true
+			break
+		}
+		if !split {
+			return breaks
+		}
+	}
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,94 @@
+package silence
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ohzqq/avtools/ff/fftest"
+)
+
+const detectLog = `Input #0, flac, from 'book.flac':
+[silencedetect @ 0x1] silence_start: -0.01
+[silencedetect @ 0x1] silence_end: 0.5 | silence_duration: 0.51
+size=N/A time=00:00:30.00 bitrate=N/A speed= 900x
+[silencedetect @ 0x1] silence_start: 9.5
+[silencedetect @ 0x1] silence_end: 12.5 | silence_duration: 3
+[silencedetect @ 0x1] silence_start: 19
+[silencedetect @ 0x1] silence_end: 20 | silence_duration: 1
+[silencedetect @ 0x1] silence_start: 29.5
+`
+
+func TestDetect(t *testing.T) {
+	r := fftest.New().On("ffmpeg", fftest.Response{Stderr: detectLog})
+	silences, err := DetectWith(context.Background(), r, "book.flac", "", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantArgv := [][]string{{
+		"ffmpeg", "-hide_banner", "-nostats", "-i", "book.flac", "-vn",
+		"-af", "silencedetect=noise=-30dB:d=1", "-f", "null", "-",
+	}}
+	if got := r.Argv(); !reflect.DeepEqual(got, wantArgv) {
+		t.Errorf("argv\n got %q\nwant %q", got, wantArgv)
+	}
+
+	// the silence running at the end is left out
+	want := []Silence{
+		{0, 500 * time.Millisecond},
+		{9500 * time.Millisecond, 12500 * time.Millisecond},
+		{19 * time.Second, 20 * time.Second},
+	}
+	if !reflect.DeepEqual(silences, want) {
+		t.Errorf("got %v, want %v", silences, want)
+	}
+}
+
+func TestDetectFails(t *testing.T) {
+	failed := errors.New("exit status 1")
+	r := fftest.New().On("ffmpeg", fftest.Response{Stderr: "no such file", Err: failed})
+	_, err := DetectWith(context.Background(), r, "book.flac", "-40dB", time.Second)
+	if !errors.Is(err, failed) || !strings.Contains(err.Error(), "no such file") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestChapters(t *testing.T) {
+	silences := []Silence{
+		{9500 * time.Millisecond, 12500 * time.Millisecond},
+		{19 * time.Second, 20 * time.Second},
+		{40 * time.Second, 40600 * time.Millisecond},
+	}
+	for _, tt := range []struct {
+		name string
+		opts Options
+		want []time.Duration
+	}{
+		{"silence", Options{Silence: time.Second}, []time.Duration{11 * time.Second, 19500 * time.Millisecond}},
+		{"min length", Options{Silence: time.Second, MinLength: 10 * time.Second}, []time.Duration{11 * time.Second}},
+		{"count", Options{Count: 2}, []time.Duration{11 * time.Second}},
+		{"max length", Options{Silence: 2 * time.Second, MaxLength: 30 * time.Second}, []time.Duration{11 * time.Second, 19500 * time.Millisecond, 40300 * time.Millisecond}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			chaps := Chapters(silences, time.Minute, tt.opts)
+			if len(chaps) != len(tt.want)+1 {
+				t.Fatalf("got %d chapters, want %d", len(chaps), len(tt.want)+1)
+			}
+			for i, b := range tt.want {
+				if chaps[i].End().Dur != b || chaps[i+1].Start().Dur != b {
+					t.Errorf("break %d: got %s, want %s", i+1, chaps[i].End().Dur, b)
+				}
+			}
+			if chaps[0].Start().Dur != 0 || chaps[len(chaps)-1].End().Dur != time.Minute {
+				t.Errorf("chapters don't cover the media: %s-%s", chaps[0].Start().Dur, chaps[len(chaps)-1].End().Dur)
+			}
+			if chaps[0].Title() != "Chapter 1" {
+				t.Errorf("title %q", chaps[0].Title())
+			}
+		})
+	}
+}
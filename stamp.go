@@ -0,0 +1,269 @@
+package avtools
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timebase is the length of a tick in seconds, as the fraction Num/Den.
+type Timebase struct {
+	Num int64
+	Den int64
+}
+
+var (
+	Nanosecond  = Timebase{1, int64(time.Second)}
+	Microsecond = Timebase{1, 1000000}
+	Millisecond = Timebase{1, 1000}
+	// CDFrame is the 1/75 second frame of cue sheets.
+	CDFrame = Timebase{1, 75}
+)
+
+// ParseTimebase parses a num/den timebase, or a bare den meaning 1/den.
+func ParseTimebase(b string) (Timebase, error) {
+	num, den := "1", b
+	if n, d, ok := strings.Cut(b, "/"); ok {
+		num, den = n, d
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(num), 10, 64)
+	if err != nil {
+		return Timebase{}, fmt.Errorf("%w: timebase %q", ErrInvalidTimestamp, b)
+	}
+	d, err := strconv.ParseInt(strings.TrimSpace(den), 10, 64)
+	if err != nil {
+		return Timebase{}, fmt.Errorf("%w: timebase %q", ErrInvalidTimestamp, b)
+	}
+	tb := Timebase{n, d}
+	if !tb.Valid() {
+		return Timebase{}, fmt.Errorf("%w: timebase %q", ErrInvalidTimestamp, b)
+	}
+	return tb, nil
+}
+
+func (b Timebase) Valid() bool {
+	return b.Num > 0 && b.Den > 0
+}
+
+func (b Timebase) String() string {
+	return fmt.Sprintf("%d/%d", b.Num, b.Den)
+}
+
+// Rounding is how a Stamp is rounded when it can't be converted exactly.
+type Rounding int
+
+const (
+	// RoundNearest rounds halves away from zero.
+	RoundNearest Rounding = iota
+	// RoundDown rounds toward negative infinity.
+	RoundDown
+	// RoundUp rounds toward positive infinity.
+	RoundUp
+)
+
+// Stamp is a timestamp counted in ticks of a timebase, so times read from
+// ffmetadata, cue sheets or ffprobe convert between timebases exactly. The
+// zero Stamp has no timebase and is not Valid.
+type Stamp struct {
+	Ticks int64
+	Base  Timebase
+}
+
+func NewStamp(ticks int64, base Timebase) Stamp {
+	return Stamp{Ticks: ticks, Base: base}
+}
+
+// StampOf returns d as a Stamp in nanoseconds.
+func StampOf(d time.Duration) Stamp {
+	return Stamp{Ticks: int64(d), Base: Nanosecond}
+}
+
+// ParseTicks parses a tick count t in the timebase b, like the START and
+// TIMEBASE of an ffmetadata chapter.
+func ParseTicks(t, b string) (Stamp, error) {
+	base, err := ParseTimebase(b)
+	if err != nil {
+		return Stamp{}, err
+	}
+	ticks, err := strconv.ParseInt(strings.TrimSpace(t), 10, 64)
+	if err != nil {
+		return Stamp{}, fmt.Errorf("%w %q", ErrInvalidTimestamp, t)
+	}
+	return NewStamp(ticks, base), nil
+}
+
+func (s Stamp) Valid() bool {
+	return s.Base.Valid()
+}
+
+func (s Stamp) IsZero() bool {
+	return s.Ticks == 0
+}
+
+// rat is the stamp in seconds, an invalid stamp being 0.
+func (s Stamp) rat() *big.Rat {
+	if !s.Valid() {
+		return new(big.Rat)
+	}
+	r := new(big.Rat).SetFrac(
+		new(big.Int).Mul(big.NewInt(s.Ticks), big.NewInt(s.Base.Num)),
+		big.NewInt(s.Base.Den),
+	)
+	return r
+}
+
+// Rescale converts s to the timebase b, rounding when the stamp falls
+// between two ticks of b.
+func (s Stamp) Rescale(b Timebase, r Rounding) Stamp {
+	if s.Base == b {
+		return s
+	}
+	ticks := new(big.Rat).Mul(s.rat(), new(big.Rat).SetFrac64(b.Den, b.Num))
+	return Stamp{Ticks: round(ticks, r), Base: b}
+}
+
+// Exact reports whether s can be expressed in the timebase b without
+// rounding.
+func (s Stamp) Exact(b Timebase) bool {
+	ticks := new(big.Rat).Mul(s.rat(), new(big.Rat).SetFrac64(b.Den, b.Num))
+	return ticks.IsInt()
+}
+
+func round(r *big.Rat, mode Rounding) int64 {
+	q, m := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	if m.Sign() == 0 {
+		return q.Int64()
+	}
+	// QuoRem truncates toward zero, so q is one off for negative floors
+	// and positive ceilings.
+	switch mode {
+	case RoundDown:
+		if r.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		}
+	case RoundUp:
+		if r.Sign() > 0 {
+			q.Add(q, big.NewInt(1))
+		}
+	default:
+		twice := new(big.Int).Mul(new(big.Int).Abs(m), big.NewInt(2))
+		if twice.Cmp(r.Denom()) >= 0 {
+			q.Add(q, big.NewInt(int64(r.Sign())))
+		}
+	}
+	return q.Int64()
+}
+
+// common is the coarsest timebase both a and b convert to exactly.
+func common(a, b Timebase) Timebase {
+	num := new(big.Int).GCD(nil, nil, big.NewInt(a.Num), big.NewInt(b.Num))
+	gcd := new(big.Int).GCD(nil, nil, big.NewInt(a.Den), big.NewInt(b.Den))
+	lcm := new(big.Int).Mul(big.NewInt(a.Den), big.NewInt(b.Den))
+	lcm.Quo(lcm, gcd)
+	return Timebase{Num: num.Int64(), Den: lcm.Int64()}
+}
+
+// Add returns s+o. Stamps in different timebases are added in a timebase
+// both convert to exactly.
+func (s Stamp) Add(o Stamp) Stamp {
+	if !o.Valid() {
+		return s
+	}
+	if !s.Valid() {
+		return o
+	}
+	if s.Base == o.Base {
+		return Stamp{Ticks: s.Ticks + o.Ticks, Base: s.Base}
+	}
+	b := common(s.Base, o.Base)
+	a, c := s.Rescale(b, RoundNearest), o.Rescale(b, RoundNearest)
+	return Stamp{Ticks: a.Ticks + c.Ticks, Base: b}
+}
+
+// Sub returns s-o, like Add.
+func (s Stamp) Sub(o Stamp) Stamp {
+	o.Ticks = -o.Ticks
+	return s.Add(o)
+}
+
+// Scale multiplies s by num/den, keeping its timebase.
+func (s Stamp) Scale(num, den int64, r Rounding) Stamp {
+	ticks := new(big.Rat).Mul(
+		new(big.Rat).SetInt64(s.Ticks),
+		new(big.Rat).SetFrac64(num, den),
+	)
+	return Stamp{Ticks: round(ticks, r), Base: s.Base}
+}
+
+// Cmp compares s and o as -1, 0 or +1, whatever their timebases.
+func (s Stamp) Cmp(o Stamp) int {
+	return s.rat().Cmp(o.rat())
+}
+
+// Duration is s rounded to the nearest nanosecond.
+func (s Stamp) Duration() time.Duration {
+	if !s.Valid() {
+		return 0
+	}
+	return time.Duration(s.Rescale(Nanosecond, RoundNearest).Ticks)
+}
+
+// Seconds is s in fractional seconds.
+func (s Stamp) Seconds() float64 {
+	if !s.Valid() {
+		return 0
+	}
+	f, _ := s.rat().Float64()
+	return f
+}
+
+// clock splits s, rounded to the timebase b, into its sign and hours,
+// minutes, seconds and remaining ticks.
+func (s Stamp) clock(b Timebase) (sign string, hh, mm, ss, frac int64) {
+	t := s.Rescale(b, RoundNearest).Ticks
+	if t < 0 {
+		sign = "-"
+		t = -t
+	}
+	perSec := b.Den / b.Num
+	frac = t % perSec
+	secs := t / perSec
+	return sign, secs / 3600, secs % 3600 / 60, secs % 60, frac
+}
+
+// FFmpeg formats s as HH:MM:SS.mmmmmm, to the microsecond ffmpeg counts
+// in.
+func (s Stamp) FFmpeg() string {
+	sign, hh, mm, ss, us := s.clock(Microsecond)
+	return fmt.Sprintf("%s%02d:%02d:%02d.%06d", sign, hh, mm, ss, us)
+}
+
+// Cue formats s as the MM:SS:FF of cue sheets, minutes not wrapping at an
+// hour.
+func (s Stamp) Cue() string {
+	sign, hh, mm, ss, ff := s.clock(CDFrame)
+	return fmt.Sprintf("%s%02d:%02d:%02d", sign, hh*60+mm, ss, ff)
+}
+
+// VTT formats s as the HH:MM:SS.mmm of WebVTT.
+func (s Stamp) VTT() string {
+	sign, hh, mm, ss, ms := s.clock(Millisecond)
+	return fmt.Sprintf("%s%02d:%02d:%02d.%03d", sign, hh, mm, ss, ms)
+}
+
+// SRT formats s as the HH:MM:SS,mmm of SubRip.
+func (s Stamp) SRT() string {
+	sign, hh, mm, ss, ms := s.clock(Millisecond)
+	return fmt.Sprintf("%s%02d:%02d:%02d,%03d", sign, hh, mm, ss, ms)
+}
+
+// MS formats s in whole milliseconds.
+func (s Stamp) MS() string {
+	return strconv.FormatInt(s.Rescale(Millisecond, RoundNearest).Ticks, 10)
+}
+
+func (s Stamp) String() string {
+	return s.VTT()
+}
@@ -0,0 +1,67 @@
+package avtools
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStampRescale(t *testing.T) {
+	// a 44.1kHz sample count through milliseconds and back isn't exact, but
+	// through the common timebase it is
+	s := NewStamp(220501, Timebase{1, 44100})
+	if s.Exact(Millisecond) {
+		t.Errorf("%s is exact in ms", s)
+	}
+	back := s.Rescale(Timebase{1, 44100 * 1000}, RoundNearest).Rescale(s.Base, RoundNearest)
+	if back != s {
+		t.Errorf("round trip: got %v, want %v", back, s)
+	}
+
+	for _, tt := range []struct {
+		r    Rounding
+		want int64
+	}{
+		{RoundNearest, 5000},
+		{RoundDown, 5000},
+		{RoundUp, 5001},
+	} {
+		got := s.Rescale(Millisecond, tt.r).Ticks
+		if got != tt.want {
+			t.Errorf("rounding %d: got %d, want %d", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestStampArithmetic(t *testing.T) {
+	a := NewStamp(1, CDFrame)
+	b := NewStamp(1, Millisecond)
+	sum := a.Add(b)
+	if got := sum.Sub(b); got.Cmp(a) != 0 {
+		t.Errorf("(a+b)-b = %v, want %v", got, a)
+	}
+
+	// 3000 frames of 1/75 are 40s exactly, whatever the sum went through
+	total := NewStamp(0, CDFrame)
+	for i := 0; i < 3000; i++ {
+		total = total.Add(a)
+	}
+	if total.Duration() != 40*time.Second {
+		t.Errorf("3000 frames: got %s, want 40s", total.Duration())
+	}
+}
+
+func TestParseTicks(t *testing.T) {
+	s, err := ParseTicks("441000", "1/44100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Duration() != 10*time.Second || s.FFmpeg() != "00:00:10.000000" {
+		t.Errorf("got %s, %s", s.Duration(), s.FFmpeg())
+	}
+
+	for _, bad := range [][2]string{{"1", "0/1"}, {"x", "1/1000"}, {"1", "1/x"}} {
+		if _, err := ParseTicks(bad[0], bad[1]); err == nil {
+			t.Errorf("ParseTicks(%q, %q): no error", bad[0], bad[1])
+		}
+	}
+}
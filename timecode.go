@@ -0,0 +1,213 @@
+package avtools
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Rate is a frame rate of Num/Den frames per second.
+type Rate struct {
+	Num int64
+	Den int64
+}
+
+var (
+	// NTSC is 29.97 fps, counted in drop-frame timecode.
+	NTSC = Rate{30000, 1001}
+	// NTSC60 is 59.94 fps, counted in drop-frame timecode.
+	NTSC60 = Rate{60000, 1001}
+)
+
+// ParseRate parses a num/den frame rate, like ffprobe's r_frame_rate, or a
+// decimal one. The NTSC rates 23.976, 29.97, 47.952, 59.94 and 119.88 are
+// taken as their exact n*1000/1001.
+func ParseRate(r string) (Rate, error) {
+	if n, d, ok := strings.Cut(r, "/"); ok {
+		num, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+		if err != nil {
+			return Rate{}, fmt.Errorf("%w: frame rate %q", ErrInvalidTimestamp, r)
+		}
+		den, err := strconv.ParseInt(strings.TrimSpace(d), 10, 64)
+		if err != nil {
+			return Rate{}, fmt.Errorf("%w: frame rate %q", ErrInvalidTimestamp, r)
+		}
+		rate := Rate{num, den}
+		if !rate.Valid() {
+			return Rate{}, fmt.Errorf("%w: frame rate %q", ErrInvalidTimestamp, r)
+		}
+		return rate, nil
+	}
+
+	fps, err := strconv.ParseFloat(strings.TrimSpace(r), 64)
+	if err != nil || fps <= 0 {
+		return Rate{}, fmt.Errorf("%w: frame rate %q", ErrInvalidTimestamp, r)
+	}
+	if fps == math.Trunc(fps) {
+		return Rate{int64(fps), 1}, nil
+	}
+	nominal := math.Round(fps)
+	if math.Abs(fps-nominal*1000/1001) < 0.005 {
+		return Rate{int64(nominal) * 1000, 1001}, nil
+	}
+	num, den := int64(math.Round(fps*1000)), int64(1000)
+	g := gcd(num, den)
+	return Rate{num / g, den / g}, nil
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func (r Rate) Valid() bool {
+	return r.Num > 0 && r.Den > 0
+}
+
+func (r Rate) String() string {
+	return fmt.Sprintf("%d/%d", r.Num, r.Den)
+}
+
+// Frame is the timebase of one frame.
+func (r Rate) Frame() Timebase {
+	return Timebase{Num: r.Den, Den: r.Num}
+}
+
+// Nominal is the number of frames counted in a second of timecode, 30 for
+// 29.97 fps.
+func (r Rate) Nominal() int64 {
+	return int64(math.Round(float64(r.Num) / float64(r.Den)))
+}
+
+// DropFrame reports whether drop-frame timecode is defined for r, i.e. r is
+// 29.97 or 59.94 fps.
+func (r Rate) DropFrame() bool {
+	return r.Den == 1001 && r.Num%30000 == 0 && r.Num <= 60000
+}
+
+// dropped is the number of frame numbers skipped at the start of each
+// minute but every tenth.
+func (r Rate) dropped() int64 {
+	return r.Nominal() / 15
+}
+
+// StreamRate returns the frame rate of the first video stream with one, as
+// probed by ffprobe.
+func StreamRate(streams []map[string]string) (Rate, bool) {
+	for _, s := range streams {
+		if s["codec_type"] != "video" || s["cover"] == "true" {
+			continue
+		}
+		if r, err := ParseRate(s["r_frame_rate"]); err == nil {
+			return r, true
+		}
+	}
+	return Rate{}, false
+}
+
+// timecode matches what ParseTimecode reads. A . is never taken before the
+// frames, as HH:MM:SS.FF can't be told apart from fractions of seconds.
+var timecode = regexp.MustCompile(`^-?\d+:\d+:\d+[:;]\d+$`)
+
+// IsTimecode reports whether t looks like an SMPTE timecode, HH:MM:SS:FF, or
+// HH:MM:SS;FF for drop-frame.
+func IsTimecode(t string) bool {
+	return timecode.MatchString(t)
+}
+
+// ParseTimecode parses an SMPTE timecode at the frame rate r. A ; before the
+// frames marks drop-frame timecode, which is only defined at 29.97 and
+// 59.94 fps.
+func ParseTimecode(tc string, r Rate) (Stamp, error) {
+	invalid := fmt.Errorf("%w: timecode %q", ErrInvalidTimestamp, tc)
+
+	sep := strings.LastIndexAny(tc, ":;")
+	if sep < 0 {
+		return Stamp{}, invalid
+	}
+	drop := tc[sep] != ':'
+
+	split := strings.Split(tc[:sep], ":")
+	if len(split) != 3 {
+		return Stamp{}, invalid
+	}
+	split = append(split, tc[sep+1:])
+
+	sign := int64(1)
+	if strings.HasPrefix(split[0], "-") {
+		sign = -1
+		split[0] = split[0][1:]
+	}
+
+	var n [4]int64
+	for i, s := range split {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || v < 0 {
+			return Stamp{}, invalid
+		}
+		n[i] = v
+	}
+	hh, mm, ss, ff := n[0], n[1], n[2], n[3]
+
+	if !r.Valid() {
+		return Stamp{}, fmt.Errorf("%w: timecode %q needs a frame rate", ErrInvalidTimestamp, tc)
+	}
+	if drop && !r.DropFrame() {
+		return Stamp{}, fmt.Errorf("%w: no drop-frame timecode at %s fps", ErrInvalidTimestamp, r)
+	}
+
+	nominal := r.Nominal()
+	if mm > 59 || ss > 59 || ff >= nominal {
+		return Stamp{}, invalid
+	}
+
+	frames := ((hh*60+mm)*60+ss)*nominal + ff
+	if drop {
+		d := r.dropped()
+		if ss == 0 && mm%10 != 0 && ff < d {
+			return Stamp{}, fmt.Errorf("%w: timecode %q is a dropped frame", ErrInvalidTimestamp, tc)
+		}
+		minutes := hh*60 + mm
+		frames -= d * (minutes - minutes/10)
+	}
+
+	return NewStamp(sign*frames, r.Frame()), nil
+}
+
+// Timecode formats s as an SMPTE timecode at the frame rate r, rounded to the
+// nearest frame. Drop-frame timecode is written with a ; before the frames,
+// and only when r allows it.
+func (s Stamp) Timecode(r Rate, drop bool) string {
+	if !r.Valid() {
+		return ""
+	}
+	frames := s.Rescale(r.Frame(), RoundNearest).Ticks
+
+	var sign string
+	if frames < 0 {
+		sign = "-"
+		frames = -frames
+	}
+
+	nominal := r.Nominal()
+	sep := ":"
+	if drop && r.DropFrame() {
+		sep = ";"
+		d := r.dropped()
+		perMin := nominal*60 - d
+		per10Min := perMin*10 + d
+		tens, rem := frames/per10Min, frames%per10Min
+		frames += 9 * d * tens
+		if rem > d {
+			frames += d * ((rem - d) / perMin)
+		}
+	}
+
+	ff := frames % nominal
+	secs := frames / nominal
+	return fmt.Sprintf("%s%02d:%02d:%02d%s%02d", sign, secs/3600, secs/60%60, secs%60, sep, ff)
+}
@@ -0,0 +1,93 @@
+package avtools
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDropFrameTimecode(t *testing.T) {
+	for _, tt := range []struct {
+		tc     string
+		r      Rate
+		frames int64
+	}{
+		{"00:00:00;00", NTSC, 0},
+		{"00:00:59;29", NTSC, 1799},
+		{"00:01:00;02", NTSC, 1800},
+		{"00:10:00;00", NTSC, 17982},
+		{"01:00:00;00", NTSC, 107892},
+		{"00:01:00;04", NTSC60, 3600},
+	} {
+		s, err := ParseTimecode(tt.tc, tt.r)
+		if err != nil {
+			t.Errorf("%s: %v", tt.tc, err)
+			continue
+		}
+		if s.Ticks != tt.frames || s.Base != tt.r.Frame() {
+			t.Errorf("%s: got %v, want %d frames", tt.tc, s, tt.frames)
+		}
+		if got := s.Timecode(tt.r, true); got != tt.tc {
+			t.Errorf("%d frames: got %s, want %s", tt.frames, got, tt.tc)
+		}
+	}
+}
+
+func TestTimecodeSeparators(t *testing.T) {
+	for _, tc := range []string{"00:01:00:02", "00:01:00;02", "-00:00:01:00"} {
+		if !IsTimecode(tc) {
+			t.Errorf("%s isn't a timecode", tc)
+		}
+	}
+	// fractions of seconds aren't frames
+	for _, tc := range []string{"00:01:00.02", "00:01:00.5", "00:01:00.250", "01:00"} {
+		if IsTimecode(tc) {
+			t.Errorf("%s is a timecode", tc)
+		}
+	}
+	if _, err := ParseTimecode("00:01:00.02", NTSC); err == nil {
+		t.Error("read 00:01:00.02 as a timecode")
+	}
+}
+
+func TestParseStampDecimal(t *testing.T) {
+	for _, tt := range []struct {
+		ss   string
+		rate []Rate
+		want time.Duration
+	}{
+		{"00:00:05.50", nil, 5500 * time.Millisecond},
+		{"00:10:00.25", []Rate{{25, 1}}, 10*time.Minute + 250*time.Millisecond},
+		{"01:02:03.25", []Rate{NTSC}, time.Hour + 2*time.Minute + 3250*time.Millisecond},
+	} {
+		got, err := ParseStamp(tt.ss, tt.rate...)
+		if err != nil {
+			t.Errorf("%s: %v", tt.ss, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.ss, got, tt.want)
+		}
+	}
+}
+
+func TestTimecodeErrors(t *testing.T) {
+	for _, tt := range []struct {
+		tc string
+		r  Rate
+	}{
+		// dropped frame numbers
+		{"00:01:00;00", NTSC},
+		{"00:01:00;01", NTSC},
+		// no drop-frame at 25 fps
+		{"00:00:01;00", Rate{25, 1}},
+		// no guessed rate
+		{"00:00:01:00", Rate{}},
+		{"00:00:01:30", NTSC},
+	} {
+		_, err := ParseTimecode(tt.tc, tt.r)
+		if !errors.Is(err, ErrInvalidTimestamp) {
+			t.Errorf("%s at %s: got %v", tt.tc, tt.r, err)
+		}
+	}
+}
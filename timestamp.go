@@ -2,13 +2,11 @@ package avtools
 
 import (
 	"fmt"
-	"log"
 	"math"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/samber/lo"
 	"golang.org/x/exp/constraints"
 )
 
@@ -24,51 +22,48 @@ type Time struct {
 }
 
 func Timestamp(d time.Duration) Time {
-	stamp := Time{Dur: d}
-	dur := d.String()
-
-	var t []string
-	if strings.Contains(dur, "ms") {
-		stamp.ss = d.Seconds()
-	} else {
-		t = strings.SplitAfter(dur, "m")
-		ss := strings.TrimSuffix(t[len(t)-1], "s")
-		stamp.ss = StringToFloat(ss)
+	return Time{
+		hh:  int(d / time.Hour),
+		mm:  int(d % time.Hour / time.Minute),
+		ss:  (d % time.Minute).Seconds(),
+		Dur: d,
 	}
-
-	t = lo.DropRight(t, 1)
-
-	if len(t) > 0 {
-		t = strings.SplitAfter(t[0], "h")
-		mm := strings.TrimSuffix(t[len(t)-1], "m")
-		stamp.mm, _ = strconv.Atoi(mm)
-		t = lo.DropRight(t, 1)
-
-		if len(t) > 0 {
-			hh := strings.TrimSuffix(t[len(t)-1], "h")
-			stamp.hh, _ = strconv.Atoi(hh)
-		}
-	}
-
-	return stamp
 }
 
-func ParseString(t string) Time {
-	return Timestamp(ParseStamp(t))
+func ParseString(t string) (Time, error) {
+	d, err := ParseStamp(t)
+	if err != nil {
+		return Time{}, err
+	}
+	return Timestamp(d), nil
 }
 
-func StringToFloat(t string) float64 {
+func StringToFloat(t string) (float64, error) {
 	if t == "" {
 		t = "0"
 	}
 	i, err := strconv.ParseFloat(t, 64)
 	if err != nil {
-		log.Fatal(err)
+		return 0, fmt.Errorf("%w %q", ErrInvalidTimestamp, t)
 	}
-	return i
+	return i, nil
 }
 
-func ParseStamp(t string) time.Duration {
+// ParseStamp parses [[hh:]mm:]ss[.fff], or an SMPTE timecode at the
+// optional frame rate, see ParseTimecode.
+func ParseStamp(t string, rate ...Rate) (time.Duration, error) {
+	if IsTimecode(t) {
+		var r Rate
+		if len(rate) > 0 {
+			r = rate[0]
+		}
+		tc, err := ParseTimecode(t, r)
+		if err != nil {
+			return 0, err
+		}
+		return tc.Duration(), nil
+	}
+
 	var hh string
 	var mm string
 	var ss string
@@ -82,43 +77,38 @@ func ParseStamp(t string) time.Duration {
 		ss = split[1] + "s"
 	case 1:
 		ss = split[0] + "s"
+	default:
+		return 0, fmt.Errorf("%w %q", ErrInvalidTimestamp, t)
 	}
 	stamp := fmt.Sprintf("%s%s%s", hh, mm, ss)
 
-	return ParseDuration(stamp)
-}
-
-func ParseTimeAndBase(t, b string) time.Duration {
-	base, err := strconv.Atoi(strings.TrimPrefix(b, "1/"))
+	d, err := time.ParseDuration(stamp)
 	if err != nil {
-		log.Fatal(err)
+		return 0, fmt.Errorf("%w %q", ErrInvalidTimestamp, t)
 	}
+	return d, nil
+}
 
-	dur, err := strconv.Atoi(t)
+// ParseTimeAndBase parses a tick count in the timebase b, see ParseTicks.
+func ParseTimeAndBase(t, b string) (time.Duration, error) {
+	stamp, err := ParseTicks(t, b)
 	if err != nil {
-		log.Fatal(err)
+		return 0, err
 	}
-
-	secs := dur / base
-	ms := secs * 1000
-	d := strconv.Itoa(ms) + "ms"
-
-	return ParseDuration(d)
+	return stamp.Duration(), nil
 }
 
 func ParseStampDuration[N Number](t, b N) time.Duration {
 	secs := float64(t) / float64(b)
-	ms := secs * 1000
-	d := strconv.Itoa(int(ms)) + "ms"
-	return ParseDuration(d)
+	return time.Duration(secs * float64(time.Second)).Round(time.Millisecond)
 }
 
-func ParseDuration(d string) time.Duration {
+func ParseDuration(d string) (time.Duration, error) {
 	dur, err := time.ParseDuration(d)
 	if err != nil {
-		log.Fatal(err)
+		return 0, fmt.Errorf("%w %q", ErrInvalidTimestamp, d)
 	}
-	return dur
+	return dur, nil
 }
 
 func ParseNumber[N Number](num N, dig int) string {
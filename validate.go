@@ -0,0 +1,225 @@
+package avtools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Severity int
+
+const (
+	// Warning is for chapters that play fine but aren't tidy, like gaps.
+	Warning Severity = iota
+	// Error is for chapters players and ffmpeg get wrong.
+	Error
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// ProblemKind is what is wrong with a chapter.
+type ProblemKind string
+
+const (
+	NegativeStart ProblemKind = "negative start"
+	Unsorted      ProblemKind = "unsorted"
+	Overlap       ProblemKind = "overlap"
+	Gap           ProblemKind = "gap"
+	ZeroLength    ProblemKind = "zero length"
+	OpenEnd       ProblemKind = "no end"
+	PastEnd       ProblemKind = "past end"
+)
+
+// Problem is something wrong with the chapter at index Chapter.
+type Problem struct {
+	Chapter  int
+	Kind     ProblemKind
+	Severity Severity
+	Msg      string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: chapter %d: %s: %s", p.Severity, p.Chapter+1, p.Kind, p.Msg)
+}
+
+// Report lists the problems of a chapter list, in chapter order.
+type Report []Problem
+
+// HasErrors reports whether any problem is an Error.
+func (r Report) HasErrors() bool {
+	for _, p := range r {
+		if p.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors returns the problems that are errors.
+func (r Report) Errors() Report {
+	var errs Report
+	for _, p := range r {
+		if p.Severity == Error {
+			errs = append(errs, p)
+		}
+	}
+	return errs
+}
+
+func (r Report) String() string {
+	lines := make([]string, len(r))
+	for i, p := range r {
+		lines[i] = p.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Err returns the errors of the report as an ErrInvalidChapters, or nil
+// when there are none.
+func (r Report) Err() error {
+	errs := r.Errors()
+	if len(errs) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(errs))
+	for i, p := range errs {
+		msgs[i] = fmt.Sprintf("chapter %d: %s: %s", p.Chapter+1, p.Kind, p.Msg)
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidChapters, strings.Join(msgs, "; "))
+}
+
+// Validate checks the chapters for gaps, overlaps, zero length and unsorted
+// chapters, and against the media duration d unless it is 0.
+func (c Chapters) Validate(d time.Duration) Report {
+	var r Report
+	add := func(i int, kind ProblemKind, sev Severity, format string, args ...any) {
+		r = append(r, Problem{
+			Chapter:  i,
+			Kind:     kind,
+			Severity: sev,
+			Msg:      fmt.Sprintf(format, args...),
+		})
+	}
+	dur := StampOf(d)
+	last := len(c) - 1
+
+	for i, ch := range c {
+		ss, to := ch.Stamps()
+
+		if ss.Ticks < 0 {
+			add(i, NegativeStart, Error, "starts at %s", ss)
+		}
+		if i == 0 && ss.Ticks > 0 {
+			add(i, Gap, Warning, "starts at %s, not 0", ss)
+		}
+		if i > 0 {
+			prev, _ := c[i-1].Stamps()
+			if ss.Cmp(prev) < 0 {
+				add(i, Unsorted, Error, "starts at %s, before chapter %d at %s", ss, i, prev)
+			}
+		}
+
+		if i < last {
+			next, _ := c[i+1].Stamps()
+			switch {
+			case ss.Cmp(next) == 0:
+				add(i, ZeroLength, Error, "starts at %s like chapter %d", ss, i+2)
+			case to.IsZero():
+				add(i, OpenEnd, Warning, "ends where chapter %d starts", i+2)
+			case to.Cmp(ss) <= 0:
+				add(i, ZeroLength, Error, "ends at %s, not after its start %s", to, ss)
+			case to.Cmp(next) > 0:
+				add(i, Overlap, Error, "ends at %s, after chapter %d starts at %s", to, i+2, next)
+			case to.Cmp(next) < 0:
+				add(i, Gap, Warning, "ends at %s, before chapter %d starts at %s", to, i+2, next)
+			}
+			continue
+		}
+
+		open := ch.open()
+		switch {
+		case d > 0 && ss.Cmp(dur) >= 0:
+			add(i, PastEnd, Error, "starts at %s, after the media ends at %s", ss, dur)
+		case open && !to.IsZero():
+			add(i, ZeroLength, Error, "ends at %s, not after its start %s", to, ss)
+		case open && d > 0:
+			add(i, OpenEnd, Warning, "ends with the media at %s", dur)
+		case open:
+			add(i, OpenEnd, Warning, "has no end")
+		case d > 0 && to.Cmp(dur) > 0:
+			add(i, PastEnd, Error, "ends at %s, after the media ends at %s", to, dur)
+		case d > 0 && to.Cmp(dur) < 0:
+			add(i, Gap, Warning, "ends at %s, before the media ends at %s", to, dur)
+		}
+	}
+
+	return r
+}
+
+// Repair fixes the problems Validate finds and returns them: starts before
+// zero become zero, chapters are sorted by start, chapters starting after
+// the media or at the same time as the next one are dropped, the first
+// chapter starts at zero and each chapter ends where the next starts, the
+// last one at the duration d unless it is 0.
+func (c *Chapters) Repair(d time.Duration) Report {
+	r := c.Validate(d)
+	if len(r) == 0 {
+		return r
+	}
+
+	chaps := append(Chapters(nil), *c...)
+	dur := StampOf(d)
+
+	for _, ch := range chaps {
+		ss, to := ch.Stamps()
+		if ss.Ticks < 0 {
+			ch.SetStamps(NewStamp(0, ss.Base), to)
+		}
+	}
+
+	sort.SliceStable(chaps, func(i, j int) bool {
+		a, _ := chaps[i].Stamps()
+		b, _ := chaps[j].Stamps()
+		return a.Cmp(b) < 0
+	})
+
+	var kept Chapters
+	for i, ch := range chaps {
+		ss, _ := ch.Stamps()
+		if d > 0 && ss.Cmp(dur) >= 0 {
+			continue
+		}
+		if i+1 < len(chaps) {
+			next, _ := chaps[i+1].Stamps()
+			if ss.Cmp(next) == 0 {
+				continue
+			}
+		}
+		kept = append(kept, ch)
+	}
+
+	for i, ch := range kept {
+		ss, to := ch.Stamps()
+		if i == 0 {
+			ss = NewStamp(0, ss.Base)
+		}
+		switch {
+		case i+1 < len(kept):
+			to, _ = kept[i+1].Stamps()
+		case d > 0:
+			to = dur.Rescale(ss.Base, RoundNearest)
+		case to.Cmp(ss) <= 0:
+			to = NewStamp(0, ss.Base)
+		}
+		ch.SetStamps(ss, to)
+	}
+
+	*c = kept
+	return r
+}
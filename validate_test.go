@@ -0,0 +1,148 @@
+package avtools
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// problem is a Problem without its message.
+type problem struct {
+	chapter  int
+	kind     ProblemKind
+	severity Severity
+}
+
+func TestValidateRepair(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		chaps    []span
+		d        time.Duration
+		problems []problem
+		repaired []span
+	}{
+		{
+			name:     "clean",
+			chaps:    []span{{0, 20, "a"}, {20, 40, "b"}, {40, 60, "c"}},
+			d:        time.Minute,
+			repaired: []span{{0, 20, "a"}, {20, 40, "b"}, {40, 60, "c"}},
+		},
+		{
+			name:     "overlap",
+			chaps:    []span{{0, 25, "a"}, {20, 40, "b"}, {40, 60, "c"}},
+			d:        time.Minute,
+			problems: []problem{{0, Overlap, Error}},
+			repaired: []span{{0, 20, "a"}, {20, 40, "b"}, {40, 60, "c"}},
+		},
+		{
+			name:  "gaps",
+			chaps: []span{{5, 20, "a"}, {25, 40, "b"}, {40, 50, "c"}},
+			d:     time.Minute,
+			problems: []problem{
+				{0, Gap, Warning},
+				{0, Gap, Warning},
+				{2, Gap, Warning},
+			},
+			repaired: []span{{0, 25, "a"}, {25, 40, "b"}, {40, 60, "c"}},
+		},
+		{
+			name:     "zero length",
+			chaps:    []span{{0, 20, "a"}, {20, 20, "b"}, {40, 60, "c"}},
+			d:        time.Minute,
+			problems: []problem{{1, ZeroLength, Error}},
+			repaired: []span{{0, 20, "a"}, {20, 40, "b"}, {40, 60, "c"}},
+		},
+		{
+			name:     "same start",
+			chaps:    []span{{0, 20, "a"}, {20, 30, "b"}, {20, 40, "c"}, {40, 60, "d"}},
+			d:        time.Minute,
+			problems: []problem{{1, ZeroLength, Error}},
+			repaired: []span{{0, 20, "a"}, {20, 40, "c"}, {40, 60, "d"}},
+		},
+		{
+			name:     "ends past the end",
+			chaps:    []span{{0, 20, "a"}, {20, 70, "b"}},
+			d:        time.Minute,
+			problems: []problem{{1, PastEnd, Error}},
+			repaired: []span{{0, 20, "a"}, {20, 60, "b"}},
+		},
+		{
+			name:     "starts past the end",
+			chaps:    []span{{0, 20, "a"}, {20, 40, "b"}, {70, 80, "c"}},
+			d:        time.Minute,
+			problems: []problem{{1, Gap, Warning}, {2, PastEnd, Error}},
+			repaired: []span{{0, 20, "a"}, {20, 60, "b"}},
+		},
+		{
+			name:  "unsorted",
+			chaps: []span{{20, 40, "b"}, {0, 20, "a"}, {40, 60, "c"}},
+			d:     time.Minute,
+			problems: []problem{
+				{0, Gap, Warning},
+				{0, Overlap, Error},
+				{1, Unsorted, Error},
+				{1, Gap, Warning},
+			},
+			repaired: []span{{0, 20, "a"}, {20, 40, "b"}, {40, 60, "c"}},
+		},
+		{
+			name:     "negative start",
+			chaps:    []span{{-1, 20, "a"}, {20, 60, "b"}},
+			d:        time.Minute,
+			problems: []problem{{0, NegativeStart, Error}},
+			repaired: []span{{0, 20, "a"}, {20, 60, "b"}},
+		},
+		{
+			name:     "open last without duration",
+			chaps:    []span{{0, 20, "a"}, {20, 0, "b"}},
+			problems: []problem{{1, OpenEnd, Warning}},
+			repaired: []span{{0, 20, "a"}, {20, 0, "b"}},
+		},
+		{
+			name:     "open last with duration",
+			chaps:    []span{{0, 20, "a"}, {20, 0, "b"}},
+			d:        time.Minute,
+			problems: []problem{{1, OpenEnd, Warning}},
+			repaired: []span{{0, 20, "a"}, {20, 60, "b"}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := makeChapters(tt.chaps...)
+
+			r := c.Validate(tt.d)
+			checkProblems(t, r, tt.problems)
+
+			var errs bool
+			for _, p := range tt.problems {
+				errs = errs || p.severity == Error
+			}
+			if r.HasErrors() != errs {
+				t.Errorf("HasErrors %v, want %v", r.HasErrors(), errs)
+			}
+			if err := r.Err(); errs != errors.Is(err, ErrInvalidChapters) {
+				t.Errorf("Err %v", err)
+			}
+
+			repaired := c.Repair(tt.d)
+			checkProblems(t, repaired, tt.problems)
+			checkSpans(t, c, tt.repaired)
+
+			if r := c.Validate(tt.d); r.HasErrors() {
+				t.Errorf("repaired chapters have errors:\n%s", r)
+			}
+		})
+	}
+}
+
+func checkProblems(t *testing.T, r Report, want []problem) {
+	t.Helper()
+	if len(r) != len(want) {
+		t.Fatalf("got problems\n%s\nwant %v", r, want)
+	}
+	for i, w := range want {
+		got := problem{r[i].Chapter, r[i].Kind, r[i].Severity}
+		if got != w {
+			t.Errorf("problem %d: got %v, want %v", i+1, got, w)
+		}
+	}
+}
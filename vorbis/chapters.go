@@ -0,0 +1,194 @@
+package vorbis
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ohzqq/avtools"
+)
+
+// Chapter is a chapter stored as CHAPTERxxx comments, as done in Ogg Vorbis
+// and Opus files.
+type Chapter struct {
+	Num   int
+	URL   string
+	start time.Duration
+	end   time.Duration
+	title string
+}
+
+// IsChapterTag reports whether key is a CHAPTERxxx, CHAPTERxxxNAME or
+// CHAPTERxxxURL comment.
+func IsChapterTag(key string) bool {
+	_, _, ok := splitKey(key)
+	return ok
+}
+
+// splitKey returns the chapter number and the field of a chapter comment
+// key, which is empty for the timestamp.
+func splitKey(key string) (int, string, bool) {
+	upper := strings.ToUpper(key)
+	if !strings.HasPrefix(upper, "CHAPTER") {
+		return 0, "", false
+	}
+	rest := upper[len("CHAPTER"):]
+	i := 0
+	for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+		i++
+	}
+	if i == 0 {
+		return 0, "", false
+	}
+	num, err := strconv.Atoi(rest[:i])
+	if err != nil {
+		return 0, "", false
+	}
+	switch field := rest[i:]; field {
+	case "", "NAME", "URL":
+		return num, field, true
+	}
+	return 0, "", false
+}
+
+// Chapters collects the chapters in tags, ordered by number. Each chapter
+// ends at the start of the next, the last one at dur. Comments with an
+// unreadable timestamp are skipped.
+func Chapters(tags map[string]string, dur time.Duration) []*Chapter {
+	byNum := make(map[int]*Chapter)
+	get := func(n int) *Chapter {
+		if ch, ok := byNum[n]; ok {
+			return ch
+		}
+		ch := &Chapter{Num: n, start: -1}
+		byNum[n] = ch
+		return ch
+	}
+
+	for key, val := range tags {
+		n, field, ok := splitKey(key)
+		if !ok {
+			continue
+		}
+		ch := get(n)
+		switch field {
+		case "":
+			start, err := ParseTime(val)
+			if err == nil {
+				ch.start = start
+			}
+		case "NAME":
+			ch.title = val
+		case "URL":
+			ch.URL = val
+		}
+	}
+
+	var chaps []*Chapter
+	for _, ch := range byNum {
+		if ch.start >= 0 {
+			chaps = append(chaps, ch)
+		}
+	}
+	sort.Slice(chaps, func(i, j int) bool {
+		return chaps[i].Num < chaps[j].Num
+	})
+
+	for i, ch := range chaps {
+		ch.end = dur
+		if i+1 < len(chaps) {
+			ch.end = chaps[i+1].start
+		}
+	}
+
+	return chaps
+}
+
+// Comments returns the comments for chaps, numbered from 001, as key and
+// value pairs in order.
+func Comments(chaps []*avtools.Chapter) [][2]string {
+	var comments [][2]string
+	for i, ch := range chaps {
+		key := fmt.Sprintf("CHAPTER%03d", i+1)
+		comments = append(comments,
+			[2]string{key, FormatTime(ch.Start().Dur)},
+			[2]string{key + "NAME", ch.Title()},
+		)
+		if url := ch.Tags["url"]; url != "" {
+			comments = append(comments, [2]string{key + "URL", url})
+		}
+	}
+	return comments
+}
+
+// StripChapters returns a copy of tags without chapter comments.
+func StripChapters(tags map[string]string) map[string]string {
+	stripped := make(map[string]string)
+	for k, v := range tags {
+		if !IsChapterTag(k) {
+			stripped[k] = v
+		}
+	}
+	return stripped
+}
+
+func (ch Chapter) Start() time.Duration {
+	return ch.start
+}
+
+func (ch Chapter) End() time.Duration {
+	return ch.end
+}
+
+func (ch Chapter) Title() string {
+	return ch.title
+}
+
+// Tags holds the chapter url, when set.
+func (ch Chapter) Tags() map[string]string {
+	tags := make(map[string]string)
+	if ch.URL != "" {
+		tags["url"] = ch.URL
+	}
+	return tags
+}
+
+// ParseTime parses a HH:MM:SS.mmm timestamp; the fraction may have any
+// number of digits.
+func ParseTime(t string) (time.Duration, error) {
+	t = strings.TrimSpace(t)
+	invalid := fmt.Errorf("vorbis: chapter %w %q", avtools.ErrInvalidTimestamp, t)
+
+	split := strings.Split(t, ":")
+	if len(split) != 3 {
+		return 0, invalid
+	}
+
+	var d time.Duration
+	for _, s := range split[:2] {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return 0, invalid
+		}
+		d = d*60 + time.Duration(n)
+	}
+
+	secs, err := strconv.ParseFloat(split[2], 64)
+	if err != nil || secs < 0 {
+		return 0, invalid
+	}
+
+	return d*time.Minute + time.Duration(secs*float64(time.Second)).Round(time.Microsecond), nil
+}
+
+func FormatTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d",
+		ms/3600000,
+		ms/60000%60,
+		ms/1000%60,
+		ms%1000,
+	)
+}
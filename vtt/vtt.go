@@ -0,0 +1,256 @@
+package vtt
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ohzqq/avtools"
+	"github.com/ohzqq/fidi"
+)
+
+const Header = "WEBVTT"
+
+// Chapters is a WebVTT track of kind chapters.
+type Chapters struct {
+	fidi.File
+	Cues []*Cue
+}
+
+type Cue struct {
+	ID    string
+	start time.Duration
+	end   time.Duration
+	text  string
+}
+
+func Load(file string) (avtools.Metaz, error) {
+	src, err := avtools.NewFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	vtt := &Chapters{
+		File: src,
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return vtt, err
+	}
+	defer f.Close()
+
+	err = vtt.Parse(f)
+	if err != nil {
+		return vtt, err
+	}
+
+	return vtt, nil
+}
+
+// Parse reads the cues of a WebVTT file, skipping NOTE, STYLE and REGION
+// blocks. The lines of a cue's payload are joined by a space.
+func (c *Chapters) Parse(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+
+	if !scanner.Scan() {
+		return fmt.Errorf("vtt: empty file")
+	}
+	first := strings.TrimPrefix(scanner.Text(), "\ufeff")
+	if !strings.HasPrefix(first, Header) {
+		return fmt.Errorf("vtt: missing WEBVTT header")
+	}
+
+	var block []string
+	flush := func() error {
+		defer func() { block = nil }()
+		if len(block) == 0 {
+			return nil
+		}
+		switch strings.SplitN(block[0], " ", 2)[0] {
+		case "NOTE", "STYLE", "REGION":
+			return nil
+		}
+		cue, err := parseCue(block)
+		if err != nil {
+			return err
+		}
+		if cue != nil {
+			c.Cues = append(c.Cues, cue)
+		}
+		return nil
+	}
+
+	// skip the rest of the header block
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			break
+		}
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		if line == "" {
+			if err := flush(); err != nil {
+				return err
+			}
+			continue
+		}
+		block = append(block, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+func parseCue(block []string) (*Cue, error) {
+	cue := &Cue{}
+	if !strings.Contains(block[0], "-->") {
+		cue.ID = block[0]
+		block = block[1:]
+	}
+	if len(block) == 0 {
+		return nil, nil
+	}
+
+	start, rest, ok := strings.Cut(block[0], "-->")
+	if !ok {
+		return nil, fmt.Errorf("vtt: cue timing %w %q", avtools.ErrInvalidTimestamp, block[0])
+	}
+	end := strings.Fields(rest)
+	if len(end) == 0 {
+		return nil, fmt.Errorf("vtt: cue timing %w %q", avtools.ErrInvalidTimestamp, block[0])
+	}
+
+	var err error
+	cue.start, err = ParseTime(strings.TrimSpace(start))
+	if err != nil {
+		return nil, err
+	}
+	cue.end, err = ParseTime(end[0])
+	if err != nil {
+		return nil, err
+	}
+	cue.text = strings.Join(block[1:], " ")
+
+	return cue, nil
+}
+
+// Dump writes the chapters of meta as a WebVTT chapters track, numbering
+// cues from 1.
+func Dump(meta avtools.Meta) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(Header + "\n")
+
+	var dur time.Duration
+	if d, err := strconv.ParseFloat(meta.Tags()["duration"], 64); err == nil {
+		dur = time.Duration(d * float64(time.Second))
+	}
+
+	chaps := meta.Chapters()
+	for i, ch := range chaps {
+		end := ch.End().Dur
+		if end <= ch.Start().Dur {
+			end = dur
+			if i+1 < len(chaps) {
+				end = chaps[i+1].Start().Dur
+			}
+		}
+		title := ch.Title()
+		if title == "" {
+			title = fmt.Sprintf("Chapter %d", i+1)
+		}
+		fmt.Fprintf(&buf, "\n%d\n%s --> %s\n%s\n",
+			i+1,
+			FormatTime(ch.Start().Dur),
+			FormatTime(end),
+			title,
+		)
+	}
+
+	return buf.Bytes()
+}
+
+func (c Chapters) Chapters() []avtools.ChapterMeta {
+	var chaps []avtools.ChapterMeta
+	for _, cue := range c.Cues {
+		chaps = append(chaps, cue)
+	}
+	return chaps
+}
+
+func (c Chapters) Tags() map[string]string {
+	return map[string]string{}
+}
+
+func (c Chapters) Streams() []map[string]string {
+	return []map[string]string{}
+}
+
+func (c Chapters) Source() fidi.File {
+	return c.File
+}
+
+func (c Cue) Start() time.Duration {
+	return c.start
+}
+
+func (c Cue) End() time.Duration {
+	return c.end
+}
+
+func (c Cue) Title() string {
+	return c.text
+}
+
+// ParseTime parses a [hh:]mm:ss.ttt timestamp.
+func ParseTime(t string) (time.Duration, error) {
+	invalid := fmt.Errorf("vtt: %w %q", avtools.ErrInvalidTimestamp, t)
+
+	secs, frac, ok := strings.Cut(t, ".")
+	if !ok || len(frac) != 3 {
+		return 0, invalid
+	}
+	ms, err := strconv.Atoi(frac)
+	if err != nil {
+		return 0, invalid
+	}
+
+	split := strings.Split(secs, ":")
+	if len(split) < 2 || len(split) > 3 {
+		return 0, invalid
+	}
+
+	var d time.Duration
+	for _, s := range split {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return 0, invalid
+		}
+		d = d*60 + time.Duration(n)
+	}
+
+	return d*time.Second + time.Duration(ms)*time.Millisecond, nil
+}
+
+func FormatTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d",
+		ms/3600000,
+		ms/60000%60,
+		ms/1000%60,
+		ms%1000,
+	)
+}
+
+// Sniff reports whether data starts with the WEBVTT header.
+func Sniff(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimPrefix(data, []byte("\ufeff")), []byte(Header))
+}
@@ -0,0 +1,266 @@
+package youtube
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ohzqq/avtools"
+	"github.com/ohzqq/fidi"
+)
+
+// MinLength is the shortest chapter YouTube accepts.
+const MinLength = 10 * time.Second
+
+var (
+	stamp    = `\d{1,2}(?::\d{1,2}){1,2}(?:\.\d+)?`
+	leading  = regexp.MustCompile(`^[\s\-*•·–—>#\d.)]*?[\[(]?(` + stamp + `)[\])]?\s*[-–—:|.)]*\s*(.*)$`)
+	trailing = regexp.MustCompile(`^[\s\-*•·–—>]*(.*?)\s*[-–—:|]*\s*[\[(]?(` + stamp + `)[\])]?$`)
+)
+
+// Chapters is a list of timestamps as found in video descriptions and show
+// notes. Duration, when set, ends the last chapter.
+type Chapters struct {
+	fidi.File
+	Chaps    []*Chapter
+	Duration time.Duration
+}
+
+type Chapter struct {
+	start time.Duration
+	end   time.Duration
+	title string
+}
+
+func Load(file string) (avtools.Metaz, error) {
+	src, err := avtools.NewFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := &Chapters{
+		File: src,
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return ch, err
+	}
+	defer f.Close()
+
+	err = ch.Parse(f)
+	if err != nil {
+		return ch, err
+	}
+
+	return ch, nil
+}
+
+// Parse reads every line holding a H:MM:SS or MM:SS timestamp, before or
+// after the title, ignoring bullets, brackets and separators. Other lines
+// are skipped.
+func (c *Chapters) Parse(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if line == "" {
+			continue
+		}
+
+		var ts, title string
+		if m := leading.FindStringSubmatch(line); m != nil {
+			ts, title = m[1], m[2]
+		} else if m := trailing.FindStringSubmatch(line); m != nil {
+			ts, title = m[2], m[1]
+		} else {
+			continue
+		}
+
+		start, err := ParseTime(ts)
+		if err != nil {
+			return err
+		}
+		c.Chaps = append(c.Chaps, &Chapter{
+			start: start,
+			title: strings.TrimSpace(title),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(c.Chaps) == 0 {
+		return fmt.Errorf("youtube: no timestamps found")
+	}
+	for i := 1; i < len(c.Chaps); i++ {
+		if c.Chaps[i].start <= c.Chaps[i-1].start {
+			return fmt.Errorf("youtube: timestamp %s is not after %s",
+				FormatTime(c.Chaps[i].start),
+				FormatTime(c.Chaps[i-1].start),
+			)
+		}
+	}
+	c.setEnds()
+
+	return nil
+}
+
+// SetDuration sets the media duration, which ends the last chapter.
+func (c *Chapters) SetDuration(d time.Duration) {
+	c.Duration = d
+	c.setEnds()
+}
+
+func (c *Chapters) setEnds() {
+	for i, ch := range c.Chaps {
+		ch.end = c.Duration
+		if i+1 < len(c.Chaps) {
+			ch.end = c.Chaps[i+1].start
+		}
+	}
+}
+
+// Dump writes the chapters of meta as a timestamp list. The first chapter
+// always starts at 0:00; Check lists the problems YouTube would reject.
+func Dump(meta avtools.Meta) []byte {
+	var buf bytes.Buffer
+	hours := false
+	for _, ch := range meta.Chapters() {
+		if ch.Start().Dur >= time.Hour {
+			hours = true
+		}
+	}
+	for i, ch := range meta.Chapters() {
+		start := ch.Start().Dur
+		if i == 0 {
+			start = 0
+		}
+		title := ch.Title()
+		if title == "" {
+			title = fmt.Sprintf("Chapter %d", i+1)
+		}
+		fmt.Fprintf(&buf, "%s %s\n", formatTime(start, hours), title)
+	}
+
+	return buf.Bytes()
+}
+
+// Check lists the ways the chapters of meta break YouTube's rules: at least
+// three chapters, each at least MinLength long.
+func Check(meta avtools.Meta) []string {
+	var warn []string
+
+	chaps := meta.Chapters()
+	if len(chaps) < 3 {
+		warn = append(warn, fmt.Sprintf("%d chapters, at least 3 are needed", len(chaps)))
+	}
+
+	var dur time.Duration
+	if d, err := strconv.ParseFloat(meta.Tags()["duration"], 64); err == nil {
+		dur = time.Duration(d * float64(time.Second))
+	}
+
+	for i, ch := range chaps {
+		start := ch.Start().Dur
+		if i == 0 {
+			start = 0
+		}
+		end := ch.End().Dur
+		if i+1 < len(chaps) {
+			end = chaps[i+1].Start().Dur
+		} else if end <= start {
+			end = dur
+		}
+		if end > start && end-start < MinLength {
+			warn = append(warn, fmt.Sprintf("chapter %d %q is shorter than %s", i+1, ch.Title(), MinLength))
+		}
+	}
+
+	return warn
+}
+
+func (c Chapters) Chapters() []avtools.ChapterMeta {
+	var chaps []avtools.ChapterMeta
+	for _, ch := range c.Chaps {
+		chaps = append(chaps, ch)
+	}
+	return chaps
+}
+
+func (c Chapters) Tags() map[string]string {
+	tags := make(map[string]string)
+	if c.Duration > 0 {
+		tags["duration"] = strconv.FormatFloat(c.Duration.Seconds(), 'f', 3, 64)
+	}
+	return tags
+}
+
+func (c Chapters) Streams() []map[string]string {
+	return []map[string]string{}
+}
+
+func (c Chapters) Source() fidi.File {
+	return c.File
+}
+
+func (ch Chapter) Start() time.Duration {
+	return ch.start
+}
+
+func (ch Chapter) End() time.Duration {
+	return ch.end
+}
+
+func (ch Chapter) Title() string {
+	return ch.title
+}
+
+// ParseTime parses a [H:]MM:SS timestamp, with optional fractional seconds.
+func ParseTime(t string) (time.Duration, error) {
+	invalid := fmt.Errorf("youtube: %w %q", avtools.ErrInvalidTimestamp, t)
+
+	secs, frac, _ := strings.Cut(t, ".")
+	split := strings.Split(secs, ":")
+	if len(split) < 2 || len(split) > 3 {
+		return 0, invalid
+	}
+
+	var d time.Duration
+	for i, s := range split {
+		n, err := strconv.Atoi(s)
+		if err != nil || (i > 0 && n > 59) {
+			return 0, invalid
+		}
+		d = d*60 + time.Duration(n)
+	}
+	d *= time.Second
+
+	if frac != "" {
+		f, err := strconv.ParseFloat("0."+frac, 64)
+		if err != nil {
+			return 0, invalid
+		}
+		d += time.Duration(f * float64(time.Second))
+	}
+
+	return d, nil
+}
+
+// FormatTime formats d as M:SS, or H:MM:SS from an hour on.
+func FormatTime(d time.Duration) string {
+	return formatTime(d, d >= time.Hour)
+}
+
+func formatTime(d time.Duration, hours bool) string {
+	s := int(d / time.Second)
+	if hours {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}